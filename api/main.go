@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/jimiolaniyan/gomicroblog/auth"
@@ -18,8 +19,25 @@ import (
 
 var dbURL = os.Getenv("DATABASE_URL")
 var dbName = os.Getenv("DATABASE_NAME")
+var maxPostLength = os.Getenv("MAX_POST_LENGTH")
+var mediaDir = os.Getenv("MEDIA_DIR")
+
+const (
+	// orphanedMediaTTL is how long uploaded media is kept without being added to a post
+	orphanedMediaTTL = 24 * time.Hour
+	// publishInterval is how often scheduled posts are checked for being due
+	publishInterval = 15 * time.Second
+)
 
 func main() {
+	if maxPostLength != "" {
+		n, err := strconv.Atoi(maxPostLength)
+		if err != nil {
+			log.Fatalf("invalid MAX_POST_LENGTH: %s", err)
+		}
+		MaxBodyLength = n
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -35,15 +53,73 @@ func main() {
 
 	u := client.Database(dbName).Collection("users")
 	p := client.Database(dbName).Collection("posts")
+	r := client.Database(dbName).Collection("reactions")
+	a := client.Database(dbName).Collection("attachments")
+	d := client.Database(dbName).Collection("drafts")
+	v := client.Database(dbName).Collection("votes")
+	b := client.Database(dbName).Collection("bookmarks")
+	t := client.Database(dbName).Collection("timelines")
+	tr := client.Database(dbName).Collection("trends")
 
-	svc := NewService(NewMongoUserRepository(u), NewMongoPostRepository(p))
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	blobs, err := NewFileBlobStore(mediaDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	svc := NewService(NewMongoUserRepository(u), NewMongoPostRepository(p),
+		WithReactionRepository(NewMongoReactionRepository(r)),
+		WithMedia(NewMongoAttachmentRepository(a), blobs),
+		WithDraftRepository(NewMongoDraftRepository(d)),
+		WithVoteRepository(NewMongoVoteRepository(v)),
+		WithBookmarkRepository(NewMongoBookmarkRepository(b)),
+		WithTimelineRepository(NewMongoTimelineRepository(t)),
+		WithTrendRepository(NewMongoTrendRepository(tr)))
+	go collectOrphanedMedia(svc)
+	go publishDuePosts(svc)
 	authSvc := auth.NewService(auth.NewAccountRepository(), NewAccountCreatedHandler(svc))
 
 	router := httprouter.New()
 	router.Handler(http.MethodPost, "/auth/v1/accounts", auth.RegisterAccountHandler(authSvc))
 	router.Handler(http.MethodPost, "/auth/v1/sessions", auth.LoginHandler(authSvc))
 	router.Handler(http.MethodPost, "/v1/posts", RequireAuth(LastSeenMiddleware(CreatePostHandler(svc), svc)))
-	router.Handler(http.MethodGet, "/v1/users/:username", LastSeenMiddleware(GetProfileHandler(svc), svc))
+	router.Handler(http.MethodGet, "/v1/posts/:id", OptionalAuth(LastSeenMiddleware(GetPostHandler(svc), svc)))
+	router.Handler(http.MethodPatch, "/v1/posts/:id", RequireAuth(LastSeenMiddleware(EditPostHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/posts/:id/thread", OptionalAuth(LastSeenMiddleware(GetThreadHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/posts/:id/revisions", OptionalAuth(LastSeenMiddleware(GetPostRevisionsHandler(svc), svc)))
+	router.Handler(http.MethodDelete, "/v1/posts/:id", RequireAuth(LastSeenMiddleware(DeletePostHandler(svc), svc)))
+	router.Handler(http.MethodPost, "/v1/posts/:id/reposts", RequireAuth(LastSeenMiddleware(CreateRepostHandler(svc), svc)))
+	router.Handler(http.MethodDelete, "/v1/posts/:id/reposts", RequireAuth(LastSeenMiddleware(RemoveRepostHandler(svc), svc)))
+	router.Handler(http.MethodPost, "/v1/posts/:id/reactions", RequireAuth(LastSeenMiddleware(CreateReactionHandler(svc), svc)))
+	router.Handler(http.MethodPost, "/v1/posts/:id/pin", RequireAuth(LastSeenMiddleware(PinPostHandler(svc), svc)))
+	router.Handler(http.MethodDelete, "/v1/posts/:id/pin", RequireAuth(LastSeenMiddleware(UnpinPostHandler(svc), svc)))
+	router.Handler(http.MethodPost, "/v1/posts/:id/bookmark", RequireAuth(LastSeenMiddleware(BookmarkPostHandler(svc), svc)))
+	router.Handler(http.MethodDelete, "/v1/posts/:id/bookmark", RequireAuth(LastSeenMiddleware(RemoveBookmarkHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/bookmarks", RequireAuth(LastSeenMiddleware(GetBookmarksHandler(svc), svc)))
+	router.Handler(http.MethodPost, "/v1/posts/:id/votes", RequireAuth(LastSeenMiddleware(VoteHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/posts/:id/reactions", OptionalAuth(LastSeenMiddleware(GetReactionsHandler(svc), svc)))
+	router.Handler(http.MethodDelete, "/v1/posts/:id/reactions/:type", RequireAuth(LastSeenMiddleware(RemoveReactionHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/scheduled", RequireAuth(LastSeenMiddleware(GetScheduledPostsHandler(svc), svc)))
+	router.Handler(http.MethodPatch, "/v1/scheduled/:id", RequireAuth(LastSeenMiddleware(ReschedulePostHandler(svc), svc)))
+	router.Handler(http.MethodDelete, "/v1/scheduled/:id", RequireAuth(LastSeenMiddleware(CancelScheduledPostHandler(svc), svc)))
+	router.Handler(http.MethodPost, "/v1/drafts", RequireAuth(LastSeenMiddleware(CreateDraftHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/drafts", RequireAuth(LastSeenMiddleware(GetDraftsHandler(svc), svc)))
+	router.Handler(http.MethodPatch, "/v1/drafts/:id", RequireAuth(LastSeenMiddleware(UpdateDraftHandler(svc), svc)))
+	router.Handler(http.MethodDelete, "/v1/drafts/:id", RequireAuth(LastSeenMiddleware(DeleteDraftHandler(svc), svc)))
+	router.Handler(http.MethodPost, "/v1/drafts/:id/publish", RequireAuth(LastSeenMiddleware(PublishDraftHandler(svc), svc)))
+	router.Handler(http.MethodPost, "/v1/media", RequireAuth(LastSeenMiddleware(UploadMediaHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/media/:key", OptionalAuth(GetMediaHandler(svc)))
+	router.Handler(http.MethodGet, "/v1/timeline", RequireAuth(LastSeenMiddleware(GetTimelineHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/explore", OptionalAuth(LastSeenMiddleware(GetExploreHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/users/:username", OptionalAuth(LastSeenMiddleware(GetProfileHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/users/:username/posts", OptionalAuth(LastSeenMiddleware(GetUserPostsHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/users/:username/mentions", OptionalAuth(LastSeenMiddleware(GetUserMentionsHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/tags/:tag/posts", OptionalAuth(LastSeenMiddleware(GetTagPostsHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/search/posts", OptionalAuth(LastSeenMiddleware(SearchPostsHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/search/users", OptionalAuth(LastSeenMiddleware(SearchUsersHandler(svc), svc)))
+	router.Handler(http.MethodGet, "/v1/trends", OptionalAuth(LastSeenMiddleware(GetTrendsHandler(svc), svc)))
 	router.Handler(http.MethodPatch, "/v1/users", RequireAuth(LastSeenMiddleware(EditProfileHandler(svc), svc)))
 	router.Handler(http.MethodGet, "/v1/users/:username/followers", RequireAuth(LastSeenMiddleware(GetUserFollowersHandler(svc), svc)))
 	router.Handler(http.MethodGet, "/v1/users/:username/friends", RequireAuth(LastSeenMiddleware(GetUserFriendsHandler(svc), svc)))
@@ -53,3 +129,30 @@ func main() {
 	log.Printf("Server started. Listening on port: %s\n", "8090")
 	log.Fatal(http.ListenAndServe(":"+"8090", router))
 }
+
+func collectOrphanedMedia(svc Service) {
+	for range time.Tick(time.Hour) {
+		n, err := svc.DeleteOrphanedMedia(orphanedMediaTTL)
+		if err != nil {
+			log.Println(err)
+		}
+		if n > 0 {
+			log.Printf("Deleted %d orphaned media\n", n)
+		}
+	}
+}
+
+// publishDuePosts publishes scheduled posts as they become due, starting with
+// any that fell due while the server was down
+func publishDuePosts(svc Service) {
+	for {
+		n, err := svc.PublishDuePosts()
+		if err != nil {
+			log.Println(err)
+		}
+		if n > 0 {
+			log.Printf("Published %d scheduled posts\n", n)
+		}
+		time.Sleep(publishInterval)
+	}
+}
@@ -0,0 +1,87 @@
+package blog
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrInvalidAttachment  = errors.New("attachment can't be added to post")
+	ErrTooManyAttachments = errors.New("too many attachments")
+	ErrAltTextTooLong     = errors.New("alt text is too long")
+)
+
+const (
+	maxAttachments   = 4
+	maxAltTextLength = 1000
+)
+
+type AttachmentRepository interface {
+	Store(a Attachment) error
+	FindByIDs(ids []AttachmentID) ([]Attachment, error)
+	FindByPost(postID PostID) ([]Attachment, error)
+	// Attach links unattached attachments of the owner to a post. It fails
+	// with ErrInvalidAttachment, without attaching any, if one of them isn't.
+	Attach(owner ID, ids []AttachmentID, postID PostID) error
+	// FindOrphans returns the attachments uploaded before the given time that
+	// were never added to a post
+	FindOrphans(before time.Time) ([]Attachment, error)
+	// DeleteOrphan deletes an attachment unless it was added to a post, in
+	// which case it returns ErrAttachmentNotFound
+	DeleteOrphan(id AttachmentID) error
+	Delete(id AttachmentID) error
+}
+
+type AttachmentID string
+
+// Attachment is an image uploaded to be shown with a post. It is an orphan
+// until it is added to a post.
+type Attachment struct {
+	ID            AttachmentID `bson:"_id"`
+	Owner         ID           `bson:"owner"`
+	PostID        PostID       `bson:"post_id,omitempty"`
+	ContentType   string       `bson:"content_type"`
+	Width, Height int
+	AltText       string    `bson:"alt_text"`
+	Key           string    `bson:"key"`
+	ThumbnailKey  string    `bson:"thumbnail_key"`
+	CreatedAt     time.Time `bson:"created_at"`
+}
+
+// NewAttachment returns an attachment for an image along with the keys its
+// files are to be stored under
+func NewAttachment(owner ID, img *processedImage, altText string) (*Attachment, error) {
+	altText = strings.TrimSpace(altText)
+	if graphemeCount(altText) > maxAltTextLength {
+		return nil, ErrAltTextTooLong
+	}
+
+	id := AttachmentID(xid.New().String())
+	return &Attachment{
+		ID:           id,
+		Owner:        owner,
+		ContentType:  img.ContentType,
+		Width:        img.Width,
+		Height:       img.Height,
+		AltText:      altText,
+		Key:          string(id) + mediaExtensions[img.ContentType],
+		ThumbnailKey: string(id) + "_thumb" + mediaExtensions[img.ThumbnailType],
+		CreatedAt:    time.Now().UTC(),
+	}, nil
+}
+
+// attachmentIDFromKey returns the id of the attachment a file key belongs to
+func attachmentIDFromKey(key string) AttachmentID {
+	id := strings.TrimSuffix(key, path.Ext(key))
+	return AttachmentID(strings.TrimSuffix(id, "_thumb"))
+}
+
+// IsOrphan reports whether the attachment was never added to a post
+func (a *Attachment) IsOrphan() bool {
+	return a.PostID == ""
+}
@@ -18,7 +18,7 @@ type Account struct {
 
 type ID string
 
-//Credentials holds the account's sensitive information
+// Credentials holds the account's sensitive information
 type Credentials struct {
 	Username,
 	Email,
@@ -35,7 +35,7 @@ var (
 	ErrInvalidCredentials = errors.New("invalid username or password")
 )
 
-//NewAccount validates username and email and returns a new Account if
+// NewAccount validates username and email and returns a new Account if
 // arguments are valid
 func NewAccount(username string, email string) (*Account, error) {
 	r := regexp.MustCompile(`^\w{1,24}$`)
@@ -21,7 +21,18 @@ type BddTestSuite struct {
 
 func (bs *BddTestSuite) SetupSuite() {
 	bs.now = time.Now().UTC()
-	bs.svc = service{users: NewUserRepository(), posts: NewPostRepository()}
+	bs.svc = service{
+		users:       NewUserRepository(),
+		posts:       NewPostRepository(),
+		reactions:   NewReactionRepository(),
+		attachments: NewAttachmentRepository(),
+		blobs:       NewBlobStore(),
+		drafts:      NewDraftRepository(),
+		votes:       NewVoteRepository(),
+		bookmarks:   NewBookmarkRepository(),
+		timelines:   NewTimelineRepository(),
+		trends:      NewTrendRepository(),
+	}
 
 	bs.userID = nextID()
 	bs.username = "U"
@@ -35,6 +46,7 @@ func (bs *BddTestSuite) SetupSuite() {
 
 func (bs *BddTestSuite) TearDownTest() {
 	bs.svc.posts = NewPostRepository()
+	bs.svc.reactions = NewReactionRepository()
 }
 
 func (bs *BddTestSuite) TestPostCreation() {
@@ -42,15 +54,15 @@ func (bs *BddTestSuite) TestPostCreation() {
 		body := "P"
 
 		Convey("When U creates P", func() {
-			postID, err := bs.svc.CreatePost(bs.userID, body)
+			postID, err := bs.svc.CreatePost(bs.userID, createPostRequest{Body: body})
 			So(err, ShouldBeNil)
 			So(IsValidID(string(postID)), ShouldBeTrue)
 
 			Convey("Then the user's posts will contain P", func() {
-				posts, _ := bs.svc.GetUserPosts(bs.username)
-				p := &Post{}
+				posts, _ := bs.svc.GetUserPosts("", bs.username, Page{})
+				p := postResponse{}
 
-				for _, post := range posts {
+				for _, post := range posts.Posts {
 					if post.Author.UserID == bs.userID && post.Body == body {
 						p = post
 					}
@@ -68,7 +80,7 @@ func (bs *BddTestSuite) TestProfileWithNoPosts() {
 	Convey("Given a newly registered user U with no posts", bs.T(), func() {
 
 		Convey("When his profile is requested", func() {
-			profile, err := bs.svc.GetProfile(bs.username)
+			profile, err := bs.svc.GetProfile("", bs.username)
 			So(err, ShouldBeNil)
 			So(profile, ShouldNotBeNil)
 
@@ -80,6 +92,7 @@ func (bs *BddTestSuite) TestProfileWithNoPosts() {
 					Bio:      "",
 					Joined:   profile.Joined,
 					LastSeen: profile.LastSeen,
+					Pinned:   []postResponse{},
 					Posts:    []postResponse{},
 				}
 
@@ -108,7 +121,7 @@ func (bs *BddTestSuite) TestProfileWithPostsAndFriends() {
 			u1.Follow(u2)
 			u2.Follow(u1)
 			Convey("When his profile is requested", func() {
-				profile, err := bs.svc.GetProfile(u1.Username)
+				profile, err := bs.svc.GetProfile("", u1.Username)
 
 				So(err, ShouldBeNil)
 				So(profile, ShouldNotBeNil)
@@ -118,9 +131,9 @@ func (bs *BddTestSuite) TestProfileWithPostsAndFriends() {
 					expected := Profile{
 						Relationships: Relationships{Followers: 1, Friends: 1},
 						Posts: []postResponse{
-							{ID: postIDs[2], Author: ar, Body: "C", Timestamp: profile.Posts[0].Timestamp},
-							{ID: postIDs[1], Author: ar, Body: "B", Timestamp: profile.Posts[1].Timestamp},
-							{ID: postIDs[0], Author: ar, Body: "A", Timestamp: profile.Posts[2].Timestamp},
+							{ID: postIDs[2], Kind: KindPost, Visibility: VisibilityPublic, Author: ar, Body: "C", Timestamp: profile.Posts[0].Timestamp, Reactions: noReactions, Entities: noEntities},
+							{ID: postIDs[1], Kind: KindPost, Visibility: VisibilityPublic, Author: ar, Body: "B", Timestamp: profile.Posts[1].Timestamp, Reactions: noReactions, Entities: noEntities},
+							{ID: postIDs[0], Kind: KindPost, Visibility: VisibilityPublic, Author: ar, Body: "A", Timestamp: profile.Posts[2].Timestamp, Reactions: noReactions, Entities: noEntities},
 						},
 					}
 
@@ -160,7 +173,7 @@ func (bs *BddTestSuite) TestEditUserProfile() {
 			So(err, ShouldBeNil)
 
 			Convey("Then his profile shows the updated information", func() {
-				profile, err := bs.svc.GetProfile(existingUser.Username)
+				profile, err := bs.svc.GetProfile("", existingUser.Username)
 
 				So(err, ShouldBeNil)
 				So(profile.Username, ShouldEqual, newU)
@@ -272,29 +285,31 @@ func (bs *BddTestSuite) TestTimelines() {
 		u1.Follow(u3)
 		Convey("With U1, U2 and U3 having the following posts", func() {
 			posts := []string{"p1", "p2", "p3", "p4", "p5", "p6"}
-			p21ID, _ := bs.svc.CreatePost(u2.ID, posts[1])
-			p31ID, _ := bs.svc.CreatePost(u3.ID, posts[0])
-			p22ID, _ := bs.svc.CreatePost(u2.ID, posts[5])
-			p11ID, _ := bs.svc.CreatePost(u1.ID, posts[2])
-			p32ID, _ := bs.svc.CreatePost(u3.ID, posts[3])
-			p12ID, _ := bs.svc.CreatePost(u2.ID, posts[4])
+			p21ID, _ := bs.svc.CreatePost(u2.ID, createPostRequest{Body: posts[1]})
+			p31ID, _ := bs.svc.CreatePost(u3.ID, createPostRequest{Body: posts[0]})
+			p22ID, _ := bs.svc.CreatePost(u2.ID, createPostRequest{Body: posts[5]})
+			p11ID, _ := bs.svc.CreatePost(u1.ID, createPostRequest{Body: posts[2]})
+			p32ID, _ := bs.svc.CreatePost(u3.ID, createPostRequest{Body: posts[3]})
+			p12ID, _ := bs.svc.CreatePost(u2.ID, createPostRequest{Body: posts[4]})
 
 			Convey("When requests his timeline", func() {
-				tl, err := bs.svc.GetTimeline(u1.ID)
+				tl, err := bs.svc.GetTimeline(u1.ID, Page{})
 				So(err, ShouldBeNil)
 
 				Convey("Then his timeline is as follows", func() {
-					ar := authorResponse{u1.ID, u1.Username, avatar(u1.Email)}
+					ar1 := authorResponse{UserID: u1.ID, Username: u1.Username, Avatar: avatar(u1.Email)}
+					ar2 := authorResponse{UserID: u2.ID, Username: u2.Username, Avatar: avatar(u2.Email)}
+					ar3 := authorResponse{UserID: u3.ID, Username: u3.Username, Avatar: avatar(u3.Email)}
 					expectedTL := []postResponse{
-						{p12ID, posts[4], tl[0].Timestamp, ar},
-						{p32ID, posts[3], tl[1].Timestamp, ar},
-						{p11ID, posts[2], tl[2].Timestamp, ar},
-						{p22ID, posts[5], tl[3].Timestamp, ar},
-						{p31ID, posts[0], tl[4].Timestamp, ar},
-						{p21ID, posts[1], tl[5].Timestamp, ar},
+						{ID: p12ID, Kind: KindPost, Visibility: VisibilityPublic, Body: posts[4], Timestamp: tl.Posts[0].Timestamp, Author: ar2, Reactions: noReactions, Entities: noEntities},
+						{ID: p32ID, Kind: KindPost, Visibility: VisibilityPublic, Body: posts[3], Timestamp: tl.Posts[1].Timestamp, Author: ar3, Reactions: noReactions, Entities: noEntities},
+						{ID: p11ID, Kind: KindPost, Visibility: VisibilityPublic, Body: posts[2], Timestamp: tl.Posts[2].Timestamp, Author: ar1, Reactions: noReactions, Entities: noEntities},
+						{ID: p22ID, Kind: KindPost, Visibility: VisibilityPublic, Body: posts[5], Timestamp: tl.Posts[3].Timestamp, Author: ar2, Reactions: noReactions, Entities: noEntities},
+						{ID: p31ID, Kind: KindPost, Visibility: VisibilityPublic, Body: posts[0], Timestamp: tl.Posts[4].Timestamp, Author: ar3, Reactions: noReactions, Entities: noEntities},
+						{ID: p21ID, Kind: KindPost, Visibility: VisibilityPublic, Body: posts[1], Timestamp: tl.Posts[5].Timestamp, Author: ar2, Reactions: noReactions, Entities: noEntities},
 					}
 
-					So(tl, ShouldResemble, expectedTL)
+					So(tl.Posts, ShouldResemble, expectedTL)
 
 					Reset(func() {
 						_ = bs.svc.users.Delete(u1.ID)
@@ -308,6 +323,11 @@ func (bs *BddTestSuite) TestTimelines() {
 	})
 }
 
+var (
+	noReactions = map[ReactionType]int{}
+	noEntities  = []entityResponse{}
+)
+
 func createInfoFromUser(u2 *User) UserInfo {
 	return UserInfo{
 		ID:       u2.ID,
@@ -329,9 +349,9 @@ func getUserInfoFromList(infos []UserInfo, id ID) UserInfo {
 }
 
 func createPosts(id ID, svc service) (ids []PostID, ok bool) {
-	id1, _ := svc.CreatePost(id, "A")
-	id2, _ := svc.CreatePost(id, "B")
-	id3, _ := svc.CreatePost(id, "C")
+	id1, _ := svc.CreatePost(id, createPostRequest{Body: "A"})
+	id2, _ := svc.CreatePost(id, createPostRequest{Body: "B"})
+	id3, _ := svc.CreatePost(id, createPostRequest{Body: "C"})
 
 	ids = append(ids, id1, id2, id3)
 	ok = true
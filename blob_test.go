@@ -0,0 +1,42 @@
+package blog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileBlobStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileBlobStore(filepath.Join(dir, "media"))
+	assert.Nil(t, err)
+
+	for _, key := range []string{"", ".hidden", "../escape", `a\b`} {
+		assert.Equal(t, ErrBlobNotFound, store.Put(key, strings.NewReader("data")), key)
+	}
+
+	_, err = store.Get("missing.png")
+	assert.Equal(t, ErrBlobNotFound, err)
+
+	assert.Nil(t, store.Put("image.png", strings.NewReader("data")))
+	r, err := store.Get("image.png")
+	assert.Nil(t, err)
+	data, _ := ioutil.ReadAll(r)
+	_ = r.Close()
+	assert.Equal(t, "data", string(data))
+
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "media"))
+	assert.Equal(t, 1, len(files))
+
+	assert.Nil(t, store.Delete("image.png"))
+	assert.Nil(t, store.Delete("image.png"))
+	_, err = store.Get("image.png")
+	assert.Equal(t, ErrBlobNotFound, err)
+}
@@ -0,0 +1,48 @@
+package blog
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+var (
+	ErrAlreadyBookmarked = errors.New("post already bookmarked")
+	ErrNotBookmarked     = errors.New("post not bookmarked")
+)
+
+type BookmarkRepository interface {
+	// Store saves a bookmark. It returns ErrAlreadyBookmarked if the user
+	// already bookmarked the post.
+	Store(b Bookmark) error
+	Delete(userID ID, postID PostID) error
+	DeleteByPost(postID PostID) error
+	// FindByUser returns the bookmarks of the user within page, most recently
+	// saved first. The page cursors are bookmark ids.
+	FindByUser(userID ID, page Page) ([]Bookmark, error)
+}
+
+type BookmarkID string
+
+// Bookmark saves a post for a user to read later. Bookmarks are private, the
+// author of the post is never told about them.
+type Bookmark struct {
+	ID        BookmarkID `bson:"_id"`
+	UserID    ID         `bson:"user_id"`
+	PostID    PostID     `bson:"post_id"`
+	Timestamp time.Time
+}
+
+func NewBookmark(userID ID, postID PostID) *Bookmark {
+	return &Bookmark{
+		ID:        BookmarkID(xid.New().String()),
+		UserID:    userID,
+		PostID:    postID,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+func (b Bookmark) key() string {
+	return string(b.UserID) + ":" + string(b.PostID)
+}
@@ -0,0 +1,65 @@
+package blog
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+var ErrDraftNotFound = errors.New("draft not found")
+
+// maxDraftLength is the longest draft body in bytes. Drafts can run over
+// MaxBodyLength while they are being written, they are only held to it when
+// published.
+const maxDraftLength = 10000
+
+type DraftRepository interface {
+	Store(d Draft) error
+	Update(d Draft) error
+	FindByID(id DraftID) (Draft, error)
+	// FindByAuthor returns the drafts of a user, most recently updated first
+	FindByAuthor(id ID) ([]Draft, error)
+	Delete(id DraftID) error
+}
+
+type DraftID string
+
+// Draft is a post the author is still writing. Drafts are kept apart from
+// posts and are only ever seen by their author.
+type Draft struct {
+	ID        DraftID   `bson:"_id"`
+	Author    ID        `bson:"author"`
+	Body      string    `bson:"body"`
+	InReplyTo PostID    `bson:"in_reply_to,omitempty"`
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+func NewDraft(author ID, body string, inReplyTo PostID) (*Draft, error) {
+	if len(body) > maxDraftLength {
+		return nil, ErrBodyTooLong
+	}
+
+	now := time.Now().UTC()
+	return &Draft{
+		ID:        DraftID(xid.New().String()),
+		Author:    author,
+		Body:      body,
+		InReplyTo: inReplyTo,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// Edit replaces the contents of the draft
+func (d *Draft) Edit(body string, inReplyTo PostID) error {
+	if len(body) > maxDraftLength {
+		return ErrBodyTooLong
+	}
+
+	d.Body = body
+	d.InReplyTo = inReplyTo
+	d.UpdatedAt = time.Now().UTC()
+	return nil
+}
@@ -0,0 +1,27 @@
+package blog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDraft_Edit(t *testing.T) {
+	_, err := NewDraft(nextID(), strings.Repeat("a", maxDraftLength+1), "")
+	assert.Equal(t, ErrBodyTooLong, err)
+
+	// drafts can be empty or longer than a post while being written
+	d, err := NewDraft(nextID(), "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, d.CreatedAt, d.UpdatedAt)
+
+	long := strings.Repeat("a", MaxBodyLength+1)
+	assert.Nil(t, d.Edit(long, "parent"))
+	assert.Equal(t, long, d.Body)
+	assert.Equal(t, PostID("parent"), d.InReplyTo)
+	assert.False(t, d.UpdatedAt.Before(d.CreatedAt))
+
+	assert.Equal(t, ErrBodyTooLong, d.Edit(strings.Repeat("a", maxDraftLength+1), ""))
+	assert.Equal(t, long, d.Body)
+}
@@ -0,0 +1,109 @@
+package blog
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EntityType tells apart the parts of a post body that link somewhere
+type EntityType string
+
+const (
+	EntityURL     EntityType = "url"
+	EntityMention EntityType = "mention"
+	EntityHashtag EntityType = "hashtag"
+)
+
+var (
+	// a URL starts with a scheme or www. and runs until the next space
+	urlPattern = regexp.MustCompile(`(?i)(?:^|[^\w/@.])((?:https?://|www\.)[^\s<>"]+)`)
+	// a mention is an @ followed by a username that doesn't start in the
+	// middle of a word, so that email addresses aren't taken for mentions
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@])(@\w{1,24})\b`)
+	// a tag is a # followed by letters, digits and underscores with at least
+	// one letter, that doesn't start in the middle of a word
+	tagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{M}\p{N}_&#])(#[\p{L}\p{M}\p{N}_]*\p{L}[\p{L}\p{M}\p{N}_]*)`)
+)
+
+// Entity is a URL, mention or hashtag found in a post body. Start and End are
+// the byte offsets of the entity in the body and RuneStart and RuneEnd the
+// same offsets counted in runes.
+type Entity struct {
+	Type EntityType
+	Text string
+	// Value is what the entity points at: the address of a URL, the username
+	// of a mention or the normalized name of a hashtag
+	Value              string
+	Start, End         int
+	RuneStart, RuneEnd int
+}
+
+// tokenize returns the entities in body in the order they appear. Entities
+// never split a grapheme cluster, and mentions and hashtags that are part of
+// a URL are left out.
+func tokenize(body string) []Entity {
+	boundaries := map[int]bool{}
+	for _, b := range graphemeBreaks(body) {
+		boundaries[b] = true
+	}
+
+	var entities []Entity
+	add := func(t EntityType, start, end int, value string) {
+		if !boundaries[start] || !boundaries[end] {
+			return
+		}
+		for _, e := range entities {
+			if start < e.End && e.Start < end {
+				return
+			}
+		}
+		entities = append(entities, Entity{Type: t, Text: body[start:end], Value: value, Start: start, End: end})
+	}
+
+	for _, m := range urlPattern.FindAllStringSubmatchIndex(body, -1) {
+		start, end := m[2], m[2]+len(trimURL(body[m[2]:m[3]]))
+		add(EntityURL, start, end, urlAddress(body[start:end]))
+	}
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(body, -1) {
+		add(EntityMention, m[2], m[3], body[m[2]+1:m[3]])
+	}
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(body, -1) {
+		add(EntityHashtag, m[2], m[3], normalizeTag(body[m[2]:m[3]]))
+	}
+
+	sort.Slice(entities, func(i, j int) bool {
+		return entities[i].Start < entities[j].Start
+	})
+
+	for i := range entities {
+		entities[i].RuneStart = runeOffset(body, entities[i].Start)
+		entities[i].RuneEnd = runeOffset(body, entities[i].End)
+	}
+	return entities
+}
+
+// trimURL drops the punctuation that ends the sentence a URL is in, along
+// with closing brackets that weren't opened within the URL
+func trimURL(u string) string {
+	for u != "" {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(".,:;!?'*", last) >= 0:
+			u = u[:len(u)-1]
+		case last == ')' && strings.Count(u, "(") < strings.Count(u, ")"):
+			u = u[:len(u)-1]
+		default:
+			return u
+		}
+	}
+	return u
+}
+
+// urlAddress adds the scheme to URLs written without one
+func urlAddress(u string) string {
+	if strings.HasPrefix(strings.ToLower(u), "www.") {
+		return "http://" + u
+	}
+	return u
+}
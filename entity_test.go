@@ -0,0 +1,48 @@
+package blog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		body string
+		want []Entity
+	}{
+		{body: "nothing to see", want: nil},
+		{
+			body: "héllo @user #Go",
+			want: []Entity{
+				{Type: EntityMention, Text: "@user", Value: "user", Start: 7, End: 12, RuneStart: 6, RuneEnd: 11},
+				{Type: EntityHashtag, Text: "#Go", Value: "go", Start: 13, End: 16, RuneStart: 12, RuneEnd: 15},
+			},
+		},
+		{
+			body: "see https://example.com/@user/#top.",
+			want: []Entity{
+				{Type: EntityURL, Text: "https://example.com/@user/#top", Value: "https://example.com/@user/#top",
+					Start: 4, End: 34, RuneStart: 4, RuneEnd: 34},
+			},
+		},
+		{
+			body: "(www.example.com/a_(b)) 👍🏽#tag",
+			want: []Entity{
+				{Type: EntityURL, Text: "www.example.com/a_(b)", Value: "http://www.example.com/a_(b)",
+					Start: 1, End: 22, RuneStart: 1, RuneEnd: 22},
+				{Type: EntityHashtag, Text: "#tag", Value: "tag", Start: 32, End: 36, RuneStart: 26, RuneEnd: 30},
+			},
+		},
+		{
+			body: "#café @bob́ me@example.com",
+			want: []Entity{
+				{Type: EntityHashtag, Text: "#café", Value: "café", Start: 0, End: 7, RuneStart: 0, RuneEnd: 6},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tokenize(tt.body), tt.body)
+	}
+}
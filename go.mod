@@ -8,6 +8,8 @@ require (
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/google/go-cmp v0.3.1 // indirect
 	github.com/julienschmidt/httprouter v1.3.0
+	github.com/kljensen/snowball v0.6.0
+	github.com/rivo/uniseg v0.2.0
 	github.com/rs/xid v1.2.1
 	github.com/smartystreets/goconvey v0.0.0-20190731233626-505e41936337
 	github.com/stretchr/testify v1.4.0
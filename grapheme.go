@@ -0,0 +1,31 @@
+package blog
+
+import (
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// graphemeBreaks returns the byte offsets at which the grapheme clusters of s
+// start, followed by len(s). Each cluster is what a reader sees as a single
+// character, such as a letter with its accents or an emoji sequence, as set
+// out by Unicode Standard Annex #29.
+func graphemeBreaks(s string) []int {
+	var breaks []int
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		start, _ := g.Positions()
+		breaks = append(breaks, start)
+	}
+	return append(breaks, len(s))
+}
+
+// graphemeCount returns the number of grapheme clusters in s
+func graphemeCount(s string) int {
+	return uniseg.GraphemeClusterCount(s)
+}
+
+// runeOffset converts a byte offset into s to an offset counted in runes
+func runeOffset(s string, byteOffset int) int {
+	return utf8.RuneCountInString(s[:byteOffset])
+}
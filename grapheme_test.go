@@ -0,0 +1,74 @@
+package blog
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphemeCount(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{s: "", want: 0},
+		{s: "abc", want: 3},
+		{s: "café", want: 4},
+		{s: "cafe\u0301", want: 4},
+		{s: "a\r\nb", want: 3},
+		{s: "한국어", want: 3},
+		{s: "\u1100\u1161\u11a8", want: 1},
+		{s: "👍🏽", want: 1},
+		{s: "\U0001F468\u200d\U0001F469\u200d\U0001F467", want: 1},
+		{s: "\u2764\ufe0f", want: 1},
+		{s: "🇳🇬🇬🇧🇺", want: 3},
+		{s: "नमस्ते", want: 4},
+		{s: "a\u200db", want: 2},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, graphemeCount(tt.s), tt.s)
+	}
+}
+
+// TestGraphemeBreaks_UnicodeData runs the break tests published with the
+// version of Unicode the segmenter implements
+func TestGraphemeBreaks_UnicodeData(t *testing.T) {
+	f, err := os.Open("testdata/GraphemeBreakTest.txt")
+	require.Nil(t, err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var s strings.Builder
+		var want []int
+		for _, field := range strings.Fields(line) {
+			switch field {
+			case "÷":
+				want = append(want, s.Len())
+			case "×":
+			default:
+				r, err := strconv.ParseUint(field, 16, 32)
+				require.Nil(t, err, "line %d", n)
+				s.WriteRune(rune(r))
+			}
+		}
+
+		assert.Equal(t, want, graphemeBreaks(s.String()), "line %d: %s", n, line)
+		assert.Equal(t, len(want)-1, graphemeCount(s.String()), "line %d: %s", n, line)
+	}
+	require.Nil(t, scanner.Err())
+}
@@ -6,8 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"strconv"
 	"strings"
 
 	"github.com/julienschmidt/httprouter"
@@ -39,7 +44,7 @@ func CreatePostHandler(svc Service) http.Handler {
 		}
 
 		req := request.(createPostRequest)
-		postID, err := svc.CreatePost(ID(userID), req.Body)
+		postID, err := svc.CreatePost(ID(userID), req)
 
 		if err != nil {
 			encodeError(err, w)
@@ -55,6 +60,664 @@ func CreatePostHandler(svc Service) http.Handler {
 	})
 }
 
+func GetPostHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id := getValueFromRequestParams(r, "id")
+		if id == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		viewer, _ := getUserIDFromContext(r.Context())
+		p, err := svc.GetPost(ID(viewer), PostID(id))
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		if err = json.NewEncoder(w).Encode(getPostResponse{Post: &p, URL: r.URL.String()}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+func GetThreadHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		if postID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		viewer, _ := getUserIDFromContext(r.Context())
+		thread, err := svc.GetThread(ID(viewer), PostID(postID))
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		if err = json.NewEncoder(w).Encode(thread); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+func EditPostHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		request, err := decodeEditPostRequest(r.Body)
+		if postID == "" || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		req := request.(editPostRequest)
+		if err := svc.EditPost(ID(id), PostID(postID), req.Body); err != nil {
+			encodeError(err, w)
+			return
+		}
+	})
+}
+
+func GetPostRevisionsHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		if postID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		viewer, _ := getUserIDFromContext(r.Context())
+		revisions, err := svc.GetPostRevisions(ID(viewer), PostID(postID))
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		if err = json.NewEncoder(w).Encode(revisions); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+func DeletePostHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		if postID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		if err := svc.DeletePost(ID(id), PostID(postID)); err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func CreateRepostHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		request, err := decodeRepostRequest(r.Body)
+		if postID == "" || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		req := request.(createPostRequest)
+		repostID, err := svc.Repost(ID(id), PostID(postID), req.Body)
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/v1/posts/%s", repostID))
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(&createPostResponse{ID: repostID}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+func RemoveRepostHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		if postID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		if err := svc.RemoveRepost(ID(id), PostID(postID)); err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func CreateReactionHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		request, err := decodeReactRequest(r.Body)
+		if postID == "" || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		req := request.(reactRequest)
+		if err := svc.React(ID(id), PostID(postID), req.Type); err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func RemoveReactionHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		t := getValueFromRequestParams(r, "type")
+		if postID == "" || t == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		if err := svc.Unreact(ID(id), PostID(postID), ReactionType(t)); err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func VoteHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		request, err := decodeVoteRequest(r.Body)
+		req := request.(voteRequest)
+		if postID == "" || err != nil || req.Choice == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		if err := svc.Vote(ID(id), PostID(postID), *req.Choice); err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func PinPostHandler(svc Service) http.Handler {
+	return handlePostAction(func(id ID, postID PostID) error {
+		return svc.PinPost(id, postID)
+	})
+}
+
+func UnpinPostHandler(svc Service) http.Handler {
+	return handlePostAction(func(id ID, postID PostID) error {
+		return svc.UnpinPost(id, postID)
+	})
+}
+
+func BookmarkPostHandler(svc Service) http.Handler {
+	return handlePostAction(func(id ID, postID PostID) error {
+		return svc.BookmarkPost(id, postID)
+	})
+}
+
+func RemoveBookmarkHandler(svc Service) http.Handler {
+	return handlePostAction(func(id ID, postID PostID) error {
+		return svc.RemoveBookmark(id, postID)
+	})
+}
+
+// handlePostAction runs f for the signed in user on the post in the request
+// path and replies with no content when it succeeds
+func handlePostAction(f func(id ID, postID PostID) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		if postID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		if err := f(ID(id), PostID(postID)); err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func GetBookmarksHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		page, err := getPageFromRequest(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		posts, err := svc.GetBookmarks(ID(id), page)
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		encodePostPage(w, r, posts)
+	})
+}
+
+func GetReactionsHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		if postID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		viewer, _ := getUserIDFromContext(r.Context())
+		reactors, err := svc.GetReactions(ID(viewer), PostID(postID))
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		if err = json.NewEncoder(w).Encode(reactors); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+func GetScheduledPostsHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		posts, err := svc.GetScheduledPosts(ID(id))
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		if err = json.NewEncoder(w).Encode(postsResponse{Posts: posts, URL: r.URL.String()}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+func ReschedulePostHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		request, err := decodeReschedulePostRequest(r.Body)
+		if postID == "" || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		req := request.(reschedulePostRequest)
+		if err := svc.ReschedulePost(ID(id), PostID(postID), req.PublishAt); err != nil {
+			encodeError(err, w)
+			return
+		}
+	})
+}
+
+func CancelScheduledPostHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		postID := getValueFromRequestParams(r, "id")
+		if postID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		if err := svc.CancelScheduledPost(ID(id), PostID(postID)); err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func CreateDraftHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		request, err := decodeDraftRequest(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		draftID, err := svc.CreateDraft(ID(id), request.(draftRequest))
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/v1/drafts/%s", draftID))
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(&createDraftResponse{ID: draftID}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+func UpdateDraftHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		draftID := getValueFromRequestParams(r, "id")
+		request, err := decodeDraftRequest(r.Body)
+		if draftID == "" || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		if err := svc.UpdateDraft(ID(id), DraftID(draftID), request.(draftRequest)); err != nil {
+			encodeError(err, w)
+			return
+		}
+	})
+}
+
+func GetDraftsHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		drafts, err := svc.GetDrafts(ID(id))
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		if err = json.NewEncoder(w).Encode(draftsResponse{Drafts: drafts, URL: r.URL.String()}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+func DeleteDraftHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		draftID := getValueFromRequestParams(r, "id")
+		if draftID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		if err := svc.DeleteDraft(ID(id), DraftID(draftID)); err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func PublishDraftHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		draftID := getValueFromRequestParams(r, "id")
+		if draftID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		postID, err := svc.PublishDraft(ID(id), DraftID(draftID))
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/v1/posts/%s", postID))
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(&createPostResponse{ID: postID}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+// UploadMediaHandler takes a multipart form with the image in its file field
+// and an optional alt_text field
+func UploadMediaHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// leave room for the rest of the form on top of the file
+		body := &limitedBody{ReadCloser: r.Body, max: MaxUploadSize + 1<<20}
+		r.Body = body
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			if body.tooLarge {
+				encodeError(ErrMediaTooLarge, w)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		data, err := ioutil.ReadAll(io.LimitReader(file, MaxUploadSize+1))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		res, err := svc.UploadMedia(ID(id), data, r.FormValue("alt_text"))
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		w.Header().Set("Location", res.URL)
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
+// limitedBody fails reads once more than max bytes of a request body have
+// been read, remembering that it did so the request can be turned away as too
+// large rather than malformed
+type limitedBody struct {
+	io.ReadCloser
+	max      int64
+	read     int64
+	tooLarge bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.tooLarge {
+		return 0, ErrMediaTooLarge
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.max {
+		b.tooLarge = true
+		return n, ErrMediaTooLarge
+	}
+	return n, err
+}
+
+func GetMediaHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := getValueFromRequestParams(r, "key")
+		if key == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		viewer, _ := getUserIDFromContext(r.Context())
+		media, err := svc.GetMedia(ID(viewer), key)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			encodeError(err, w)
+			return
+		}
+		defer media.Close()
+
+		// files never change once uploaded since every upload gets a new key,
+		// but only the media of public posts can be kept by shared caches
+		cache := "private"
+		if media.Public {
+			cache = "public"
+		}
+		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+		w.Header().Set("Cache-Control", cache+", max-age=31536000, immutable")
+		_, _ = io.Copy(w, media)
+	})
+}
+
 func GetProfileHandler(svc Service) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -65,7 +728,8 @@ func GetProfileHandler(svc Service) http.Handler {
 			return
 		}
 
-		p, err := svc.GetProfile(username)
+		viewer, _ := getUserIDFromContext(r.Context())
+		p, err := svc.GetProfile(ID(viewer), username)
 		if err != nil {
 			encodeError(err, w)
 			return
@@ -78,6 +742,174 @@ func GetProfileHandler(svc Service) http.Handler {
 	})
 }
 
+func GetTimelineHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := getUserIDFromContext(r.Context())
+		if !ok {
+			encodeError(ErrEmptyContext, w)
+			return
+		}
+
+		page, err := getPageFromRequest(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		tl, err := svc.GetTimeline(ID(id), page)
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		encodePostPage(w, r, tl)
+	})
+}
+
+func GetUserPostsHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		username := getValueFromRequestParams(r, "username")
+		page, err := getPageFromRequest(r)
+		if username == "" || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		viewer, _ := getUserIDFromContext(r.Context())
+		posts, err := svc.GetUserPosts(ID(viewer), username, page)
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		encodePostPage(w, r, posts)
+	})
+}
+
+func SearchPostsHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		page, err := getSearchPageFromRequest(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		viewer, _ := getUserIDFromContext(r.Context())
+		posts, err := svc.SearchPosts(ID(viewer), r.URL.Query().Get("q"), page)
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		encodePage(w, r, posts, "offset", "offset")
+	})
+}
+
+func GetUserMentionsHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		username := getValueFromRequestParams(r, "username")
+		page, err := getPageFromRequest(r)
+		if username == "" || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		viewer, _ := getUserIDFromContext(r.Context())
+		posts, err := svc.GetUserMentions(ID(viewer), username, page)
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		encodePostPage(w, r, posts)
+	})
+}
+
+func GetTagPostsHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		tag := getValueFromRequestParams(r, "tag")
+		page, err := getPageFromRequest(r)
+		if tag == "" || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		viewer, _ := getUserIDFromContext(r.Context())
+		posts, err := svc.GetTagPosts(ID(viewer), tag, page)
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		encodePostPage(w, r, posts)
+	})
+}
+
+func GetExploreHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		page, err := getPageFromRequest(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var engaged bool
+		if e := r.URL.Query().Get("engaged"); e != "" {
+			if engaged, err = strconv.ParseBool(e); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		viewer, _ := getUserIDFromContext(r.Context())
+		posts, err := svc.GetExplore(ID(viewer), engaged, page)
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		encodePostPage(w, r, posts)
+	})
+}
+
+func GetTrendsHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		q := r.URL.Query()
+		limit := 0
+		if l := q.Get("limit"); l != "" {
+			var err error
+			if limit, err = strconv.Atoi(l); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		trends, err := svc.GetTrends(TrendWindow(q.Get("window")), limit)
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		if err = json.NewEncoder(w).Encode(trends); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
 func EditProfileHandler(svc Service) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -143,6 +975,32 @@ func GetUserFollowersHandler(svc Service) http.Handler {
 	})
 }
 
+func SearchUsersHandler(svc Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		q := r.URL.Query()
+		limit := 0
+		if l := q.Get("limit"); l != "" {
+			var err error
+			if limit, err = strconv.Atoi(l); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		users, err := svc.SearchUsers(q.Get("q"), limit)
+		if err != nil {
+			encodeError(err, w)
+			return
+		}
+
+		if err = json.NewEncoder(w).Encode(users); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
+
 type gFunc func(string) ([]UserInfo, error)
 
 func getRelationships(w http.ResponseWriter, r *http.Request, f gFunc) {
@@ -200,6 +1058,22 @@ func RequireAuth(f http.Handler) http.Handler {
 	})
 }
 
+// OptionalAuth lets anonymous requests through to routes anyone can read,
+// while requests with a valid token carry the user's id so that handlers can
+// tailor their responses to the viewer
+func OptionalAuth(f http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenStr := getTokenStrFromRequest(r)
+		if token, err := parseTokenStr(tokenStr); err == nil {
+			if _, ok := token.Claims.(jwt.MapClaims)["sub"].(string); ok {
+				r = r.WithContext(addClaimsToCtx(r.Context(), token))
+			}
+		}
+
+		f.ServeHTTP(w, r)
+	})
+}
+
 func getRelationshipRequestParams(r *http.Request, w http.ResponseWriter) (string, string, bool) {
 	username := getValueFromRequestParams(r, "username")
 	if username == "" {
@@ -236,6 +1110,69 @@ func getValueFromRequestParams(r *http.Request, name string) string {
 	return strings.TrimSpace(params.ByName(name))
 }
 
+func getSearchPageFromRequest(r *http.Request) (SearchPage, error) {
+	q := r.URL.Query()
+	page := SearchPage{Order: SearchOrder(q.Get("sort"))}
+
+	for key, v := range map[string]*int{"limit": &page.Limit, "offset": &page.Offset} {
+		if s := q.Get(key); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return SearchPage{}, err
+			}
+			*v = n
+		}
+	}
+	return page, nil
+}
+
+func getPageFromRequest(r *http.Request) (Page, error) {
+	q := r.URL.Query()
+	page := Page{Before: q.Get("before"), After: q.Get("after")}
+
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Limit = limit
+	}
+	return page, nil
+}
+
+func encodePostPage(w http.ResponseWriter, r *http.Request, pp postPage) {
+	encodePage(w, r, pp, "before", "after")
+}
+
+// encodePage writes the posts of the page with links to the pages either side
+// of it, passing the cursors in the nextKey and prevKey query parameters
+func encodePage(w http.ResponseWriter, r *http.Request, pp postPage, nextKey, prevKey string) {
+	res := postsResponse{Posts: pp.Posts, URL: r.URL.String()}
+	if pp.Next != "" {
+		res.Next = pageURL(r.URL, nextKey, pp.Next)
+	}
+	if pp.Prev != "" {
+		res.Prev = pageURL(r.URL, prevKey, pp.Prev)
+	}
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// pageURL returns u with its cursor replaced by the given one, keeping the
+// other query parameters such as limit.
+func pageURL(u *url.URL, key, cursor string) string {
+	q := u.Query()
+	q.Del("before")
+	q.Del("after")
+	q.Set(key, cursor)
+
+	l := *u
+	l.RawQuery = q.Encode()
+	return l.String()
+}
+
 func getUserIDFromContext(ctx context.Context) (id string, ok bool) {
 	id, ok = ctx.Value(idKey).(string)
 	return
@@ -259,14 +1196,26 @@ func encodeError(err error, w http.ResponseWriter) {
 	switch err {
 	case ErrInvalidID:
 		w.WriteHeader(http.StatusUnauthorized)
-	case ErrCantFollowSelf, ErrCantUnFollowSelf:
+	case ErrCantFollowSelf, ErrCantUnFollowSelf, ErrNotPostAuthor, ErrCantRepostOwn, ErrCantRepostPrivate:
 		w.WriteHeader(http.StatusForbidden)
-	case ErrNotFound:
+	case ErrNotFound, ErrPostNotFound, ErrBlobNotFound, ErrDraftNotFound:
 		w.WriteHeader(http.StatusNotFound)
-	case ErrExistingUsername, ErrAlreadyFollowing, ErrNotFollowing:
+	case ErrExistingUsername, ErrAlreadyFollowing, ErrNotFollowing, ErrAlreadyReposted, ErrNotReposted,
+		ErrAlreadyReacted, ErrNotReacted, ErrNotScheduled, ErrAlreadyVoted, ErrPollClosed,
+		ErrAlreadyPinned, ErrNotPinned, ErrAlreadyBookmarked, ErrNotBookmarked:
 		w.WriteHeader(http.StatusConflict)
-	case ErrEmptyBody, ErrInvalidUsername, ErrBioTooLong:
+	case ErrInvalidCursor:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrEmptyBody, ErrBodyTooLong, ErrInvalidUsername, ErrBioTooLong, ErrNoParentPost, ErrCantEditRepost, ErrInvalidReaction,
+		ErrInvalidTag, ErrInvalidAttachment, ErrTooManyAttachments, ErrAltTextTooLong, ErrInvalidPublishTime,
+		ErrInvalidVisibility, ErrInvalidPoll, ErrInvalidPollExpiry, ErrNoPoll, ErrInvalidChoice,
+		ErrContentWarningTooLong, ErrTooManyPinned, ErrEmptySearch, ErrInvalidSearchDate, ErrInvalidSearchOrder,
+		ErrInvalidTrendWindow:
 		w.WriteHeader(http.StatusUnprocessableEntity)
+	case ErrMediaTooLarge:
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	case ErrUnsupportedMedia:
+		w.WriteHeader(http.StatusUnsupportedMediaType)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -285,6 +1234,56 @@ func decodeCreatePostRequest(body io.ReadCloser) (interface{}, error) {
 	return req, nil
 }
 
+// decodeRepostRequest decodes the optional commentary of a quote post. An empty
+// body is a plain repost.
+func decodeRepostRequest(body io.ReadCloser) (interface{}, error) {
+	req := createPostRequest{}
+	if err := json.NewDecoder(body).Decode(&req); err != nil && err != io.EOF {
+		return createPostRequest{}, err
+	}
+	return req, nil
+}
+
+func decodeReactRequest(body io.ReadCloser) (interface{}, error) {
+	req := reactRequest{}
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return reactRequest{}, err
+	}
+	return req, nil
+}
+
+func decodeVoteRequest(body io.ReadCloser) (interface{}, error) {
+	req := voteRequest{}
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return voteRequest{}, err
+	}
+	return req, nil
+}
+
+func decodeEditPostRequest(body io.ReadCloser) (interface{}, error) {
+	req := editPostRequest{}
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return editPostRequest{}, err
+	}
+	return req, nil
+}
+
+func decodeReschedulePostRequest(body io.ReadCloser) (interface{}, error) {
+	req := reschedulePostRequest{}
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return reschedulePostRequest{}, err
+	}
+	return req, nil
+}
+
+func decodeDraftRequest(body io.ReadCloser) (interface{}, error) {
+	req := draftRequest{}
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return draftRequest{}, err
+	}
+	return req, nil
+}
+
 func decodeEditProfileRequest(body io.ReadCloser) (interface{}, error) {
 	req := editProfileRequest{}
 	if err := json.NewDecoder(body).Decode(&req); err != nil {
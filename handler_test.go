@@ -1,11 +1,14 @@
 package blog
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image/png"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os/exec"
@@ -122,6 +125,7 @@ func (hs *HandlerTestSuite) TestCreatePostHandler() {
 	uid := string(hs.userID)
 	b := `{"body": ""}`
 	body := `{"body": "i love my wife :)"}`
+	long := `{"body": "` + strings.Repeat("a", MaxBodyLength+1) + `"}`
 
 	tests := []struct {
 		req, userID     string
@@ -135,6 +139,9 @@ func (hs *HandlerTestSuite) TestCreatePostHandler() {
 		{req: b, wantCode: http.StatusUnauthorized, wantErr: ErrInvalidID, withCtx: true},
 		{req: b, userID: "puoiwoerigp", wantCode: http.StatusUnauthorized, wantErr: ErrInvalidID, withCtx: true},
 		{req: b, userID: uid, wantCode: http.StatusUnprocessableEntity, wantErr: ErrEmptyBody, withCtx: true},
+		{req: long, userID: uid, wantCode: http.StatusUnprocessableEntity, wantErr: ErrBodyTooLong, withCtx: true},
+		{req: `{"body": "reply", "in_reply_to": "invalid"}`, userID: uid, wantCode: http.StatusUnprocessableEntity, wantErr: ErrNoParentPost, withCtx: true},
+		{req: `{"body": "secret", "visibility": "friends"}`, userID: uid, wantCode: http.StatusUnprocessableEntity, wantErr: ErrInvalidVisibility, withCtx: true},
 		{req: body, userID: uid, wantCode: http.StatusCreated, wantErr: errNil, wantID: true, wantLoc: "/v1/posts/", withCtx: true},
 	}
 
@@ -166,14 +173,789 @@ func (hs *HandlerTestSuite) TestCreatePostHandler() {
 
 }
 
+func (hs *HandlerTestSuite) TestGetPostHandler() {
+	router := httprouter.New()
+	router.Handler(http.MethodPost, "/v1/posts", CreatePostHandler(hs.svc))
+	router.Handler(http.MethodGet, "/v1/posts/:id", GetPostHandler(hs.svc))
+
+	r, _ := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(`{"body": "find me"}`))
+	r = setIDInRequestContext(r, string(hs.userID))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	location := w.Header().Get("Location")
+	require.NotEmpty(hs.T(), location)
+
+	private, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "followers only", Visibility: VisibilityFollowers})
+
+	tests := []struct {
+		url      string
+		wantCode int
+		wantErr  error
+		wantBody string
+		wantUN   string
+	}{
+		{url: "/v1/posts/%20", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: "/v1/posts/invalid", wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+		{url: "/v1/posts/" + string(nextID()), wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+		{url: location, wantCode: http.StatusOK, wantErr: errNil, wantBody: "find me", wantUN: hs.username},
+		{url: "/v1/posts/" + string(private), wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Post postResponse `json:"post"`
+			URL  string       `json:"url"`
+			Err  string       `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.Equal(hs.T(), tt.wantBody, res.Post.Body)
+		assert.Equal(hs.T(), tt.wantUN, res.Post.Author.Username)
+	}
+}
+
+func (hs *HandlerTestSuite) TestGetThreadHandler() {
+	root, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "root"})
+	reply, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "reply", InReplyTo: root})
+	nested, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "nested", InReplyTo: reply})
+
+	tests := []struct {
+		postID        string
+		wantCode      int
+		wantErr       error
+		wantAncestors int
+		wantReplyID   PostID
+	}{
+		{postID: "%20", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{postID: string(nextID()), wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+		{postID: string(root), wantCode: http.StatusOK, wantErr: errNil, wantReplyID: reply},
+		{postID: string(reply), wantCode: http.StatusOK, wantErr: errNil, wantAncestors: 1, wantReplyID: nested},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/v1/posts/"+tt.postID+"/thread", nil)
+
+		router := httprouter.New()
+		router.Handler(http.MethodGet, "/v1/posts/:id/thread", GetThreadHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Ancestors []postResponse `json:"ancestors"`
+			Post      struct {
+				ID         PostID `json:"id"`
+				ReplyCount int    `json:"reply_count"`
+				Replies    []struct {
+					ID PostID `json:"id"`
+				} `json:"replies"`
+			} `json:"post"`
+			Err string `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.Equal(hs.T(), tt.wantAncestors, len(res.Ancestors))
+
+		if tt.wantReplyID != "" {
+			assert.Equal(hs.T(), 1, res.Post.ReplyCount)
+			assert.Equal(hs.T(), tt.wantReplyID, res.Post.Replies[0].ID)
+		}
+	}
+}
+
+func (hs *HandlerTestSuite) TestEditPostHandler() {
+	other := DuplicateUser(hs.users, *hs.user, "notEditor")
+	postID, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "tpyo"})
+	pid, uid := string(postID), string(hs.userID)
+
+	tests := []struct {
+		postID, id, req string
+		withCtx         bool
+		wantCode        int
+		wantErr         error
+		wantRevsLen     int
+	}{
+		{postID: pid, req: `invalid`, wantCode: http.StatusBadRequest, wantErr: errNil},
+		{postID: pid, req: `{"body": "typo"}`, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{postID: pid, id: "invalid", req: `{"body": "typo"}`, withCtx: true, wantCode: http.StatusUnauthorized, wantErr: ErrInvalidID},
+		{postID: string(nextID()), id: uid, req: `{"body": "typo"}`, withCtx: true, wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+		{postID: pid, id: string(other.ID), req: `{"body": "typo"}`, withCtx: true, wantCode: http.StatusForbidden, wantErr: ErrNotPostAuthor},
+		{postID: pid, id: uid, req: `{"body": ""}`, withCtx: true, wantCode: http.StatusUnprocessableEntity, wantErr: ErrEmptyBody},
+		{postID: pid, id: uid, req: `{"body": "typo"}`, withCtx: true, wantCode: http.StatusOK, wantErr: errNil, wantRevsLen: 1},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodPatch, "/v1/posts/"+tt.postID, strings.NewReader(tt.req))
+		r2, _ := http.NewRequest(http.MethodGet, "/v1/posts/"+pid+"/revisions", nil)
+
+		if tt.withCtx {
+			r = setIDInRequestContext(r, tt.id)
+		}
+
+		router := httprouter.New()
+		router.Handler(http.MethodPatch, "/v1/posts/:id", EditPostHandler(hs.svc))
+		router.Handler(http.MethodGet, "/v1/posts/:id/revisions", GetPostRevisionsHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		w2 := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		router.ServeHTTP(w2, r2)
+
+		var res struct {
+			Err string `json:"error,omitempty"`
+		}
+		var revs []revisionResponse
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+		_ = json.NewDecoder(w2.Body).Decode(&revs)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.Equal(hs.T(), http.StatusOK, w2.Code)
+		assert.Equal(hs.T(), tt.wantRevsLen, len(revs))
+	}
+
+	p, _ := hs.svc.GetPost("", postID)
+	assert.Equal(hs.T(), "typo", p.Body)
+	assert.NotNil(hs.T(), p.EditedAt)
+}
+
+func (hs *HandlerTestSuite) TestDeletePostHandler() {
+	other := DuplicateUser(hs.users, *hs.user, "notAuthor")
+	postID, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "delete me"})
+	uid := string(hs.userID)
+
+	tests := []struct {
+		postID, id string
+		withCtx    bool
+		wantCode   int
+		wantErr    error
+	}{
+		{postID: "%20", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{postID: string(postID), wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{postID: string(postID), id: "invalid", withCtx: true, wantCode: http.StatusUnauthorized, wantErr: ErrInvalidID},
+		{postID: string(nextID()), id: uid, withCtx: true, wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+		{postID: string(postID), id: string(other.ID), withCtx: true, wantCode: http.StatusForbidden, wantErr: ErrNotPostAuthor},
+		{postID: string(postID), id: uid, withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil},
+		{postID: string(postID), id: uid, withCtx: true, wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodDelete, "/v1/posts/"+tt.postID, nil)
+
+		if tt.withCtx {
+			r = setIDInRequestContext(r, tt.id)
+		}
+
+		router := httprouter.New()
+		router.Handler(http.MethodDelete, "/v1/posts/:id", DeletePostHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Err string `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+	}
+
+	_, err := hs.svc.GetPost("", postID)
+	assert.Equal(hs.T(), ErrPostNotFound, err)
+}
+
+func (hs *HandlerTestSuite) TestRepostHandlers() {
+	author := DuplicateUser(hs.users, *hs.user, "repostedAuthor")
+	postID, _ := hs.svc.CreatePost(author.ID, createPostRequest{Body: "share me"})
+	own, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "mine"})
+	pid, uid := string(postID), string(hs.userID)
+
+	tests := []struct {
+		method, postID, req string
+		withCtx             bool
+		wantCode            int
+		wantErr             error
+		wantLoc             string
+	}{
+		{method: http.MethodPost, postID: pid, req: `invalid`, wantCode: http.StatusBadRequest, wantErr: errNil},
+		{method: http.MethodPost, postID: pid, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{method: http.MethodPost, postID: string(nextID()), withCtx: true, wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+		{method: http.MethodPost, postID: string(own), withCtx: true, wantCode: http.StatusForbidden, wantErr: ErrCantRepostOwn},
+		{method: http.MethodPost, postID: pid, withCtx: true, wantCode: http.StatusCreated, wantErr: errNil, wantLoc: "/v1/posts/"},
+		{method: http.MethodPost, postID: pid, withCtx: true, wantCode: http.StatusConflict, wantErr: ErrAlreadyReposted},
+		{method: http.MethodPost, postID: pid, req: `{"body": "quoted"}`, withCtx: true, wantCode: http.StatusCreated, wantErr: errNil, wantLoc: "/v1/posts/"},
+		{method: http.MethodDelete, postID: pid, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{method: http.MethodDelete, postID: pid, withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil},
+		{method: http.MethodDelete, postID: pid, withCtx: true, wantCode: http.StatusConflict, wantErr: ErrNotReposted},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(tt.method, "/v1/posts/"+tt.postID+"/reposts", strings.NewReader(tt.req))
+
+		if tt.withCtx {
+			r = setIDInRequestContext(r, uid)
+		}
+
+		router := httprouter.New()
+		router.Handler(http.MethodPost, "/v1/posts/:id/reposts", CreateRepostHandler(hs.svc))
+		router.Handler(http.MethodDelete, "/v1/posts/:id/reposts", RemoveRepostHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Err string `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.True(hs.T(), strings.HasPrefix(w.Header().Get("Location"), tt.wantLoc))
+	}
+
+	p, _ := hs.svc.GetPost("", postID)
+	assert.Equal(hs.T(), 1, p.RepostCount)
+}
+
+func (hs *HandlerTestSuite) TestReactionHandlers() {
+	postID, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "react here"})
+	pid, uid := string(postID), string(hs.userID)
+	url := "/v1/posts/" + pid + "/reactions"
+
+	tests := []struct {
+		method, url, req string
+		withCtx          bool
+		wantCode         int
+		wantErr          error
+		wantReactors     int
+	}{
+		{method: http.MethodPost, url: url, req: `invalid`, wantCode: http.StatusBadRequest, wantErr: errNil},
+		{method: http.MethodPost, url: url, req: `{"type": "like"}`, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{method: http.MethodPost, url: "/v1/posts/" + string(nextID()) + "/reactions", req: `{"type": "like"}`, withCtx: true, wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+		{method: http.MethodPost, url: url, req: `{"type": "meh"}`, withCtx: true, wantCode: http.StatusUnprocessableEntity, wantErr: ErrInvalidReaction},
+		{method: http.MethodPost, url: url, req: `{"type": "like"}`, withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil, wantReactors: 1},
+		{method: http.MethodPost, url: url, req: `{"type": "like"}`, withCtx: true, wantCode: http.StatusConflict, wantErr: ErrAlreadyReacted, wantReactors: 1},
+		{method: http.MethodPost, url: url, req: `{"type": "wow"}`, withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil, wantReactors: 2},
+		{method: http.MethodDelete, url: url + "/like", wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext, wantReactors: 2},
+		{method: http.MethodDelete, url: url + "/meh", withCtx: true, wantCode: http.StatusUnprocessableEntity, wantErr: ErrInvalidReaction, wantReactors: 2},
+		{method: http.MethodDelete, url: url + "/like", withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil, wantReactors: 1},
+		{method: http.MethodDelete, url: url + "/like", withCtx: true, wantCode: http.StatusConflict, wantErr: ErrNotReacted, wantReactors: 1},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.req))
+		r2, _ := http.NewRequest(http.MethodGet, url, nil)
+
+		if tt.withCtx {
+			r = setIDInRequestContext(r, uid)
+		}
+
+		router := httprouter.New()
+		router.Handler(http.MethodPost, "/v1/posts/:id/reactions", CreateReactionHandler(hs.svc))
+		router.Handler(http.MethodGet, "/v1/posts/:id/reactions", GetReactionsHandler(hs.svc))
+		router.Handler(http.MethodDelete, "/v1/posts/:id/reactions/:type", RemoveReactionHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		w2 := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		router.ServeHTTP(w2, r2)
+
+		var res struct {
+			Err string `json:"error,omitempty"`
+		}
+		var reactors []reactorResponse
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+		_ = json.NewDecoder(w2.Body).Decode(&reactors)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.Equal(hs.T(), tt.wantReactors, len(reactors))
+	}
+
+	p, _ := hs.svc.GetPost(hs.userID, postID)
+	assert.Equal(hs.T(), map[ReactionType]int{ReactionWow: 1}, p.Reactions)
+	assert.Equal(hs.T(), []ReactionType{ReactionWow}, p.Reacted)
+}
+
+func (hs *HandlerTestSuite) TestScheduledPostHandlers() {
+	other := DuplicateUser(hs.users, *hs.user, "notScheduler")
+	publishAt := time.Now().Add(time.Hour).UTC()
+	postID, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "scheduled", PublishAt: publishAt})
+	url, uid := "/v1/scheduled/"+string(postID), string(hs.userID)
+	later := publishAt.Add(time.Hour).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		method, url, id, req string
+		withCtx              bool
+		wantCode             int
+		wantErr              error
+		wantScheduled        int
+	}{
+		{method: http.MethodPost, url: "/v1/posts", id: uid, req: `{"body": "b", "publish_at": "` + past + `"}`, withCtx: true, wantCode: http.StatusUnprocessableEntity, wantErr: ErrInvalidPublishTime, wantScheduled: 1},
+		{method: http.MethodPatch, url: url, id: uid, req: `invalid`, withCtx: true, wantCode: http.StatusBadRequest, wantErr: errNil, wantScheduled: 1},
+		{method: http.MethodPatch, url: url, req: `{"publish_at": "` + later + `"}`, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext, wantScheduled: 1},
+		{method: http.MethodPatch, url: url, id: string(other.ID), req: `{"publish_at": "` + later + `"}`, withCtx: true, wantCode: http.StatusForbidden, wantErr: ErrNotPostAuthor, wantScheduled: 1},
+		{method: http.MethodPatch, url: url, id: uid, req: `{"publish_at": "` + past + `"}`, withCtx: true, wantCode: http.StatusUnprocessableEntity, wantErr: ErrInvalidPublishTime, wantScheduled: 1},
+		{method: http.MethodPatch, url: url, id: uid, req: `{"publish_at": "` + later + `"}`, withCtx: true, wantCode: http.StatusOK, wantErr: errNil, wantScheduled: 1},
+		{method: http.MethodDelete, url: url, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext, wantScheduled: 1},
+		{method: http.MethodDelete, url: url, id: uid, withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil},
+		{method: http.MethodDelete, url: url, id: uid, withCtx: true, wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.req))
+		r2, _ := http.NewRequest(http.MethodGet, "/v1/scheduled", nil)
+
+		if tt.withCtx {
+			r = setIDInRequestContext(r, tt.id)
+		}
+		r2 = setIDInRequestContext(r2, uid)
+
+		router := httprouter.New()
+		router.Handler(http.MethodPost, "/v1/posts", CreatePostHandler(hs.svc))
+		router.Handler(http.MethodGet, "/v1/scheduled", GetScheduledPostsHandler(hs.svc))
+		router.Handler(http.MethodPatch, "/v1/scheduled/:id", ReschedulePostHandler(hs.svc))
+		router.Handler(http.MethodDelete, "/v1/scheduled/:id", CancelScheduledPostHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		w2 := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		router.ServeHTTP(w2, r2)
+
+		var res struct {
+			Err string `json:"error,omitempty"`
+		}
+		var scheduled postsResponse
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+		_ = json.NewDecoder(w2.Body).Decode(&scheduled)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.Equal(hs.T(), http.StatusOK, w2.Code)
+		assert.Equal(hs.T(), tt.wantScheduled, len(scheduled.Posts))
+		if len(scheduled.Posts) > 0 && tt.wantCode == http.StatusOK {
+			assert.Equal(hs.T(), later, scheduled.Posts[0].PublishAt.Format(time.RFC3339))
+		}
+	}
+}
+
+func (hs *HandlerTestSuite) TestDraftHandlers() {
+	other := DuplicateUser(hs.users, *hs.user, "notDrafter")
+	draftID, _ := hs.svc.CreateDraft(hs.userID, draftRequest{Body: "draft"})
+	url, uid := "/v1/drafts/"+string(draftID), string(hs.userID)
+
+	tests := []struct {
+		method, url, id, req string
+		withCtx              bool
+		wantCode             int
+		wantErr              error
+		wantDrafts           int
+	}{
+		{method: http.MethodPost, url: "/v1/drafts", id: uid, req: `invalid`, withCtx: true, wantCode: http.StatusBadRequest, wantErr: errNil, wantDrafts: 1},
+		{method: http.MethodPost, url: "/v1/drafts", req: `{"body": "b"}`, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext, wantDrafts: 1},
+		{method: http.MethodPost, url: "/v1/drafts", id: uid, req: `{"body": ""}`, withCtx: true, wantCode: http.StatusCreated, wantErr: errNil, wantDrafts: 2},
+		{method: http.MethodPatch, url: url, id: uid, req: `invalid`, withCtx: true, wantCode: http.StatusBadRequest, wantErr: errNil, wantDrafts: 2},
+		{method: http.MethodPatch, url: url, id: string(other.ID), req: `{"body": "b"}`, withCtx: true, wantCode: http.StatusNotFound, wantErr: ErrDraftNotFound, wantDrafts: 2},
+		{method: http.MethodPatch, url: url, id: uid, req: `{"body": ""}`, withCtx: true, wantCode: http.StatusOK, wantErr: errNil, wantDrafts: 2},
+		{method: http.MethodPost, url: url + "/publish", id: uid, withCtx: true, wantCode: http.StatusUnprocessableEntity, wantErr: ErrEmptyBody, wantDrafts: 2},
+		{method: http.MethodPatch, url: url, id: uid, req: `{"body": "ready"}`, withCtx: true, wantCode: http.StatusOK, wantErr: errNil, wantDrafts: 2},
+		{method: http.MethodPost, url: url + "/publish", wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext, wantDrafts: 2},
+		{method: http.MethodPost, url: url + "/publish", id: uid, withCtx: true, wantCode: http.StatusCreated, wantErr: errNil, wantDrafts: 1},
+		{method: http.MethodDelete, url: url, id: uid, withCtx: true, wantCode: http.StatusNotFound, wantErr: ErrDraftNotFound, wantDrafts: 1},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.req))
+		r2, _ := http.NewRequest(http.MethodGet, "/v1/drafts", nil)
+
+		if tt.withCtx {
+			r = setIDInRequestContext(r, tt.id)
+		}
+		r2 = setIDInRequestContext(r2, uid)
+
+		router := httprouter.New()
+		router.Handler(http.MethodPost, "/v1/drafts", CreateDraftHandler(hs.svc))
+		router.Handler(http.MethodGet, "/v1/drafts", GetDraftsHandler(hs.svc))
+		router.Handler(http.MethodPatch, "/v1/drafts/:id", UpdateDraftHandler(hs.svc))
+		router.Handler(http.MethodDelete, "/v1/drafts/:id", DeleteDraftHandler(hs.svc))
+		router.Handler(http.MethodPost, "/v1/drafts/:id/publish", PublishDraftHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		w2 := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		router.ServeHTTP(w2, r2)
+
+		var res struct {
+			Err string `json:"error,omitempty"`
+		}
+		var drafts draftsResponse
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+		_ = json.NewDecoder(w2.Body).Decode(&drafts)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.Equal(hs.T(), http.StatusOK, w2.Code)
+		assert.Equal(hs.T(), tt.wantDrafts, len(drafts.Drafts))
+		if tt.wantCode == http.StatusCreated {
+			assert.NotEmpty(hs.T(), w.Header().Get("Location"))
+		}
+	}
+
+	// clean up the draft left behind
+	drafts, _ := hs.svc.GetDrafts(hs.userID)
+	for _, d := range drafts {
+		_ = hs.svc.DeleteDraft(hs.userID, d.ID)
+	}
+}
+
+func (hs *HandlerTestSuite) TestVoteHandler() {
+	poll := &pollRequest{Options: []string{"yes", "no"}, ExpiresIn: 3600}
+	postID, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "vote", Poll: poll})
+	plain, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "no poll"})
+	url, uid := "/v1/posts/"+string(postID)+"/votes", string(hs.userID)
+
+	tests := []struct {
+		url, req string
+		withCtx  bool
+		wantCode int
+		wantErr  error
+	}{
+		{url: url, req: `invalid`, withCtx: true, wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: url, req: `{}`, withCtx: true, wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: url, req: `{"choice": 0}`, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{url: "/v1/posts/" + string(plain) + "/votes", req: `{"choice": 0}`, withCtx: true, wantCode: http.StatusUnprocessableEntity, wantErr: ErrNoPoll},
+		{url: url, req: `{"choice": 5}`, withCtx: true, wantCode: http.StatusUnprocessableEntity, wantErr: ErrInvalidChoice},
+		{url: url, req: `{"choice": 0}`, withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil},
+		{url: url, req: `{"choice": 1}`, withCtx: true, wantCode: http.StatusConflict, wantErr: ErrAlreadyVoted},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.req))
+
+		if tt.withCtx {
+			r = setIDInRequestContext(r, uid)
+		}
+
+		router := httprouter.New()
+		router.Handler(http.MethodPost, "/v1/posts/:id/votes", VoteHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Err string `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+	}
+
+	p, _ := hs.svc.GetPost(hs.userID, postID)
+	assert.Equal(hs.T(), 0, *p.Poll.Choice)
+	assert.Equal(hs.T(), 1, *p.Poll.Options[0].Votes)
+}
+
+func (hs *HandlerTestSuite) TestPinHandlers() {
+	postID, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "pin me"})
+	url, uid := "/v1/posts/"+string(postID)+"/pin", string(hs.userID)
+
+	tests := []struct {
+		method   string
+		withCtx  bool
+		wantCode int
+		wantErr  error
+	}{
+		{method: http.MethodPost, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{method: http.MethodPost, withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil},
+		{method: http.MethodPost, withCtx: true, wantCode: http.StatusConflict, wantErr: ErrAlreadyPinned},
+		{method: http.MethodDelete, withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil},
+		{method: http.MethodDelete, withCtx: true, wantCode: http.StatusConflict, wantErr: ErrNotPinned},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(tt.method, url, nil)
+
+		if tt.withCtx {
+			r = setIDInRequestContext(r, uid)
+		}
+
+		router := httprouter.New()
+		router.Handler(http.MethodPost, "/v1/posts/:id/pin", PinPostHandler(hs.svc))
+		router.Handler(http.MethodDelete, "/v1/posts/:id/pin", UnpinPostHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Err string `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+	}
+}
+
+func (hs *HandlerTestSuite) TestBookmarkHandlers() {
+	postID, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "save me"})
+	url, uid := "/v1/posts/"+string(postID)+"/bookmark", string(hs.userID)
+
+	tests := []struct {
+		method, url string
+		withCtx     bool
+		wantCode    int
+		wantErr     error
+	}{
+		{method: http.MethodPost, url: url, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{method: http.MethodPost, url: "/v1/posts/missing/bookmark", withCtx: true, wantCode: http.StatusNotFound, wantErr: ErrPostNotFound},
+		{method: http.MethodPost, url: url, withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil},
+		{method: http.MethodPost, url: url, withCtx: true, wantCode: http.StatusConflict, wantErr: ErrAlreadyBookmarked},
+		{method: http.MethodGet, url: "/v1/bookmarks", wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{method: http.MethodGet, url: "/v1/bookmarks?limit=x", withCtx: true, wantCode: http.StatusBadRequest, wantErr: errNil},
+		{method: http.MethodGet, url: "/v1/bookmarks?before=x", withCtx: true, wantCode: http.StatusBadRequest, wantErr: ErrInvalidCursor},
+		{method: http.MethodGet, url: "/v1/bookmarks", withCtx: true, wantCode: http.StatusOK, wantErr: errNil},
+		{method: http.MethodDelete, url: url, withCtx: true, wantCode: http.StatusNoContent, wantErr: errNil},
+		{method: http.MethodDelete, url: url, withCtx: true, wantCode: http.StatusConflict, wantErr: ErrNotBookmarked},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(tt.method, tt.url, nil)
+
+		if tt.withCtx {
+			r = setIDInRequestContext(r, uid)
+		}
+
+		router := httprouter.New()
+		router.Handler(http.MethodPost, "/v1/posts/:id/bookmark", BookmarkPostHandler(hs.svc))
+		router.Handler(http.MethodDelete, "/v1/posts/:id/bookmark", RemoveBookmarkHandler(hs.svc))
+		router.Handler(http.MethodGet, "/v1/bookmarks", GetBookmarksHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Err   string         `json:"error,omitempty"`
+			Posts []postResponse `json:"posts"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		if tt.method == http.MethodGet && w.Code == http.StatusOK {
+			assert.Len(hs.T(), res.Posts, 1)
+			assert.Equal(hs.T(), postID, res.Posts[0].ID)
+		}
+	}
+}
+
+func (hs *HandlerTestSuite) TestSearchPostsHandler() {
+	var ids []PostID
+	for _, body := range []string{"searching for a handler", "the handler searched"} {
+		id, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: body})
+		ids = append(ids, id)
+	}
+
+	tests := []struct {
+		url      string
+		wantCode int
+		wantErr  error
+		wantIDs  []PostID
+		wantNext string
+	}{
+		{url: "/v1/search/posts?q=handler&limit=x", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: "/v1/search/posts?q=handler&offset=x", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: "/v1/search/posts", wantCode: http.StatusUnprocessableEntity, wantErr: ErrEmptySearch},
+		{url: "/v1/search/posts?q=handler&sort=x", wantCode: http.StatusUnprocessableEntity, wantErr: ErrInvalidSearchOrder},
+		{url: "/v1/search/posts?q=handler+since:x", wantCode: http.StatusUnprocessableEntity, wantErr: ErrInvalidSearchDate},
+		{
+			url:      "/v1/search/posts?q=searches+handlers&sort=recent&limit=1",
+			wantCode: http.StatusOK, wantErr: errNil, wantIDs: []PostID{ids[1]},
+			wantNext: "/v1/search/posts?limit=1&offset=1&q=searches+handlers&sort=recent",
+		},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+
+		router := httprouter.New()
+		router.Handler(http.MethodGet, "/v1/search/posts", SearchPostsHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Err   string         `json:"error,omitempty"`
+			Posts []postResponse `json:"posts"`
+			Next  string         `json:"next"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		if tt.wantIDs != nil {
+			assert.Equal(hs.T(), tt.wantIDs, responseIDs(res.Posts))
+			assert.Equal(hs.T(), tt.wantNext, res.Next)
+		}
+	}
+}
+
+func (hs *HandlerTestSuite) TestSearchUsersHandler() {
+	user, err := hs.users.FindByID(hs.userID)
+	require.Nil(hs.T(), err)
+
+	tests := []struct {
+		url       string
+		wantCode  int
+		wantErr   error
+		wantFirst string
+	}{
+		{url: "/v1/search/users?q=user&limit=x", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: "/v1/search/users?q=", wantCode: http.StatusUnprocessableEntity, wantErr: ErrEmptySearch},
+		{url: "/v1/search/users?q=" + user.Username, wantCode: http.StatusOK, wantErr: errNil, wantFirst: user.Username},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+
+		router := httprouter.New()
+		router.Handler(http.MethodGet, "/v1/search/users", SearchUsersHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		if w.Code != http.StatusOK {
+			var res struct {
+				Err string `json:"error,omitempty"`
+			}
+			_ = json.NewDecoder(w.Body).Decode(&res)
+			assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+			continue
+		}
+
+		var users []UserInfo
+		_ = json.NewDecoder(w.Body).Decode(&users)
+		require.NotEmpty(hs.T(), users)
+		assert.Equal(hs.T(), tt.wantFirst, users[0].Username)
+	}
+}
+
+// multipartMediaRequest returns an upload request for data in a multipart form
+func multipartMediaRequest(data []byte, altText string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if data != nil {
+		fw, _ := mw.CreateFormFile("file", "image.png")
+		_, _ = fw.Write(data)
+	}
+	_ = mw.WriteField("alt_text", altText)
+	_ = mw.Close()
+
+	r, _ := http.NewRequest(http.MethodPost, "/v1/media", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func (hs *HandlerTestSuite) TestMediaHandlers() {
+	defer func(max int64) { MaxUploadSize = max }(MaxUploadSize)
+	uid := string(hs.userID)
+	data := encodePNG(newTestImage(20, 10))
+
+	tests := []struct {
+		data     []byte
+		withCtx  bool
+		wantCode int
+		wantErr  error
+	}{
+		{wantCode: http.StatusBadRequest, withCtx: true, wantErr: errNil},
+		{data: data, wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{data: []byte("text"), withCtx: true, wantCode: http.StatusUnsupportedMediaType, wantErr: ErrUnsupportedMedia},
+		{data: bytes.Repeat([]byte("a"), 3<<20), withCtx: true, wantCode: http.StatusRequestEntityTooLarge, wantErr: ErrMediaTooLarge},
+		{data: data, withCtx: true, wantCode: http.StatusCreated, wantErr: errNil},
+	}
+
+	MaxUploadSize = 1 << 20
+	for _, tt := range tests {
+		r := multipartMediaRequest(tt.data, "alt")
+		if tt.withCtx {
+			r = setIDInRequestContext(r, uid)
+		}
+
+		w := httptest.NewRecorder()
+		UploadMediaHandler(hs.svc).ServeHTTP(w, r)
+
+		var res struct {
+			attachmentResponse
+			Err string `json:"error,omitempty"`
+		}
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+
+		if tt.wantCode == http.StatusCreated {
+			assert.Equal(hs.T(), res.URL, w.Header().Get("Location"))
+			assert.Equal(hs.T(), "alt", res.AltText)
+
+			router := httprouter.New()
+			router.Handler(http.MethodGet, "/v1/media/:key", GetMediaHandler(hs.svc))
+
+			// until the upload is posted only its owner can fetch it
+			w = httptest.NewRecorder()
+			r, _ = http.NewRequest(http.MethodGet, res.ThumbnailURL, nil)
+			router.ServeHTTP(w, r)
+			assert.Equal(hs.T(), http.StatusNotFound, w.Code)
+
+			w = httptest.NewRecorder()
+			r, _ = http.NewRequest(http.MethodGet, res.ThumbnailURL, nil)
+			router.ServeHTTP(w, setIDInRequestContext(r, uid))
+			assert.Equal(hs.T(), http.StatusOK, w.Code)
+			assert.Equal(hs.T(), "image/png", w.Header().Get("Content-Type"))
+			assert.True(hs.T(), strings.HasPrefix(w.Header().Get("Cache-Control"), "private"))
+			img, err := png.DecodeConfig(w.Body)
+			assert.Nil(hs.T(), err)
+			assert.Equal(hs.T(), 20, img.Width)
+
+			w = httptest.NewRecorder()
+			r, _ = http.NewRequest(http.MethodGet, "/v1/media/missing.png", nil)
+			router.ServeHTTP(w, r)
+			assert.Equal(hs.T(), http.StatusNotFound, w.Code)
+		}
+	}
+}
+
 func (hs *HandlerTestSuite) TestGetProfileHandler() {
 	u := "postu"
 	host := "http://localhost:8080"
 	finalURL := fmt.Sprintf("%s/v1/users/%s", host, u)
 
 	user := DuplicateUser(hs.users, *hs.user, u)
-	_, _ = hs.svc.CreatePost(hs.userID, "post")
-	_, _ = hs.svc.CreatePost(user.ID, "post")
+	_, _ = hs.svc.CreatePost(hs.userID, createPostRequest{Body: "post"})
+	_, _ = hs.svc.CreatePost(user.ID, createPostRequest{Body: "post"})
 
 	tests := []struct {
 		username              string
@@ -216,6 +998,306 @@ func (hs *HandlerTestSuite) TestGetProfileHandler() {
 
 }
 
+func (hs *HandlerTestSuite) TestGetTimelineHandler() {
+	follower := DuplicateUser(hs.users, *hs.user, "tlFollower")
+	friend := DuplicateUser(hs.users, *hs.user, "tlFriend")
+	_ = hs.svc.CreateRelationshipFor(follower.ID, friend.Username)
+
+	_, _ = hs.svc.CreatePost(friend.ID, createPostRequest{Body: "first"})
+	_, _ = hs.svc.CreatePost(follower.ID, createPostRequest{Body: "second"})
+	_, _ = hs.svc.CreatePost(friend.ID, createPostRequest{Body: "third"})
+
+	tests := []struct {
+		id         string
+		withCtx    bool
+		wantCode   int
+		wantErr    error
+		wantPosLen int
+		wantURL    string
+	}{
+		{wantCode: http.StatusInternalServerError, wantErr: ErrEmptyContext},
+		{id: "invalid", withCtx: true, wantCode: http.StatusUnauthorized, wantErr: ErrInvalidID},
+		{id: string(nextID()), withCtx: true, wantCode: http.StatusNotFound, wantErr: ErrNotFound},
+		{id: string(friend.ID), withCtx: true, wantCode: http.StatusOK, wantErr: errNil, wantPosLen: 2, wantURL: "/v1/timeline"},
+		{id: string(follower.ID), withCtx: true, wantCode: http.StatusOK, wantErr: errNil, wantPosLen: 3, wantURL: "/v1/timeline"},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/v1/timeline", nil)
+
+		if tt.withCtx {
+			r = setIDInRequestContext(r, tt.id)
+		}
+
+		router := httprouter.New()
+		router.Handler(http.MethodGet, "/v1/timeline", GetTimelineHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Posts []postResponse `json:"posts"`
+			URL   string         `json:"url"`
+			Err   string         `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.Equal(hs.T(), tt.wantPosLen, len(res.Posts))
+		assert.Equal(hs.T(), tt.wantURL, res.URL)
+
+		if len(res.Posts) > 1 {
+			assert.True(hs.T(), !res.Posts[0].Timestamp.Before(res.Posts[1].Timestamp))
+		}
+	}
+}
+
+func (hs *HandlerTestSuite) TestGetUserPostsHandler() {
+	u := "pagedUser"
+	user := DuplicateUser(hs.users, *hs.user, u)
+	p1, _ := hs.svc.CreatePost(user.ID, createPostRequest{Body: "one"})
+	p2, _ := hs.svc.CreatePost(user.ID, createPostRequest{Body: "two"})
+	p3, _ := hs.svc.CreatePost(user.ID, createPostRequest{Body: "three"})
+
+	base := fmt.Sprintf("/v1/users/%s/posts", u)
+
+	tests := []struct {
+		url                string
+		wantCode           int
+		wantErr            error
+		wantIDs            []PostID
+		wantNext, wantPrev string
+	}{
+		{url: "/v1/users/%20/posts", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: base + "?limit=abc", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: base + "?before=abc", wantCode: http.StatusBadRequest, wantErr: ErrInvalidCursor},
+		{url: "/v1/users/nonexistent/posts", wantCode: http.StatusNotFound, wantErr: ErrNotFound},
+		{url: base, wantCode: http.StatusOK, wantErr: errNil, wantIDs: []PostID{p3, p2, p1}},
+		{url: base + "?limit=2", wantCode: http.StatusOK, wantErr: errNil, wantIDs: []PostID{p3, p2},
+			wantNext: fmt.Sprintf("%s?before=%s&limit=2", base, p2)},
+		{url: fmt.Sprintf("%s?limit=2&before=%s", base, p2), wantCode: http.StatusOK, wantErr: errNil, wantIDs: []PostID{p1},
+			wantPrev: fmt.Sprintf("%s?after=%s&limit=2", base, p1)},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+
+		router := httprouter.New()
+		router.Handler(http.MethodGet, "/v1/users/:username/posts", GetUserPostsHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Posts []postResponse `json:"posts"`
+			Next  string         `json:"next"`
+			Prev  string         `json:"prev"`
+			Err   string         `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		var ids []PostID
+		for _, p := range res.Posts {
+			ids = append(ids, p.ID)
+		}
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.Equal(hs.T(), tt.wantIDs, ids)
+		assert.Equal(hs.T(), tt.wantNext, res.Next)
+		assert.Equal(hs.T(), tt.wantPrev, res.Prev)
+	}
+}
+
+func (hs *HandlerTestSuite) TestGetUserMentionsHandler() {
+	u := "mentionedUser"
+	user := DuplicateUser(hs.users, *hs.user, u)
+	p1, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "hello @" + u})
+
+	tests := []struct {
+		url          string
+		wantCode     int
+		wantErr      error
+		wantIDs      []PostID
+		wantEntities int
+	}{
+		{url: "/v1/users/" + u + "/mentions?limit=abc", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: "/v1/users/nonexistent/mentions", wantCode: http.StatusNotFound, wantErr: ErrNotFound},
+		{url: "/v1/users/" + u + "/mentions", wantCode: http.StatusOK, wantErr: errNil, wantIDs: []PostID{p1}, wantEntities: 1},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+
+		router := httprouter.New()
+		router.Handler(http.MethodGet, "/v1/users/:username/mentions", GetUserMentionsHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Posts []postResponse `json:"posts"`
+			Err   string         `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		var ids []PostID
+		entities := 0
+		for _, p := range res.Posts {
+			ids = append(ids, p.ID)
+			for _, e := range p.Entities {
+				assert.Equal(hs.T(), user.ID, e.UserID)
+				entities++
+			}
+		}
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.Equal(hs.T(), tt.wantIDs, ids)
+		assert.Equal(hs.T(), tt.wantEntities, entities)
+	}
+}
+
+func (hs *HandlerTestSuite) TestGetExploreHandler() {
+	p1, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "explored"})
+	p2, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "ignored"})
+	_ = hs.svc.React(hs.userID, p1, ReactionLike)
+
+	tests := []struct {
+		url      string
+		wantCode int
+		wantErr  error
+		wantIDs  []PostID
+		wantNext string
+	}{
+		{url: "/v1/explore?limit=abc", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: "/v1/explore?engaged=maybe", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: "/v1/explore?after=abc", wantCode: http.StatusBadRequest, wantErr: ErrInvalidCursor},
+		{url: "/v1/explore?engaged=true", wantCode: http.StatusOK, wantErr: errNil, wantIDs: []PostID{p1}},
+		{url: "/v1/explore?limit=1", wantCode: http.StatusOK, wantErr: errNil, wantIDs: []PostID{p2},
+			wantNext: fmt.Sprintf("/v1/explore?before=%s&limit=1", p2)},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+
+		w := httptest.NewRecorder()
+		GetExploreHandler(hs.svc).ServeHTTP(w, r)
+
+		var res struct {
+			Posts []postResponse `json:"posts"`
+			Next  string         `json:"next"`
+			Err   string         `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		if tt.wantIDs != nil {
+			assert.Equal(hs.T(), tt.wantIDs, responseIDs(res.Posts))
+		}
+		assert.Equal(hs.T(), tt.wantNext, res.Next)
+	}
+}
+
+func (hs *HandlerTestSuite) TestGetTrendsHandler() {
+	for i := 0; i < minTrendPosts; i++ {
+		_, _ = hs.svc.CreatePost(hs.userID, createPostRequest{Body: "#handlers"})
+	}
+
+	tests := []struct {
+		url      string
+		wantCode int
+		wantErr  error
+		wantTags []string
+	}{
+		{url: "/v1/trends?limit=x", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: "/v1/trends?window=1w", wantCode: http.StatusUnprocessableEntity, wantErr: ErrInvalidTrendWindow},
+		{url: "/v1/trends?window=24h&limit=1", wantCode: http.StatusOK, wantErr: errNil, wantTags: []string{"handlers"}},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+
+		w := httptest.NewRecorder()
+		GetTrendsHandler(hs.svc).ServeHTTP(w, r)
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		if tt.wantTags == nil {
+			var res struct {
+				Err string `json:"error,omitempty"`
+			}
+			_ = json.NewDecoder(w.Body).Decode(&res)
+			assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+			continue
+		}
+
+		var trends []Trend
+		_ = json.NewDecoder(w.Body).Decode(&trends)
+		var tags []string
+		for _, t := range trends {
+			tags = append(tags, t.Tag)
+		}
+		assert.Equal(hs.T(), tt.wantTags, tags)
+	}
+}
+
+func (hs *HandlerTestSuite) TestGetTagPostsHandler() {
+	p1, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "#handlers one"})
+	p2, _ := hs.svc.CreatePost(hs.userID, createPostRequest{Body: "#Handlers two"})
+
+	base := "/v1/tags/handlers/posts"
+
+	tests := []struct {
+		url      string
+		wantCode int
+		wantErr  error
+		wantIDs  []PostID
+		wantNext string
+	}{
+		{url: base + "?limit=abc", wantCode: http.StatusBadRequest, wantErr: errNil},
+		{url: base + "?before=abc", wantCode: http.StatusBadRequest, wantErr: ErrInvalidCursor},
+		{url: "/v1/tags/%23/posts", wantCode: http.StatusUnprocessableEntity, wantErr: ErrInvalidTag},
+		{url: "/v1/tags/nothing/posts", wantCode: http.StatusOK, wantErr: errNil},
+		{url: base, wantCode: http.StatusOK, wantErr: errNil, wantIDs: []PostID{p2, p1}},
+		{url: base + "?limit=1", wantCode: http.StatusOK, wantErr: errNil, wantIDs: []PostID{p2},
+			wantNext: fmt.Sprintf("%s?before=%s&limit=1", base, p2)},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+
+		router := httprouter.New()
+		router.Handler(http.MethodGet, "/v1/tags/:tag/posts", GetTagPostsHandler(hs.svc))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var res struct {
+			Posts []postResponse `json:"posts"`
+			Next  string         `json:"next"`
+			Err   string         `json:"error,omitempty"`
+		}
+
+		_ = json.NewDecoder(w.Body).Decode(&res)
+
+		var ids []PostID
+		for _, p := range res.Posts {
+			ids = append(ids, p.ID)
+		}
+
+		assert.Equal(hs.T(), tt.wantCode, w.Code)
+		assert.Equal(hs.T(), tt.wantErr.Error(), res.Err)
+		assert.Equal(hs.T(), tt.wantIDs, ids)
+		assert.Equal(hs.T(), tt.wantNext, res.Next)
+	}
+}
+
 func (hs *HandlerTestSuite) TestEditProfileHandler() {
 	// duplicate user to avoid conflicts with user in the suite
 	username := "tempUser"
@@ -302,6 +1384,17 @@ func (hs *HandlerTestSuite) TestEditProfileHandler() {
 			router.ServeHTTP(w, r3)
 		}
 	}
+
+	r, _ := http.NewRequest(http.MethodPatch, "/v1/users", strings.NewReader(`{"expand_sensitive": true}`))
+	r = setIDInRequestContext(r, sid)
+	w := httptest.NewRecorder()
+	EditProfileHandler(hs.svc).ServeHTTP(w, r)
+	assert.Equal(hs.T(), http.StatusOK, w.Code)
+
+	u, _ := hs.users.FindByID(user.ID)
+	profile, _ := hs.svc.GetProfile(user.ID, u.Username)
+	require.NotNil(hs.T(), profile.ExpandSensitive)
+	assert.True(hs.T(), *profile.ExpandSensitive)
 }
 
 func (hs *HandlerTestSuite) TestCreateRelationshipHandler() {
@@ -485,6 +1578,42 @@ func (hs *HandlerTestSuite) TestRequireAuthMiddleware() {
 	}
 }
 
+func (hs *HandlerTestSuite) TestOptionalAuthMiddleware() {
+	validToken, _ := getJWTToken("randomid")
+
+	tests := []struct {
+		authHeader string
+		wantID     string
+		wantOK     bool
+	}{
+		{},
+		{authHeader: "Random random"},
+		{authHeader: "Bearer random.random.random"},
+		{authHeader: "Bearer " + invalidToken},
+		{authHeader: "Bearer " + validToken, wantID: "randomid", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		var called, ok bool
+		var id string
+		f := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			id, ok = getUserIDFromContext(r.Context())
+		})
+
+		r, _ := http.NewRequest(http.MethodGet, "/v1/posts", nil)
+		r.Header.Set("Authorization", tt.authHeader)
+
+		w := httptest.NewRecorder()
+		OptionalAuth(f).ServeHTTP(w, r)
+
+		assert.Equal(hs.T(), http.StatusOK, w.Code)
+		assert.True(hs.T(), called)
+		assert.Equal(hs.T(), tt.wantOK, ok)
+		assert.Equal(hs.T(), tt.wantID, id)
+	}
+}
+
 func (hs *HandlerTestSuite) TestLastSeenMiddleware() {
 	now := time.Now().UTC()
 	validToken, _ := getJWTToken(string(hs.userID))
@@ -527,7 +1656,7 @@ func (hs *HandlerTestSuite) TestLastSeenMiddleware() {
 		assert.Equal(hs.T(), tt.wantCalled, called)
 
 		if tt.wantUpdatedLastSeen {
-			p, _ := hs.svc.GetProfile(hs.username)
+			p, _ := hs.svc.GetProfile("", hs.username)
 			assert.Equal(hs.T(), tt.wantUpdatedLastSeen, p.LastSeen.After(now))
 		}
 	}
@@ -1,7 +1,13 @@
 package blog
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
 	"sort"
+	"sync"
+	"time"
 )
 
 type userRepository struct {
@@ -67,16 +73,77 @@ func (repo *userRepository) FindByIDs(ids []ID) ([]User, error) {
 	return users, nil
 }
 
+func (repo *userRepository) Search(q string, limit int) ([]User, error) {
+	users := make([]User, 0, len(repo.users))
+	for _, u := range repo.users {
+		users = append(users, *u)
+	}
+	return rankUsers(users, q, limit), nil
+}
+
+func (repo *userRepository) FindPopular(ids []ID, followers int) ([]ID, error) {
+	popular := []ID{}
+	for _, id := range ids {
+		if u, ok := repo.users[id]; ok && len(u.Followers) > followers {
+			popular = append(popular, id)
+		}
+	}
+	return popular, nil
+}
+
 type postRepository struct {
 	posts map[PostID]Post
+	// conversations indexes post ids by the id of their conversation
+	conversations map[PostID][]PostID
+	search        *searchIndex
 }
 
 func NewPostRepository() PostRepository {
-	return &postRepository{posts: map[PostID]Post{}}
+	return &postRepository{
+		posts:         map[PostID]Post{},
+		conversations: map[PostID][]PostID{},
+		search:        newSearchIndex(),
+	}
 }
 
 func (repo *postRepository) Store(post Post) error {
+	if _, ok := repo.posts[post.ID]; !ok {
+		c := post.ConversationID
+		repo.conversations[c] = append(repo.conversations[c], post.ID)
+	}
+	repo.posts[post.ID] = post
+	repo.search.add(post.ID, post.Body)
+	return nil
+}
+
+func (repo *postRepository) Update(post Post) error {
+	if _, ok := repo.posts[post.ID]; !ok {
+		return ErrPostNotFound
+	}
 	repo.posts[post.ID] = post
+	repo.search.add(post.ID, post.Body)
+	return nil
+}
+
+func (repo *postRepository) Delete(id PostID) error {
+	post, ok := repo.posts[id]
+	if !ok {
+		return ErrPostNotFound
+	}
+
+	ids := repo.conversations[post.ConversationID]
+	for i, pid := range ids {
+		if pid == id {
+			repo.conversations[post.ConversationID] = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(repo.conversations[post.ConversationID]) == 0 {
+		delete(repo.conversations, post.ConversationID)
+	}
+
+	delete(repo.posts, id)
+	repo.search.remove(id)
 	return nil
 }
 
@@ -87,15 +154,23 @@ func (repo *postRepository) FindByID(id PostID) (Post, error) {
 	return Post{}, ErrPostNotFound
 }
 
-func (repo *postRepository) FindLatestPostsForUser(id ID) ([]*Post, error) {
-	posts := repo.FindUserPosts(id)
+func (repo *postRepository) FindByIDs(ids []PostID) ([]*Post, error) {
+	posts := []*Post{}
+	for _, id := range ids {
+		if p, ok := repo.posts[id]; ok {
+			posts = append(posts, &p)
+		}
+	}
+	return posts, nil
+}
 
-	sortPostsByTimestamp(posts)
+func (repo *postRepository) FindLatestPostsForUser(id ID, page Page) ([]*Post, error) {
+	posts := repo.FindUserPosts(id)
 
-	return posts, nil
+	return paginate(posts, page, repo.FindByID), nil
 }
 
-func (repo *postRepository) FindLatestPostsForUserAndFriends(user *User) ([]*Post, error) {
+func (repo *postRepository) FindLatestPostsForUserAndFriends(user *User, limit int) ([]*Post, error) {
 	id := user.ID
 	posts := repo.FindUserPosts(id)
 
@@ -105,13 +180,169 @@ func (repo *postRepository) FindLatestPostsForUserAndFriends(user *User) ([]*Pos
 	}
 
 	sortPostsByTimestamp(posts)
+	if limit > 0 && len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
+
+func (repo *postRepository) FindByConversation(id PostID) ([]*Post, error) {
+	posts := []*Post{}
+	for _, pid := range repo.conversations[id] {
+		p := repo.posts[pid]
+		if !p.IsScheduled() {
+			posts = append(posts, &p)
+		}
+	}
+	return posts, nil
+}
+
+func (repo *postRepository) CountReplies(ids []PostID) (map[PostID]int, error) {
+	return repo.countBy(ids, func(p Post) PostID { return p.InReplyTo }), nil
+}
+
+func (repo *postRepository) FindRepost(id ID, postID PostID) (Post, error) {
+	for _, p := range repo.posts {
+		if p.IsRepost() && p.Author.UserID == id && p.RepostOf == postID {
+			return p, nil
+		}
+	}
+	return Post{}, ErrPostNotFound
+}
+
+func (repo *postRepository) CountReposts(ids []PostID) (map[PostID]int, error) {
+	return repo.countBy(ids, func(p Post) PostID { return p.RepostOf }), nil
+}
+
+func (repo *postRepository) FindByTag(tag string, page Page) ([]*Post, error) {
+	var posts []*Post
+	for _, p := range repo.posts {
+		if p.IsScheduled() {
+			continue
+		}
+		for _, t := range p.Tags {
+			if t == tag {
+				pp := p
+				posts = append(posts, &pp)
+				break
+			}
+		}
+	}
+	return paginate(posts, page, repo.FindByID), nil
+}
+
+func (repo *postRepository) FindPublic(since time.Time, page Page) ([]*Post, error) {
+	var posts []*Post
+	for _, p := range repo.posts {
+		if p.IsScheduled() || p.IsRepost() || !p.IsPublic() || p.Timestamp.Before(since) {
+			continue
+		}
+		pp := p
+		posts = append(posts, &pp)
+	}
+	return paginate(posts, page, repo.FindByID), nil
+}
+
+func (repo *postRepository) FindMentions(id ID, page Page) ([]*Post, error) {
+	var posts []*Post
+	for _, p := range repo.posts {
+		if p.IsScheduled() {
+			continue
+		}
+		for _, m := range p.Mentions {
+			if m.UserID == id {
+				pp := p
+				posts = append(posts, &pp)
+				break
+			}
+		}
+	}
+	return paginate(posts, page, repo.FindByID), nil
+}
+
+func (repo *postRepository) FindScheduled(id ID) ([]*Post, error) {
+	return repo.findScheduled(func(p Post) bool { return p.Author.UserID == id }), nil
+}
+
+func (repo *postRepository) FindDue(t time.Time) ([]*Post, error) {
+	return repo.findScheduled(func(p Post) bool { return !p.PublishAt.After(t) }), nil
+}
+
+func (repo *postRepository) Publish(id PostID, at time.Time) (Post, error) {
+	p, ok := repo.posts[id]
+	if !ok || !p.IsScheduled() {
+		return Post{}, ErrNotScheduled
+	}
+
+	p.State, p.PublishAt, p.Timestamp = "", time.Time{}, at
+	repo.posts[id] = p
+	return p, nil
+}
+
+func (repo *postRepository) Search(q SearchQuery, page SearchPage) ([]*Post, error) {
+	scores := repo.search.search(q)
+
+	var posts []*Post
+	for id := range scores {
+		if p, ok := repo.posts[id]; ok && q.matches(&p) {
+			posts = append(posts, &p)
+		}
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		si, sj := scores[posts[i].ID], scores[posts[j].ID]
+		if page.Order == SearchByRelevance && si != sj {
+			return si > sj
+		}
+		return posts[i].newerThan(posts[j])
+	})
+
+	if page.Offset >= len(posts) {
+		return []*Post{}, nil
+	}
+	posts = posts[page.Offset:]
+	if page.Limit > 0 && len(posts) > page.Limit {
+		posts = posts[:page.Limit]
+	}
 	return posts, nil
 }
 
+// findScheduled returns the scheduled posts matching filter, due first
+func (repo *postRepository) findScheduled(filter func(Post) bool) []*Post {
+	posts := []*Post{}
+	for _, p := range repo.posts {
+		if p.IsScheduled() && filter(p) {
+			pp := p
+			posts = append(posts, &pp)
+		}
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].PublishAt.Before(posts[j].PublishAt)
+	})
+	return posts
+}
+
+// countBy counts the public posts for which field returns each of the given ids
+func (repo *postRepository) countBy(ids []PostID, field func(Post) PostID) map[PostID]int {
+	counts := map[PostID]int{}
+	for _, id := range ids {
+		counts[id] = 0
+	}
+
+	for _, p := range repo.posts {
+		id := field(p)
+		if _, ok := counts[id]; ok && id != "" && !p.IsScheduled() && p.IsPublic() {
+			counts[id]++
+		}
+	}
+	return counts
+}
+
 func (repo *postRepository) FindUserPosts(id ID) []*Post {
 	var posts []*Post
 	for i, p := range repo.posts {
-		if p.Author.UserID == id {
+		if p.Author.UserID == id && !p.IsScheduled() {
 			pp := repo.posts[i]
 			posts = append(posts, &pp)
 		}
@@ -119,8 +350,768 @@ func (repo *postRepository) FindUserPosts(id ID) []*Post {
 	return posts
 }
 
+// paginate returns the posts selected by page, newest first. Post ids are
+// xids so they sort in creation order.
+func paginate(posts []*Post, page Page, find func(PostID) (Post, error)) []*Post {
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].newerThan(posts[j])
+	})
+
+	res := []*Post{}
+	if page.After != "" {
+		after := cursorPost(page.After, find)
+		// walk upwards from the cursor so we keep the posts closest to it
+		for i := len(posts) - 1; i >= 0; i-- {
+			if page.Limit > 0 && len(res) == page.Limit {
+				break
+			}
+			if posts[i].newerThan(after) {
+				res = append(res, posts[i])
+			}
+		}
+
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+		return res
+	}
+
+	var before *Post
+	if page.Before != "" {
+		before = cursorPost(page.Before, find)
+	}
+	for _, p := range posts {
+		if page.Limit > 0 && len(res) == page.Limit {
+			break
+		}
+		if before == nil || before.newerThan(p) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+type reactionRepository struct {
+	reactions map[string]Reaction
+}
+
+func NewReactionRepository() ReactionRepository {
+	return &reactionRepository{reactions: map[string]Reaction{}}
+}
+
+func (repo *reactionRepository) Store(r Reaction) error {
+	if _, ok := repo.reactions[r.key()]; ok {
+		return ErrAlreadyReacted
+	}
+	repo.reactions[r.key()] = r
+	return nil
+}
+
+func (repo *reactionRepository) Delete(postID PostID, userID ID, t ReactionType) error {
+	key := reactionKey(postID, userID, t)
+	if _, ok := repo.reactions[key]; !ok {
+		return ErrNotReacted
+	}
+	delete(repo.reactions, key)
+	return nil
+}
+
+func (repo *reactionRepository) DeleteByPost(postID PostID) error {
+	for k, r := range repo.reactions {
+		if r.PostID == postID {
+			delete(repo.reactions, k)
+		}
+	}
+	return nil
+}
+
+func (repo *reactionRepository) FindByPost(postID PostID) ([]Reaction, error) {
+	reactions := []Reaction{}
+	for _, r := range repo.reactions {
+		if r.PostID == postID {
+			reactions = append(reactions, r)
+		}
+	}
+
+	sort.Slice(reactions, func(i, j int) bool {
+		return reactions[i].Timestamp.After(reactions[j].Timestamp)
+	})
+	return reactions, nil
+}
+
+func (repo *reactionRepository) CountByPosts(ids []PostID) (map[PostID]map[ReactionType]int, error) {
+	counts := map[PostID]map[ReactionType]int{}
+	for _, id := range ids {
+		counts[id] = map[ReactionType]int{}
+	}
+
+	for _, r := range repo.reactions {
+		if c, ok := counts[r.PostID]; ok {
+			c[r.Type]++
+		}
+	}
+	return counts, nil
+}
+
+func (repo *reactionRepository) FindByUser(userID ID, ids []PostID) (map[PostID][]ReactionType, error) {
+	wanted := map[PostID]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	types := map[PostID][]ReactionType{}
+	for _, r := range repo.reactions {
+		if r.UserID == userID && wanted[r.PostID] {
+			types[r.PostID] = append(types[r.PostID], r.Type)
+		}
+	}
+	return types, nil
+}
+
+type voteRepository struct {
+	mu    sync.Mutex
+	votes map[string]Vote
+}
+
+func NewVoteRepository() VoteRepository {
+	return &voteRepository{votes: map[string]Vote{}}
+}
+
+func (repo *voteRepository) Store(v Vote) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.votes[v.key()]; ok {
+		return ErrAlreadyVoted
+	}
+	repo.votes[v.key()] = v
+	return nil
+}
+
+func (repo *voteRepository) DeleteByPost(postID PostID) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for k, v := range repo.votes {
+		if v.PostID == postID {
+			delete(repo.votes, k)
+		}
+	}
+	return nil
+}
+
+func (repo *voteRepository) CountByPosts(ids []PostID) (map[PostID]map[int]int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	counts := map[PostID]map[int]int{}
+	for _, id := range ids {
+		counts[id] = map[int]int{}
+	}
+
+	for _, v := range repo.votes {
+		if c, ok := counts[v.PostID]; ok {
+			c[v.Choice]++
+		}
+	}
+	return counts, nil
+}
+
+func (repo *voteRepository) FindByUser(userID ID, ids []PostID) (map[PostID]int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	choices := map[PostID]int{}
+	for _, id := range ids {
+		if v, ok := repo.votes[Vote{PostID: id, UserID: userID}.key()]; ok {
+			choices[id] = v.Choice
+		}
+	}
+	return choices, nil
+}
+
+// attachmentRepository is safe for concurrent use since orphans are collected
+// in the background
+type attachmentRepository struct {
+	mu          sync.Mutex
+	attachments map[AttachmentID]Attachment
+}
+
+func NewAttachmentRepository() AttachmentRepository {
+	return &attachmentRepository{attachments: map[AttachmentID]Attachment{}}
+}
+
+func (repo *attachmentRepository) Store(a Attachment) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.attachments[a.ID] = a
+	return nil
+}
+
+func (repo *attachmentRepository) FindByIDs(ids []AttachmentID) ([]Attachment, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var attachments []Attachment
+	for _, id := range ids {
+		if a, ok := repo.attachments[id]; ok {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments, nil
+}
+
+func (repo *attachmentRepository) FindByPost(postID PostID) ([]Attachment, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var attachments []Attachment
+	for _, a := range repo.attachments {
+		if a.PostID == postID {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments, nil
+}
+
+func (repo *attachmentRepository) Attach(owner ID, ids []AttachmentID, postID PostID) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, id := range ids {
+		a, ok := repo.attachments[id]
+		if !ok || a.Owner != owner || !a.IsOrphan() {
+			return ErrInvalidAttachment
+		}
+	}
+
+	for _, id := range ids {
+		a := repo.attachments[id]
+		a.PostID = postID
+		repo.attachments[id] = a
+	}
+	return nil
+}
+
+func (repo *attachmentRepository) FindOrphans(before time.Time) ([]Attachment, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var orphans []Attachment
+	for _, a := range repo.attachments {
+		if a.IsOrphan() && a.CreatedAt.Before(before) {
+			orphans = append(orphans, a)
+		}
+	}
+	return orphans, nil
+}
+
+func (repo *attachmentRepository) DeleteOrphan(id AttachmentID) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if a, ok := repo.attachments[id]; !ok || !a.IsOrphan() {
+		return ErrAttachmentNotFound
+	}
+	delete(repo.attachments, id)
+	return nil
+}
+
+func (repo *attachmentRepository) Delete(id AttachmentID) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.attachments[id]; !ok {
+		return ErrAttachmentNotFound
+	}
+	delete(repo.attachments, id)
+	return nil
+}
+
+type blobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+// NewBlobStore returns a BlobStore keeping files in memory
+func NewBlobStore() BlobStore {
+	return &blobStore{blobs: map[string][]byte{}}
+}
+
+func (s *blobStore) Put(key string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = data
+	return nil
+}
+
+func (s *blobStore) Get(key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, ErrBlobNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *blobStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.blobs, key)
+	return nil
+}
+
+type draftRepository struct {
+	mu     sync.Mutex
+	drafts map[DraftID]Draft
+}
+
+func NewDraftRepository() DraftRepository {
+	return &draftRepository{drafts: map[DraftID]Draft{}}
+}
+
+func (repo *draftRepository) Store(d Draft) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.drafts[d.ID] = d
+	return nil
+}
+
+func (repo *draftRepository) Update(d Draft) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.drafts[d.ID]; !ok {
+		return ErrDraftNotFound
+	}
+	repo.drafts[d.ID] = d
+	return nil
+}
+
+func (repo *draftRepository) FindByID(id DraftID) (Draft, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	d, ok := repo.drafts[id]
+	if !ok {
+		return Draft{}, ErrDraftNotFound
+	}
+	return d, nil
+}
+
+func (repo *draftRepository) FindByAuthor(id ID) ([]Draft, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	drafts := []Draft{}
+	for _, d := range repo.drafts {
+		if d.Author == id {
+			drafts = append(drafts, d)
+		}
+	}
+
+	sort.Slice(drafts, func(i, j int) bool {
+		if drafts[i].UpdatedAt.Equal(drafts[j].UpdatedAt) {
+			return drafts[i].ID > drafts[j].ID
+		}
+		return drafts[i].UpdatedAt.After(drafts[j].UpdatedAt)
+	})
+	return drafts, nil
+}
+
+func (repo *draftRepository) Delete(id DraftID) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.drafts[id]; !ok {
+		return ErrDraftNotFound
+	}
+	delete(repo.drafts, id)
+	return nil
+}
+
+type bookmarkRepository struct {
+	mu        sync.Mutex
+	bookmarks map[string]Bookmark
+}
+
+func NewBookmarkRepository() BookmarkRepository {
+	return &bookmarkRepository{bookmarks: map[string]Bookmark{}}
+}
+
+func (repo *bookmarkRepository) Store(b Bookmark) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.bookmarks[b.key()]; ok {
+		return ErrAlreadyBookmarked
+	}
+	repo.bookmarks[b.key()] = b
+	return nil
+}
+
+func (repo *bookmarkRepository) Delete(userID ID, postID PostID) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	key := Bookmark{UserID: userID, PostID: postID}.key()
+	if _, ok := repo.bookmarks[key]; !ok {
+		return ErrNotBookmarked
+	}
+	delete(repo.bookmarks, key)
+	return nil
+}
+
+func (repo *bookmarkRepository) DeleteByPost(postID PostID) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for k, b := range repo.bookmarks {
+		if b.PostID == postID {
+			delete(repo.bookmarks, k)
+		}
+	}
+	return nil
+}
+
+func (repo *bookmarkRepository) FindByUser(userID ID, page Page) ([]Bookmark, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var bookmarks []Bookmark
+	for _, b := range repo.bookmarks {
+		if b.UserID == userID {
+			bookmarks = append(bookmarks, b)
+		}
+	}
+
+	sort.Slice(bookmarks, func(i, j int) bool {
+		return bookmarks[i].ID > bookmarks[j].ID
+	})
+
+	res := []Bookmark{}
+	if page.After != "" {
+		// walk upwards from the cursor so we keep the bookmarks closest to it
+		for i := len(bookmarks) - 1; i >= 0; i-- {
+			if page.Limit > 0 && len(res) == page.Limit {
+				break
+			}
+			if string(bookmarks[i].ID) > page.After {
+				res = append([]Bookmark{bookmarks[i]}, res...)
+			}
+		}
+		return res, nil
+	}
+
+	for _, b := range bookmarks {
+		if page.Limit > 0 && len(res) == page.Limit {
+			break
+		}
+		if page.Before == "" || string(b.ID) < page.Before {
+			res = append(res, b)
+		}
+	}
+	return res, nil
+}
+
+// searchIndex is an inverted index of post bodies. For each token it holds
+// the posts it appears in and its positions in each of them, so phrases can
+// be matched by looking for their tokens one after the other.
+type searchIndex struct {
+	tokens map[string]map[PostID][]int
+	// docs holds the tokens of each post, in order
+	docs map[PostID][]string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{tokens: map[string]map[PostID][]int{}, docs: map[PostID][]string{}}
+}
+
+func (idx *searchIndex) add(id PostID, body string) {
+	idx.remove(id)
+
+	tokens := searchTokens(body)
+	for i, t := range tokens {
+		if idx.tokens[t] == nil {
+			idx.tokens[t] = map[PostID][]int{}
+		}
+		idx.tokens[t][id] = append(idx.tokens[t][id], i)
+	}
+	idx.docs[id] = tokens
+}
+
+func (idx *searchIndex) remove(id PostID) {
+	for _, t := range idx.docs[id] {
+		if posts, ok := idx.tokens[t]; ok {
+			delete(posts, id)
+			if len(posts) == 0 {
+				delete(idx.tokens, t)
+			}
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// search scores the posts matching the words and phrases of sq by tf-idf.
+// Phrases add the score of their words.
+func (idx *searchIndex) search(sq SearchQuery) map[PostID]float64 {
+	var candidates map[PostID]bool
+	var terms []string
+
+	for _, phrase := range sq.Phrases {
+		tokens := searchTokens(phrase)
+		matched := map[PostID]bool{}
+		for id := range idx.tokens[tokens[0]] {
+			if (candidates == nil || candidates[id]) && idx.hasPhrase(id, tokens) {
+				matched[id] = true
+			}
+		}
+		candidates = matched
+		terms = append(terms, searchTerms([]string{phrase})...)
+	}
+
+	words := searchTerms(sq.Words)
+	if candidates == nil {
+		candidates = map[PostID]bool{}
+		for _, t := range words {
+			for id := range idx.tokens[t] {
+				candidates[id] = true
+			}
+		}
+	}
+	terms = append(terms, words...)
+
+	scores := map[PostID]float64{}
+	n := float64(len(idx.docs))
+	for id := range candidates {
+		var score float64
+		for _, t := range terms {
+			if tf := len(idx.tokens[t][id]); tf > 0 {
+				idf := math.Log(1 + n/float64(len(idx.tokens[t])))
+				score += float64(tf) / float64(len(idx.docs[id])) * idf
+			}
+		}
+		scores[id] = score
+	}
+	return scores
+}
+
+// hasPhrase reports whether the tokens appear in the post one after the other
+func (idx *searchIndex) hasPhrase(id PostID, tokens []string) bool {
+	for _, start := range idx.tokens[tokens[0]][id] {
+		found := true
+		for i, t := range tokens[1:] {
+			if !containsPosition(idx.tokens[t][id], start+i+1) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPosition(positions []int, pos int) bool {
+	i := sort.SearchInts(positions, pos)
+	return i < len(positions) && positions[i] == pos
+}
+
+type timelineRepository struct {
+	mu        sync.Mutex
+	timelines map[ID][]TimelineEntry
+}
+
+func NewTimelineRepository() TimelineRepository {
+	return &timelineRepository{timelines: map[ID][]TimelineEntry{}}
+}
+
+func (repo *timelineRepository) Store(user ID, entries []TimelineEntry) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.timelines[user] = []TimelineEntry{}
+	repo.add(user, entries)
+	return nil
+}
+
+func (repo *timelineRepository) Push(users []ID, e TimelineEntry) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, u := range users {
+		repo.add(u, []TimelineEntry{e})
+	}
+	return nil
+}
+
+func (repo *timelineRepository) Backfill(user ID, entries []TimelineEntry) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.add(user, entries)
+	return nil
+}
+
+// add merges the entries into the user's timeline, keeping it newest first and
+// no longer than maxTimelineLength. Users without a timeline are skipped.
+func (repo *timelineRepository) add(user ID, entries []TimelineEntry) {
+	tl, ok := repo.timelines[user]
+	if !ok {
+		return
+	}
+
+	tl = append(tl, entries...)
+	sort.SliceStable(tl, func(i, j int) bool {
+		if !tl[i].Timestamp.Equal(tl[j].Timestamp) {
+			return tl[i].Timestamp.After(tl[j].Timestamp)
+		}
+		return tl[i].PostID > tl[j].PostID
+	})
+
+	if len(tl) > maxTimelineLength {
+		tl = tl[:maxTimelineLength]
+	}
+	repo.timelines[user] = tl
+}
+
+func (repo *timelineRepository) RemoveAuthor(user ID, author ID) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.remove(user, func(e TimelineEntry) bool { return e.Author == author })
+	return nil
+}
+
+func (repo *timelineRepository) RemovePost(id PostID) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for user := range repo.timelines {
+		repo.remove(user, func(e TimelineEntry) bool { return e.PostID == id })
+	}
+	return nil
+}
+
+// remove takes the entries matching filter out of the user's timeline
+func (repo *timelineRepository) remove(user ID, filter func(TimelineEntry) bool) {
+	tl, ok := repo.timelines[user]
+	if !ok {
+		return
+	}
+
+	kept := []TimelineEntry{}
+	for _, e := range tl {
+		if !filter(e) {
+			kept = append(kept, e)
+		}
+	}
+	repo.timelines[user] = kept
+}
+
+func (repo *timelineRepository) Find(user ID, limit int) ([]TimelineEntry, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	tl, ok := repo.timelines[user]
+	if !ok {
+		return nil, ErrTimelineNotFound
+	}
+	if limit > 0 && len(tl) > limit {
+		tl = tl[:limit]
+	}
+	return append([]TimelineEntry{}, tl...), nil
+}
+
+type trendRepository struct {
+	mu     sync.Mutex
+	counts map[tagBucket]TagCount
+}
+
+type tagBucket struct {
+	tag   string
+	size  time.Duration
+	start int64
+}
+
+func NewTrendRepository() TrendRepository {
+	return &trendRepository{counts: map[tagBucket]TagCount{}}
+}
+
+func (repo *trendRepository) Increment(counts []TagCount) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := time.Now()
+	for k, c := range repo.counts {
+		if c.Expires.Before(now) {
+			delete(repo.counts, k)
+		}
+	}
+
+	for _, c := range counts {
+		k := tagBucket{tag: c.Tag, size: c.Size, start: c.Start.UnixNano()}
+		stored, ok := repo.counts[k]
+		if !ok {
+			repo.counts[k] = c
+			continue
+		}
+		stored.Count += c.Count
+		if c.Expires.After(stored.Expires) {
+			stored.Expires = c.Expires
+		}
+		repo.counts[k] = stored
+	}
+	return nil
+}
+
+func (repo *trendRepository) Decrement(counts []TagCount) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, c := range counts {
+		k := tagBucket{tag: c.Tag, size: c.Size, start: c.Start.UnixNano()}
+		stored, ok := repo.counts[k]
+		if !ok || stored.Count < c.Count {
+			continue
+		}
+
+		stored.Count -= c.Count
+		if stored.Count == 0 {
+			delete(repo.counts, k)
+			continue
+		}
+		repo.counts[k] = stored
+	}
+	return nil
+}
+
+func (repo *trendRepository) Find(size time.Duration, since time.Time) ([]TagCount, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	counts := []TagCount{}
+	for _, c := range repo.counts {
+		if c.Size == size && !c.Start.Before(since) {
+			counts = append(counts, c)
+		}
+	}
+	return counts, nil
+}
+
 func sortPostsByTimestamp(posts []*Post) {
 	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].Timestamp.After(posts[j].Timestamp)
+		return posts[i].newerThan(posts[j])
 	})
 }
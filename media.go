@@ -0,0 +1,386 @@
+package blog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedMedia = errors.New("unsupported media type")
+	ErrMediaTooLarge    = errors.New("media is too large")
+)
+
+// MaxUploadSize is the largest file in bytes that can be uploaded
+var MaxUploadSize int64 = 5 << 20
+
+const (
+	// maxImagePixels guards against small files that decode to huge images.
+	// It bounds the pixels of all the frames of an animation together.
+	maxImagePixels = 40000000
+	// maxGIFFrames is the most frames an animation can have
+	maxGIFFrames = 500
+	// thumbnailSize is the longest side of a thumbnail in pixels
+	thumbnailSize = 320
+)
+
+// mediaExtensions maps the supported content types to the extension of the
+// files they are stored in
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// processedImage is an uploaded image cleaned up and ready to be stored
+type processedImage struct {
+	ContentType   string
+	Data          []byte
+	Thumbnail     []byte
+	ThumbnailType string
+	Width, Height int
+}
+
+// processImage checks that data is a supported image and re-encodes it, which
+// drops its EXIF and any other metadata. JPEGs are turned upright according
+// to their EXIF orientation first, since that is lost with the metadata.
+func processImage(data []byte) (*processedImage, error) {
+	if int64(len(data)) > MaxUploadSize {
+		return nil, ErrMediaTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := mediaExtensions[contentType]; !ok {
+		return nil, ErrUnsupportedMedia
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedMedia
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrMediaTooLarge
+	}
+
+	var img image.Image
+	var out bytes.Buffer
+	switch contentType {
+	case "image/gif":
+		frames, pixels, err := gifFrames(data)
+		if err != nil {
+			return nil, err
+		}
+		if frames > maxGIFFrames || pixels > maxImagePixels {
+			return nil, ErrMediaTooLarge
+		}
+
+		// re-encoding every frame keeps animations while dropping comments
+		// and application extensions
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedMedia
+		}
+		if err := gif.EncodeAll(&out, g); err != nil {
+			return nil, err
+		}
+		img = g.Image[0]
+	case "image/png":
+		if img, err = png.Decode(bytes.NewReader(data)); err != nil {
+			return nil, ErrUnsupportedMedia
+		}
+		if err := png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+	case "image/jpeg":
+		if img, err = jpeg.Decode(bytes.NewReader(data)); err != nil {
+			return nil, ErrUnsupportedMedia
+		}
+		img = orient(img, jpegOrientation(data))
+		if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, err
+		}
+	}
+
+	var thumb bytes.Buffer
+	thumbType := "image/png"
+	if contentType == "image/jpeg" {
+		thumbType = contentType
+		err = jpeg.Encode(&thumb, thumbnail(img, thumbnailSize), &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&thumb, thumbnail(img, thumbnailSize))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	return &processedImage{
+		ContentType:   contentType,
+		Data:          out.Bytes(),
+		Thumbnail:     thumb.Bytes(),
+		ThumbnailType: thumbType,
+		Width:         b.Dx(),
+		Height:        b.Dy(),
+	}, nil
+}
+
+// gifFrames walks the blocks of a GIF without decoding them and returns how
+// many frames it holds and how many pixels those frames add up to, so that
+// animations too large to decode can be turned away first
+func gifFrames(data []byte) (frames, pixels int, err error) {
+	// the header and the logical screen descriptor come first, followed by
+	// the global color table if there is one
+	if len(data) < 13 {
+		return 0, 0, ErrUnsupportedMedia
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (uint(data[10]&0x07) + 1)
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x21:
+			// an extension: its label and then its data
+			i += 2
+		case 0x2C:
+			// an image descriptor, the local color table if there is one, the
+			// LZW code size and then the image data
+			if i+10 > len(data) {
+				return 0, 0, ErrUnsupportedMedia
+			}
+			w := int(binary.LittleEndian.Uint16(data[i+5:]))
+			h := int(binary.LittleEndian.Uint16(data[i+7:]))
+			frames++
+			pixels += w * h
+
+			packed := data[i+9]
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << (uint(packed&0x07) + 1)
+			}
+			i++
+		case 0x3B:
+			// the trailer
+			return frames, pixels, nil
+		default:
+			return 0, 0, ErrUnsupportedMedia
+		}
+
+		// data comes in sub-blocks led by their length, ending with an empty one
+		for {
+			if i >= len(data) {
+				return 0, 0, ErrUnsupportedMedia
+			}
+			n := int(data[i])
+			i += 1 + n
+			if n == 0 {
+				break
+			}
+		}
+	}
+	return frames, pixels, nil
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, from 1 to 8, or 1
+// if it has none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			// the image data starts without any EXIF
+			return 1
+		}
+
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from the first IFD of the TIFF
+// structure holding the EXIF
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+	}
+	return 1
+}
+
+// orient transforms img so that it displays upright given its EXIF
+// orientation
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if orientation >= 5 {
+		// orientations 5 to 8 swap the sides
+		w, h = h, w
+	}
+
+	at := rgbaReader(img)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := x, y
+			switch orientation {
+			case 2:
+				sx = w - 1 - x
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sy = h - 1 - y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, w-1-x
+			case 7:
+				sx, sy = h-1-y, w-1-x
+			case 8:
+				sx, sy = h-1-y, x
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = at(b.Min.X+sx, b.Min.Y+sy)
+		}
+	}
+	return dst
+}
+
+// thumbnail scales img down to fit in a size by size square, averaging the
+// pixels each thumbnail pixel covers. Images that already fit are only copied.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w >= h && w > size {
+		w, h = size, h*size/w
+	} else if h > w && h > size {
+		w, h = w*size/h, size
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	at := rgbaReader(img)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := at(sx, sy)
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(bl/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+// rgbaReader returns a function reading the alpha-premultiplied color of a
+// pixel of img. The image types the decoders return are read straight from
+// their pixel data, since going through At allocates for every pixel.
+func rgbaReader(img image.Image) func(x, y int) (r, g, b, a uint8) {
+	switch m := img.(type) {
+	case *image.RGBA:
+		return func(x, y int) (uint8, uint8, uint8, uint8) {
+			i := m.PixOffset(x, y)
+			return m.Pix[i], m.Pix[i+1], m.Pix[i+2], m.Pix[i+3]
+		}
+	case *image.NRGBA:
+		return func(x, y int) (uint8, uint8, uint8, uint8) {
+			i := m.PixOffset(x, y)
+			a := uint32(m.Pix[i+3])
+			return uint8(uint32(m.Pix[i]) * a / 0xFF), uint8(uint32(m.Pix[i+1]) * a / 0xFF),
+				uint8(uint32(m.Pix[i+2]) * a / 0xFF), uint8(a)
+		}
+	case *image.YCbCr:
+		return func(x, y int) (uint8, uint8, uint8, uint8) {
+			c := m.COffset(x, y)
+			r, g, b := color.YCbCrToRGB(m.Y[m.YOffset(x, y)], m.Cb[c], m.Cr[c])
+			return r, g, b, 0xFF
+		}
+	case *image.Gray:
+		return func(x, y int) (uint8, uint8, uint8, uint8) {
+			v := m.Pix[m.PixOffset(x, y)]
+			return v, v, v, 0xFF
+		}
+	case *image.Paletted:
+		palette := make([][4]uint8, len(m.Palette))
+		for i, c := range m.Palette {
+			r, g, b, a := c.RGBA()
+			palette[i] = [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+		}
+		return func(x, y int) (uint8, uint8, uint8, uint8) {
+			i := int(m.Pix[m.PixOffset(x, y)])
+			if i >= len(palette) {
+				return 0, 0, 0, 0
+			}
+			c := palette[i]
+			return c[0], c[1], c[2], c[3]
+		}
+	}
+
+	return func(x, y int) (uint8, uint8, uint8, uint8) {
+		r, g, b, a := img.At(x, y).RGBA()
+		return uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)
+	}
+}
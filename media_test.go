@@ -0,0 +1,216 @@
+package blog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(img image.Image) []byte {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// encodeJPEG returns img as a JPEG whose EXIF holds the given orientation and
+// some data that must not survive processing
+func encodeJPEG(img image.Image, orientation uint16, order binary.ByteOrder) []byte {
+	var buf bytes.Buffer
+	_ = jpeg.Encode(&buf, img, nil)
+	data := buf.Bytes()
+
+	tiff := []byte("MM")
+	if order == binary.LittleEndian {
+		tiff = []byte("II")
+	}
+	ifd := make([]byte, 20)
+	order.PutUint16(ifd[0:], 1)
+	order.PutUint16(ifd[2:], 0x0112)
+	order.PutUint16(ifd[4:], 3)
+	order.PutUint32(ifd[6:], 1)
+	order.PutUint16(ifd[10:], orientation)
+	header := make([]byte, 6)
+	order.PutUint16(header[0:], 42)
+	order.PutUint32(header[2:], 8)
+	tiff = append(append(append(tiff, header...), ifd...), []byte("GPS 6.5244N 3.3792E")...)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(segment)+2))
+
+	out := append([]byte{0xFF, 0xD8, 0xFF, 0xE1}, length...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessImage(t *testing.T) {
+	defer func(max int64) { MaxUploadSize = max }(MaxUploadSize)
+
+	_, err := processImage([]byte("just some text"))
+	assert.Equal(t, ErrUnsupportedMedia, err)
+
+	_, err = processImage(append([]byte("\x89PNG\r\n\x1a\n"), "broken"...))
+	assert.Equal(t, ErrUnsupportedMedia, err)
+
+	large := encodePNG(newTestImage(640, 320))
+	MaxUploadSize = int64(len(large) - 1)
+	_, err = processImage(large)
+	assert.Equal(t, ErrMediaTooLarge, err)
+	MaxUploadSize = 5 << 20
+
+	img, err := processImage(large)
+	assert.Nil(t, err)
+	assert.Equal(t, "image/png", img.ContentType)
+	assert.Equal(t, "image/png", img.ThumbnailType)
+	assert.Equal(t, 640, img.Width)
+	assert.Equal(t, 320, img.Height)
+	thumb, _ := png.DecodeConfig(bytes.NewReader(img.Thumbnail))
+	assert.Equal(t, 320, thumb.Width)
+	assert.Equal(t, 160, thumb.Height)
+
+	data := encodeJPEG(newTestImage(40, 20), 6, binary.LittleEndian)
+	assert.True(t, bytes.Contains(data, []byte("GPS")))
+	img, err = processImage(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "image/jpeg", img.ContentType)
+	assert.False(t, bytes.Contains(img.Data, []byte("Exif")))
+	assert.False(t, bytes.Contains(img.Data, []byte("GPS")))
+	assert.Equal(t, 20, img.Width)
+	assert.Equal(t, 40, img.Height)
+
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			image.NewPaletted(image.Rect(0, 0, 10, 10), color.Palette{color.White, color.Black}),
+			image.NewPaletted(image.Rect(0, 0, 10, 10), color.Palette{color.White, color.Black}),
+		},
+		Delay: []int{10, 10},
+	}
+	var buf bytes.Buffer
+	_ = gif.EncodeAll(&buf, g)
+	img, err = processImage(buf.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, "image/gif", img.ContentType)
+	frames, _ := gif.DecodeAll(bytes.NewReader(img.Data))
+	assert.Equal(t, 2, len(frames.Image))
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	img := newTestImage(4, 2)
+	var plain bytes.Buffer
+	_ = jpeg.Encode(&plain, img, nil)
+
+	assert.Equal(t, 1, jpegOrientation(plain.Bytes()))
+	assert.Equal(t, 1, jpegOrientation([]byte("not a jpeg")))
+	assert.Equal(t, 6, jpegOrientation(encodeJPEG(img, 6, binary.LittleEndian)))
+	assert.Equal(t, 8, jpegOrientation(encodeJPEG(img, 8, binary.BigEndian)))
+	assert.Equal(t, 1, jpegOrientation(encodeJPEG(img, 9, binary.BigEndian)))
+}
+
+func TestOrient(t *testing.T) {
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, red)
+	img.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		want        []color.Color
+		w, h        int
+	}{
+		{orientation: 1, want: []color.Color{red, blue}, w: 2, h: 1},
+		{orientation: 2, want: []color.Color{blue, red}, w: 2, h: 1},
+		{orientation: 3, want: []color.Color{blue, red}, w: 2, h: 1},
+		{orientation: 5, want: []color.Color{red, blue}, w: 1, h: 2},
+		{orientation: 6, want: []color.Color{red, blue}, w: 1, h: 2},
+		{orientation: 7, want: []color.Color{blue, red}, w: 1, h: 2},
+		{orientation: 8, want: []color.Color{blue, red}, w: 1, h: 2},
+	}
+
+	for _, tt := range tests {
+		got := orient(img, tt.orientation)
+		b := got.Bounds()
+		assert.Equal(t, tt.w, b.Dx(), tt.orientation)
+		assert.Equal(t, tt.h, b.Dy(), tt.orientation)
+
+		var colors []color.Color
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				colors = append(colors, color.RGBAModel.Convert(got.At(x, y)))
+			}
+		}
+		assert.Equal(t, tt.want, colors, tt.orientation)
+	}
+}
+
+func encodeGIF(frames, w, h int) []byte {
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.White, color.Black}))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	_ = gif.EncodeAll(&buf, g)
+	return buf.Bytes()
+}
+
+func TestGIFFrames(t *testing.T) {
+	data := encodeGIF(3, 10, 20)
+	frames, pixels, err := gifFrames(data)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, frames)
+	assert.Equal(t, 600, pixels)
+
+	_, _, err = gifFrames(data[:len(data)/2])
+	assert.Equal(t, ErrUnsupportedMedia, err)
+
+	_, err = processImage(encodeGIF(maxGIFFrames+1, 1, 1))
+	assert.Equal(t, ErrMediaTooLarge, err)
+}
+
+func TestRGBAReader(t *testing.T) {
+	src := newTestImage(16, 8)
+	nrgba := image.NewNRGBA(src.Bounds())
+	gray := image.NewGray(src.Bounds())
+	paletted := image.NewPaletted(src.Bounds(), color.Palette{color.Black, color.White, color.RGBA{R: 100, A: 128}})
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			nrgba.Set(x, y, color.NRGBA{R: uint8(x * 16), G: uint8(y * 32), B: 7, A: uint8(x * y * 2)})
+			gray.Set(x, y, src.At(x, y))
+			paletted.SetColorIndex(x, y, uint8((x+y)%3))
+		}
+	}
+	ycbcr, _ := jpeg.Decode(bytes.NewReader(encodeJPEG(src, 1, binary.BigEndian)))
+
+	for _, img := range []image.Image{src, nrgba, gray, paletted, ycbcr, image.NewCMYK(src.Bounds())} {
+		at := rgbaReader(img)
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, a := img.At(x, y).RGBA()
+				gr, gg, gb, ga := at(x, y)
+				// readers work in 8 bits, so rounding may be off by one
+				assert.InDelta(t, r>>8, gr, 1, "%T", img)
+				assert.InDelta(t, g>>8, gg, 1, "%T", img)
+				assert.InDelta(t, bl>>8, gb, 1, "%T", img)
+				assert.InDelta(t, a>>8, ga, 1, "%T", img)
+			}
+		}
+	}
+}
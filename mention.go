@@ -0,0 +1,29 @@
+package blog
+
+// maxMentions caps the number of users a single post can mention, which
+// bounds the lookups needed to resolve them
+const maxMentions = 10
+
+// Mention is a user mentioned in a post along with the username they were
+// mentioned by, which is kept as written even if the user is later renamed.
+type Mention struct {
+	UserID   ID `bson:"user_id"`
+	Username string
+}
+
+// mentionedUsernames returns the usernames mentioned in body in the order they
+// first appear, without duplicates and up to maxMentions of them.
+func mentionedUsernames(body string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, e := range tokenize(body) {
+		if len(names) == maxMentions {
+			break
+		}
+		if e.Type == EntityMention && !seen[e.Value] {
+			seen[e.Value] = true
+			names = append(names, e.Value)
+		}
+	}
+	return names
+}
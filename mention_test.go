@@ -0,0 +1,29 @@
+package blog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMentionedUsernames(t *testing.T) {
+	many := strings.Repeat("@a @b @c @d @e @f @g @h @i @j ", 2) + "@k"
+
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "no mentions", want: nil},
+		{body: "@user", want: []string{"user"}},
+		{body: "hi @user1, @user_2 and @user1!", want: []string{"user1", "user_2"}},
+		{body: "mail me@example.com or @@user", want: nil},
+		{body: "(@first) @first@second", want: []string{"first"}},
+		{body: "@" + strings.Repeat("a", 25), want: nil},
+		{body: many, want: []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, mentionedUsernames(tt.body), tt.body)
+	}
+}
@@ -2,12 +2,16 @@ package blog
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type mongoUserRepository struct {
@@ -15,7 +19,64 @@ type mongoUserRepository struct {
 }
 
 func NewMongoUserRepository(c *mongo.Collection) Repository {
-	return &mongoUserRepository{collection: c}
+	repo := &mongoUserRepository{collection: c}
+	_ = repo.createIndexes()
+	_ = repo.backfillSearchFields()
+	return repo
+}
+
+// mongoUser is a user as stored, along with the fields user search looks up
+// and sorts on
+type mongoUser struct {
+	User          `bson:",inline"`
+	UsernameLower string `bson:"username_lower"`
+	FollowerCount int    `bson:"follower_count"`
+}
+
+func newMongoUser(u *User) mongoUser {
+	return mongoUser{User: *u, UsernameLower: strings.ToLower(u.Username), FollowerCount: len(u.Followers)}
+}
+
+func (m *mongoUserRepository) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username_lower", Value: 1}, {Key: "follower_count", Value: -1}}},
+		{Keys: bson.M{"follower_count": -1}},
+	})
+	return err
+}
+
+// backfillSearchFields adds the search fields to the users stored before
+// they existed
+func (m *mongoUserRepository) backfillSearchFields() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := m.collection.Find(ctx, bson.M{"username_lower": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var models []mongo.WriteModel
+	for cursor.Next(ctx) {
+		var u User
+		if err := cursor.Decode(&u); err != nil {
+			return err
+		}
+		mu := newMongoUser(&u)
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": u.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"username_lower": mu.UsernameLower, "follower_count": mu.FollowerCount}}))
+	}
+	if err := cursor.Err(); err != nil || len(models) < 1 {
+		return err
+	}
+
+	_, err = m.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
 }
 
 func (m *mongoUserRepository) FindByName(username string) (*User, error) {
@@ -34,7 +95,7 @@ func (m *mongoUserRepository) Update(u *User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := m.collection.ReplaceOne(ctx, bson.M{"_id": u.ID}, u)
+	_, err := m.collection.ReplaceOne(ctx, bson.M{"_id": u.ID}, newMongoUser(u))
 	return err
 }
 
@@ -42,7 +103,7 @@ func (m *mongoUserRepository) Store(u *User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := m.collection.InsertOne(ctx, &u)
+	_, err := m.collection.InsertOne(ctx, newMongoUser(u))
 	return err
 }
 
@@ -56,9 +117,7 @@ func (m *mongoUserRepository) FindByIDs(ids []ID) ([]User, error) {
 
 	friends := []User{}
 
-	filter := bson.D{{"_id", bson.D{
-		{"$in", ids},
-	}}}
+	filter := bson.M{"_id": bson.M{"$in": ids}}
 
 	cursor, err := m.collection.Find(ctx, filter)
 	if err != nil {
@@ -77,6 +136,95 @@ func (m *mongoUserRepository) FindByIDs(ids []ID) ([]User, error) {
 	return friends, nil
 }
 
+// Search looks up the users sharing the first letters that matchScore needs to
+// see in a username or bio word and ranks them. The usernames starting with
+// q, the best matches, are looked up first on the index of lower case
+// usernames, and the rest of the candidates fill in the room left. Either
+// way the most followed are kept when there are too many.
+func (m *mongoUserRepository) Search(q string, limit int) ([]User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prefix := func(s string) primitive.Regex {
+		return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(s)}
+	}
+
+	users, err := m.findCandidates(ctx, bson.M{"username_lower": prefix(q)}, maxUserSearchCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	name, word := []rune(q), []rune(q)
+	if len(name) >= 3 {
+		// longer searches can be misspelled after their first letters
+		name, word = name[:1], word[:2]
+	}
+
+	if room := maxUserSearchCandidates - len(users); room > 0 {
+		found := make([]ID, len(users))
+		for i, u := range users {
+			found[i] = u.ID
+		}
+
+		filter := bson.M{"_id": bson.M{"$nin": found}, "$or": []bson.M{
+			{"username_lower": prefix(string(name))},
+			{"bio": primitive.Regex{Pattern: `(^|[^\p{L}\p{N}])` + regexp.QuoteMeta(string(word)), Options: "i"}},
+		}}
+		more, err := m.findCandidates(ctx, filter, room)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, more...)
+	}
+	return rankUsers(users, q, limit), nil
+}
+
+// findCandidates returns up to limit of the users matching filter, the most
+// followed first
+func (m *mongoUserRepository) findCandidates(ctx context.Context, filter bson.M, limit int) ([]User, error) {
+	opts := options.Find().SetSort(bson.M{"follower_count": -1}).SetLimit(int64(limit))
+	cursor, err := m.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []User{}
+	for cursor.Next(ctx) {
+		var u User
+		if err := cursor.Decode(&u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, cursor.Err()
+}
+
+func (m *mongoUserRepository) FindPopular(ids []ID, followers int) ([]ID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a user has more than n followers when their followers have an element n
+	filter := bson.M{"_id": bson.M{"$in": ids}, fmt.Sprintf("followers.%d", followers): bson.M{"$exists": true}}
+	cursor, err := m.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	popular := []ID{}
+	for cursor.Next(ctx) {
+		var u struct {
+			ID ID `bson:"_id"`
+		}
+		if err := cursor.Decode(&u); err != nil {
+			return nil, err
+		}
+		popular = append(popular, u.ID)
+	}
+	return popular, cursor.Err()
+}
+
 func (m *mongoUserRepository) findUserBy(key string, val string) (*User, error) {
 	var u User
 
@@ -100,41 +248,1127 @@ type mongoPostRepository struct {
 }
 
 func NewMongoPostRepository(c *mongo.Collection) PostRepository {
-	return &mongoPostRepository{collection: c}
+	repo := &mongoPostRepository{collection: c}
+	_ = repo.createIndexes()
+	return repo
+}
+
+// published matches the posts that aren't scheduled
+var published = bson.M{"$ne": PostScheduled}
+
+func (m *mongoPostRepository) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "author.user_id", Value: 1}, {Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.M{"conversation_id": 1}},
+		{Keys: bson.M{"in_reply_to": 1}},
+		{Keys: bson.M{"repost_of": 1}},
+		{Keys: bson.D{{Key: "tags", Value: 1}, {Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "mentions.user_id", Value: 1}, {Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}},
+		// the feed order, which explore pages through within its window
+		{Keys: bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "body", Value: "text"}},
+			Options: options.Index().SetDefaultLanguage("english"),
+		},
+		{
+			Keys:    bson.M{"publish_at": 1},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"state": PostScheduled}),
+		},
+		{
+			// a user can only repost a post once
+			Keys: bson.D{{Key: "author.user_id", Value: 1}, {Key: "repost_of", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"kind": KindRepost}),
+		},
+	})
+	return err
 }
 
 func (m *mongoPostRepository) FindByID(id PostID) (Post, error) {
-	return Post{}, errors.New("implement me")
+	return m.findOne(bson.M{"_id": id})
 }
 
 func (m *mongoPostRepository) Store(post Post) error {
 	_, err := m.collection.InsertOne(context.TODO(), &post)
+	if post.IsRepost() && isDuplicateKeyError(err) {
+		return ErrAlreadyReposted
+	}
 	return err
 }
 
-func (m *mongoPostRepository) FindLatestPostsForUser(id ID) ([]*Post, error) {
-	filter := bson.D{
-		{"author.user_id", id},
+func (m *mongoPostRepository) FindByIDs(ids []PostID) ([]*Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (m *mongoPostRepository) Update(post Post) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.collection.ReplaceOne(ctx, bson.M{"_id": post.ID}, post)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+func (m *mongoPostRepository) Delete(id PostID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+func (m *mongoPostRepository) FindLatestPostsForUser(id ID, page Page) ([]*Post, error) {
+	return m.findPage(bson.M{"author.user_id": id, "state": published}, page)
+}
+
+func (m *mongoPostRepository) FindLatestPostsForUserAndFriends(user *User, limit int) ([]*Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids := append([]ID{user.ID}, user.Friends...)
+	filter := bson.M{"author.user_id": bson.M{"$in": ids}, "state": published}
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	return m.find(ctx, filter, opts)
+}
+
+func (m *mongoPostRepository) FindByConversation(id PostID) ([]*Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.find(ctx, bson.M{"conversation_id": id, "state": published})
+}
+
+func (m *mongoPostRepository) CountReplies(ids []PostID) (map[PostID]int, error) {
+	return m.countBy("in_reply_to", ids)
+}
+
+func (m *mongoPostRepository) FindRepost(id ID, postID PostID) (Post, error) {
+	return m.findOne(bson.M{"kind": KindRepost, "author.user_id": id, "repost_of": postID})
+}
+
+func (m *mongoPostRepository) CountReposts(ids []PostID) (map[PostID]int, error) {
+	return m.countBy("repost_of", ids)
+}
+
+func (m *mongoPostRepository) FindByTag(tag string, page Page) ([]*Post, error) {
+	return m.findPage(bson.M{"tags": tag, "state": published}, page)
+}
+
+func (m *mongoPostRepository) FindMentions(id ID, page Page) ([]*Post, error) {
+	return m.findPage(bson.M{"mentions.user_id": id, "state": published}, page)
+}
+
+func (m *mongoPostRepository) FindPublic(since time.Time, page Page) ([]*Post, error) {
+	return m.findPage(bson.M{
+		"timestamp":  bson.M{"$gte": since},
+		"state":      published,
+		"kind":       bson.M{"$ne": KindRepost},
+		"visibility": bson.M{"$in": []interface{}{nil, VisibilityPublic}},
+	}, page)
+}
+
+func (m *mongoPostRepository) FindScheduled(id ID) ([]*Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"publish_at": 1})
+	return m.find(ctx, bson.M{"author.user_id": id, "state": PostScheduled}, opts)
+}
+
+func (m *mongoPostRepository) FindDue(t time.Time) ([]*Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"publish_at": 1})
+	return m.find(ctx, bson.M{"state": PostScheduled, "publish_at": bson.M{"$lte": t}}, opts)
+}
+
+func (m *mongoPostRepository) Publish(id PostID, at time.Time) (Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// only the publisher that still finds the post scheduled publishes it
+	var p Post
+	err := m.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "state": PostScheduled},
+		bson.M{"$set": bson.M{"timestamp": at}, "$unset": bson.M{"state": "", "publish_at": ""}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&p)
+	if err == mongo.ErrNoDocuments {
+		return Post{}, ErrNotScheduled
+	}
+	return p, err
+}
+
+// Search runs q as a Mongo text search, which stems and drops stop words on
+// its own, so only the words and phrases as typed are handed over
+func (m *mongoPostRepository) Search(q SearchQuery, page SearchPage) ([]*Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a leading - would exclude the word rather than look for it
+	var terms []string
+	for _, w := range q.Words {
+		if w = strings.TrimLeft(w, "-"); w != "" {
+			terms = append(terms, w)
+		}
+	}
+	for _, p := range q.Phrases {
+		terms = append(terms, `"`+p+`"`)
+	}
+
+	filter := bson.M{"$text": bson.M{"$search": strings.Join(terms, " ")}, "state": published}
+	if q.Author != "" {
+		filter["author.user_id"] = q.Author
+	}
+
+	// the rules of Post.IsVisibleTo, applied before skipping so that offsets
+	// only count the posts the viewer can read
+	visible := bson.A{bson.M{"visibility": bson.M{"$in": bson.A{nil, VisibilityPublic}}}}
+	if q.Viewer != "" {
+		following := append([]ID{}, q.Following...)
+		visible = append(visible,
+			bson.M{"author.user_id": q.Viewer},
+			bson.M{"mentions.user_id": q.Viewer},
+			bson.M{"visibility": VisibilityFollowers, "author.user_id": bson.M{"$in": following}},
+		)
+	}
+	filter["$or"] = visible
+
+	timestamp := bson.M{}
+	if !q.Since.IsZero() {
+		timestamp["$gte"] = q.Since
+	}
+	if !q.Until.IsZero() {
+		timestamp["$lt"] = q.Until
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().SetSkip(int64(page.Offset))
+	if page.Order == SearchByRelevance {
+		opts.SetProjection(bson.M{"score": score}).
+			SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}})
+	} else {
+		opts.SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}})
+	}
+	if page.Limit > 0 {
+		opts.SetLimit(int64(page.Limit))
+	}
+
+	return m.find(ctx, filter, opts)
+}
+
+// countBy counts the public posts whose field holds each of the given ids
+func (m *mongoPostRepository) countBy(field string, ids []PostID) (map[PostID]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts := map[PostID]int{}
+	for _, id := range ids {
+		counts[id] = 0
+	}
+
+	pipeline := []bson.M{
+		{"$match": bson.M{
+			field:        bson.M{"$in": ids},
+			"state":      published,
+			"visibility": bson.M{"$in": bson.A{nil, VisibilityPublic}},
+		}},
+		{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+	}
+
+	cursor, err := m.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var c struct {
+			ID    PostID `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cursor.Decode(&c); err != nil {
+			return nil, err
+		}
+		counts[c.ID] = c.Count
+	}
+	return counts, cursor.Err()
+}
+
+func (m *mongoPostRepository) findOne(filter bson.M) (Post, error) {
+	var p Post
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sr := m.collection.FindOne(ctx, filter)
+
+	if sr.Err() == mongo.ErrNoDocuments {
+		return Post{}, ErrPostNotFound
+	}
+
+	if err := sr.Decode(&p); err != nil {
+		return Post{}, err
+	}
+
+	return p, nil
+}
+
+// findPage returns the posts matching filter within page, newest first
+func (m *mongoPostRepository) findPage(filter bson.M, page Page) ([]*Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	order := -1
+	if page.Before != "" {
+		filter["$or"] = m.beyondCursor("$lt", page.Before)
+	}
+	if page.After != "" {
+		// sort ascending so the limit keeps the posts closest to the cursor
+		filter["$or"] = m.beyondCursor("$gt", page.After)
+		order = 1
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: order}, {Key: "_id", Value: order}})
+	if page.Limit > 0 {
+		opts.SetLimit(int64(page.Limit))
+	}
+
+	posts, err := m.find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if order == 1 {
+		for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
+		}
+	}
+	return posts, nil
+}
+
+// beyondCursor matches the posts older ($lt) or newer ($gt) than the cursor
+func (m *mongoPostRepository) beyondCursor(op string, cursor string) bson.A {
+	c := cursorPost(cursor, m.FindByID)
+	return bson.A{
+		bson.M{"timestamp": bson.M{op: c.Timestamp}},
+		bson.M{"timestamp": c.Timestamp, "_id": bson.M{op: c.ID}},
 	}
+}
 
-	cursor, err := m.collection.Find(context.TODO(), filter)
+func (m *mongoPostRepository) find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]*Post, error) {
+	cursor, err := m.collection.Find(ctx, filter, opts...)
 	if err != nil {
 		return nil, err
 	}
+	defer cursor.Close(ctx)
 
 	posts := []*Post{}
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 		var p Post
-		err := cursor.Decode(&p)
-		if err != nil {
+		if err := cursor.Decode(&p); err != nil {
 			return nil, err
 		}
 		posts = append(posts, &p)
 	}
 
-	return posts, nil
+	return posts, cursor.Err()
+}
+
+type mongoReactionRepository struct {
+	collection *mongo.Collection
+}
+
+// mongoReaction keys a reaction so the same user can't store it twice
+type mongoReaction struct {
+	ID       string `bson:"_id"`
+	Reaction `bson:",inline"`
+}
+
+func NewMongoReactionRepository(c *mongo.Collection) ReactionRepository {
+	repo := &mongoReactionRepository{collection: c}
+	_ = repo.createIndexes()
+	return repo
+}
+
+func (m *mongoReactionRepository) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"post_id": 1}},
+		{Keys: bson.M{"user_id": 1}},
+	})
+	return err
 }
 
-func (m *mongoPostRepository) FindLatestPostsForUserAndFriends(user *User) ([]*Post, error) {
-	return nil, errors.New("implement me")
+func (m *mongoReactionRepository) Store(r Reaction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.InsertOne(ctx, mongoReaction{ID: r.key(), Reaction: r})
+	if isDuplicateKeyError(err) {
+		return ErrAlreadyReacted
+	}
+	return err
+}
+
+func (m *mongoReactionRepository) Delete(postID PostID, userID ID, t ReactionType) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": reactionKey(postID, userID, t)})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrNotReacted
+	}
+	return nil
+}
+
+func (m *mongoReactionRepository) DeleteByPost(postID PostID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.DeleteMany(ctx, bson.M{"post_id": postID})
+	return err
+}
+
+func (m *mongoReactionRepository) FindByPost(postID PostID) ([]Reaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"timestamp": -1})
+	return m.find(ctx, bson.M{"post_id": postID}, opts)
+}
+
+func (m *mongoReactionRepository) CountByPosts(ids []PostID) (map[PostID]map[ReactionType]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts := map[PostID]map[ReactionType]int{}
+	for _, id := range ids {
+		counts[id] = map[ReactionType]int{}
+	}
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"post_id": bson.M{"$in": ids}}},
+		{"$group": bson.M{
+			"_id":   bson.M{"post_id": "$post_id", "type": "$type"},
+			"count": bson.M{"$sum": 1},
+		}},
+	}
+
+	cursor, err := m.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var c struct {
+			ID struct {
+				PostID PostID       `bson:"post_id"`
+				Type   ReactionType `bson:"type"`
+			} `bson:"_id"`
+			Count int `bson:"count"`
+		}
+		if err := cursor.Decode(&c); err != nil {
+			return nil, err
+		}
+		if _, ok := counts[c.ID.PostID]; ok {
+			counts[c.ID.PostID][c.ID.Type] = c.Count
+		}
+	}
+	return counts, cursor.Err()
+}
+
+func (m *mongoReactionRepository) FindByUser(userID ID, ids []PostID) (map[PostID][]ReactionType, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reactions, err := m.find(ctx, bson.M{"user_id": userID, "post_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+
+	types := map[PostID][]ReactionType{}
+	for _, r := range reactions {
+		types[r.PostID] = append(types[r.PostID], r.Type)
+	}
+	return types, nil
+}
+
+func (m *mongoReactionRepository) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]Reaction, error) {
+	cursor, err := m.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reactions := []Reaction{}
+	for cursor.Next(ctx) {
+		var r Reaction
+		if err := cursor.Decode(&r); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, r)
+	}
+	return reactions, cursor.Err()
+}
+
+type mongoVoteRepository struct {
+	collection *mongo.Collection
+}
+
+// mongoVote keys a vote by poll and user, which makes the insert of a second
+// vote by the same user fail even when both are sent at once
+type mongoVote struct {
+	ID   string `bson:"_id"`
+	Vote `bson:",inline"`
+}
+
+func NewMongoVoteRepository(c *mongo.Collection) VoteRepository {
+	repo := &mongoVoteRepository{collection: c}
+	_ = repo.createIndexes()
+	return repo
+}
+
+func (m *mongoVoteRepository) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"post_id": 1}},
+		{Keys: bson.M{"user_id": 1}},
+	})
+	return err
+}
+
+func (m *mongoVoteRepository) Store(v Vote) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.InsertOne(ctx, mongoVote{ID: v.key(), Vote: v})
+	if isDuplicateKeyError(err) {
+		return ErrAlreadyVoted
+	}
+	return err
+}
+
+func (m *mongoVoteRepository) DeleteByPost(postID PostID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.DeleteMany(ctx, bson.M{"post_id": postID})
+	return err
+}
+
+func (m *mongoVoteRepository) CountByPosts(ids []PostID) (map[PostID]map[int]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts := map[PostID]map[int]int{}
+	for _, id := range ids {
+		counts[id] = map[int]int{}
+	}
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"post_id": bson.M{"$in": ids}}},
+		{"$group": bson.M{
+			"_id":   bson.M{"post_id": "$post_id", "choice": "$choice"},
+			"count": bson.M{"$sum": 1},
+		}},
+	}
+
+	cursor, err := m.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var c struct {
+			ID struct {
+				PostID PostID `bson:"post_id"`
+				Choice int    `bson:"choice"`
+			} `bson:"_id"`
+			Count int `bson:"count"`
+		}
+		if err := cursor.Decode(&c); err != nil {
+			return nil, err
+		}
+		if _, ok := counts[c.ID.PostID]; ok {
+			counts[c.ID.PostID][c.ID.Choice] = c.Count
+		}
+	}
+	return counts, cursor.Err()
+}
+
+func (m *mongoVoteRepository) FindByUser(userID ID, ids []PostID) (map[PostID]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := m.collection.Find(ctx, bson.M{"user_id": userID, "post_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	choices := map[PostID]int{}
+	for cursor.Next(ctx) {
+		var v Vote
+		if err := cursor.Decode(&v); err != nil {
+			return nil, err
+		}
+		choices[v.PostID] = v.Choice
+	}
+	return choices, cursor.Err()
+}
+
+type mongoAttachmentRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAttachmentRepository(c *mongo.Collection) AttachmentRepository {
+	repo := &mongoAttachmentRepository{collection: c}
+	_ = repo.createIndexes()
+	return repo
+}
+
+func (m *mongoAttachmentRepository) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"post_id": 1}},
+		{Keys: bson.M{"created_at": 1}},
+	})
+	return err
+}
+
+func (m *mongoAttachmentRepository) Store(a Attachment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.InsertOne(ctx, &a)
+	return err
+}
+
+func (m *mongoAttachmentRepository) FindByIDs(ids []AttachmentID) ([]Attachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (m *mongoAttachmentRepository) FindByPost(postID PostID) ([]Attachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.find(ctx, bson.M{"post_id": postID})
+}
+
+func (m *mongoAttachmentRepository) Attach(owner ID, ids []AttachmentID, postID PostID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": bson.M{"$in": ids}, "owner": owner, "post_id": bson.M{"$exists": false}}
+	res, err := m.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"post_id": postID}})
+	if err != nil {
+		return err
+	}
+
+	if res.ModifiedCount < int64(len(ids)) {
+		// give back the ones we took so they can be used or collected
+		undo := bson.M{"_id": bson.M{"$in": ids}, "post_id": postID}
+		if _, err := m.collection.UpdateMany(ctx, undo, bson.M{"$unset": bson.M{"post_id": ""}}); err != nil {
+			return err
+		}
+		return ErrInvalidAttachment
+	}
+	return nil
+}
+
+func (m *mongoAttachmentRepository) FindOrphans(before time.Time) ([]Attachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.find(ctx, bson.M{"post_id": bson.M{"$exists": false}, "created_at": bson.M{"$lt": before}})
+}
+
+func (m *mongoAttachmentRepository) DeleteOrphan(id AttachmentID) error {
+	return m.deleteOne(bson.M{"_id": id, "post_id": bson.M{"$exists": false}})
+}
+
+func (m *mongoAttachmentRepository) Delete(id AttachmentID) error {
+	return m.deleteOne(bson.M{"_id": id})
+}
+
+func (m *mongoAttachmentRepository) deleteOne(filter bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
+
+func (m *mongoAttachmentRepository) find(ctx context.Context, filter bson.M) ([]Attachment, error) {
+	cursor, err := m.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var attachments []Attachment
+	for cursor.Next(ctx) {
+		var a Attachment
+		if err := cursor.Decode(&a); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, cursor.Err()
+}
+
+type mongoDraftRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDraftRepository(c *mongo.Collection) DraftRepository {
+	repo := &mongoDraftRepository{collection: c}
+	_ = repo.createIndexes()
+	return repo
+}
+
+func (m *mongoDraftRepository) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "author", Value: 1}, {Key: "updated_at", Value: -1}},
+	})
+	return err
+}
+
+func (m *mongoDraftRepository) Store(d Draft) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.InsertOne(ctx, &d)
+	return err
+}
+
+func (m *mongoDraftRepository) Update(d Draft) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.collection.ReplaceOne(ctx, bson.M{"_id": d.ID}, &d)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return ErrDraftNotFound
+	}
+	return nil
+}
+
+func (m *mongoDraftRepository) FindByID(id DraftID) (Draft, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var d Draft
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return Draft{}, ErrDraftNotFound
+	}
+	return d, err
+}
+
+func (m *mongoDraftRepository) FindByAuthor(id ID) ([]Draft, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := m.collection.Find(ctx, bson.M{"author": id}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	drafts := []Draft{}
+	for cursor.Next(ctx) {
+		var d Draft
+		if err := cursor.Decode(&d); err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, d)
+	}
+	return drafts, cursor.Err()
+}
+
+func (m *mongoDraftRepository) Delete(id DraftID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrDraftNotFound
+	}
+	return nil
+}
+
+type mongoBookmarkRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoBookmarkRepository(c *mongo.Collection) BookmarkRepository {
+	repo := &mongoBookmarkRepository{collection: c}
+	_ = repo.createIndexes()
+	return repo
+}
+
+// createIndexes makes a user's bookmark of a post unique and keeps the
+// bookmarks of a user in the order they were saved
+func (m *mongoBookmarkRepository) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "post_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.M{"post_id": 1}},
+	})
+	return err
+}
+
+func (m *mongoBookmarkRepository) Store(b Bookmark) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.InsertOne(ctx, &b)
+	if isDuplicateKeyError(err) {
+		return ErrAlreadyBookmarked
+	}
+	return err
+}
+
+func (m *mongoBookmarkRepository) Delete(userID ID, postID PostID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.collection.DeleteOne(ctx, bson.M{"user_id": userID, "post_id": postID})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrNotBookmarked
+	}
+	return nil
+}
+
+func (m *mongoBookmarkRepository) DeleteByPost(postID PostID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.DeleteMany(ctx, bson.M{"post_id": postID})
+	return err
+}
+
+func (m *mongoBookmarkRepository) FindByUser(userID ID, page Page) ([]Bookmark, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	order := -1
+	if page.Before != "" {
+		filter["_id"] = bson.M{"$lt": page.Before}
+	}
+	if page.After != "" {
+		// sort ascending so the limit keeps the bookmarks closest to the cursor
+		filter["_id"] = bson.M{"$gt": page.After}
+		order = 1
+	}
+
+	opts := options.Find().SetSort(bson.M{"_id": order})
+	if page.Limit > 0 {
+		opts.SetLimit(int64(page.Limit))
+	}
+
+	cursor, err := m.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	bookmarks := []Bookmark{}
+	for cursor.Next(ctx) {
+		var b Bookmark
+		if err := cursor.Decode(&b); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+
+	if order == 1 {
+		for i, j := 0, len(bookmarks)-1; i < j; i, j = i+1, j-1 {
+			bookmarks[i], bookmarks[j] = bookmarks[j], bookmarks[i]
+		}
+	}
+	return bookmarks, cursor.Err()
+}
+
+type mongoTimelineRepository struct {
+	collection *mongo.Collection
+}
+
+// mongoTimeline holds the timeline of a user in a single document
+type mongoTimeline struct {
+	UserID  ID              `bson:"_id"`
+	Entries []TimelineEntry `bson:"entries"`
+}
+
+func NewMongoTimelineRepository(c *mongo.Collection) TimelineRepository {
+	repo := &mongoTimelineRepository{collection: c}
+	_ = repo.createIndexes()
+	return repo
+}
+
+func (m *mongoTimelineRepository) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{"entries.post_id": 1}})
+	return err
+}
+
+// pushEntries adds entries to a timeline, keeping it sorted and bounded
+func pushEntries(entries []TimelineEntry) bson.M {
+	return bson.M{"$push": bson.M{"entries": bson.M{
+		"$each":  entries,
+		"$sort":  bson.D{{Key: "timestamp", Value: -1}, {Key: "post_id", Value: -1}},
+		"$slice": maxTimelineLength,
+	}}}
+}
+
+func (m *mongoTimelineRepository) Store(user ID, entries []TimelineEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// entries are stored as an empty array rather than null so that they can
+	// be pushed to
+	tl := mongoTimeline{UserID: user, Entries: append([]TimelineEntry{}, entries...)}
+	_, err := m.collection.ReplaceOne(ctx, bson.M{"_id": user}, tl, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (m *mongoTimelineRepository) Push(users []ID, e TimelineEntry) error {
+	if len(users) < 1 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, len(users))
+	for i, u := range users {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": u}).
+			SetUpdate(pushEntries([]TimelineEntry{e}))
+	}
+
+	_, err := m.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (m *mongoTimelineRepository) Backfill(user ID, entries []TimelineEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.UpdateOne(ctx, bson.M{"_id": user}, pushEntries(entries))
+	return err
+}
+
+func (m *mongoTimelineRepository) RemoveAuthor(user ID, author ID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.UpdateOne(ctx, bson.M{"_id": user}, bson.M{"$pull": bson.M{"entries": bson.M{"author": author}}})
+	return err
+}
+
+func (m *mongoTimelineRepository) RemovePost(id PostID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.collection.UpdateMany(ctx, bson.M{"entries.post_id": id}, bson.M{"$pull": bson.M{"entries": bson.M{"post_id": id}}})
+	return err
+}
+
+func (m *mongoTimelineRepository) Find(user ID, limit int) ([]TimelineEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOne()
+	if limit > 0 {
+		opts.SetProjection(bson.M{"entries": bson.M{"$slice": limit}})
+	}
+
+	var tl mongoTimeline
+	err := m.collection.FindOne(ctx, bson.M{"_id": user}, opts).Decode(&tl)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrTimelineNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tl.Entries, nil
+}
+
+type mongoTrendRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoTrendRepository(c *mongo.Collection) TrendRepository {
+	repo := &mongoTrendRepository{collection: c}
+	_ = repo.createIndexes()
+	return repo
+}
+
+func (m *mongoTrendRepository) createIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tag", Value: 1}, {Key: "size", Value: 1}, {Key: "start", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "size", Value: 1}, {Key: "start", Value: 1}}},
+		{
+			// buckets are dropped once they are too old to be part of any trend
+			Keys:    bson.M{"expires": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	return err
+}
+
+func (m *mongoTrendRepository) Increment(counts []TagCount) error {
+	if len(counts) < 1 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, len(counts))
+	for i, c := range counts {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"tag": c.Tag, "size": c.Size, "start": c.Start}).
+			SetUpdate(bson.M{
+				"$inc": bson.M{"count": c.Count},
+				"$max": bson.M{"expires": c.Expires},
+			}).
+			SetUpsert(true)
+	}
+
+	_, err := m.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (m *mongoTrendRepository) Decrement(counts []TagCount) error {
+	if len(counts) < 1 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// no upserts, so that buckets removed once expired don't come back
+	models := make([]mongo.WriteModel, len(counts))
+	for i, c := range counts {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"tag": c.Tag, "size": c.Size, "start": c.Start, "count": bson.M{"$gte": c.Count}}).
+			SetUpdate(bson.M{"$inc": bson.M{"count": -c.Count}})
+	}
+
+	_, err := m.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (m *mongoTrendRepository) Find(size time.Duration, since time.Time) ([]TagCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := m.collection.Find(ctx, bson.M{"size": size, "start": bson.M{"$gte": since}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := []TagCount{}
+	for cursor.Next(ctx) {
+		var c TagCount
+		if err := cursor.Decode(&c); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, cursor.Err()
+}
+
+func isDuplicateKeyError(err error) bool {
+	if we, ok := err.(mongo.WriteException); ok {
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -0,0 +1,44 @@
+package blog
+
+import "errors"
+
+var (
+	ErrTooManyPinned = errors.New("too many pinned posts")
+	ErrAlreadyPinned = errors.New("post already pinned")
+	ErrNotPinned     = errors.New("post not pinned")
+)
+
+// maxPinnedPosts is how many posts a user can pin to their profile
+const maxPinnedPosts = 3
+
+// Pin pins a post to the user's profile, ahead of the ones pinned before it
+func (u *User) Pin(id PostID) error {
+	if u.HasPinned(id) {
+		return ErrAlreadyPinned
+	}
+	if len(u.Pinned) >= maxPinnedPosts {
+		return ErrTooManyPinned
+	}
+
+	u.Pinned = append([]PostID{id}, u.Pinned...)
+	return nil
+}
+
+func (u *User) Unpin(id PostID) error {
+	for i, pid := range u.Pinned {
+		if pid == id {
+			u.Pinned = append(u.Pinned[:i], u.Pinned[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotPinned
+}
+
+func (u *User) HasPinned(id PostID) bool {
+	for _, pid := range u.Pinned {
+		if pid == id {
+			return true
+		}
+	}
+	return false
+}
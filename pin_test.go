@@ -0,0 +1,27 @@
+package blog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUser_Pin(t *testing.T) {
+	u := &User{}
+
+	for _, id := range []PostID{"a", "b", "c"} {
+		assert.Nil(t, u.Pin(id))
+	}
+	assert.Equal(t, []PostID{"c", "b", "a"}, u.Pinned)
+
+	assert.Equal(t, ErrAlreadyPinned, u.Pin("b"))
+	assert.Equal(t, ErrTooManyPinned, u.Pin("d"))
+
+	assert.Nil(t, u.Unpin("b"))
+	assert.Equal(t, []PostID{"c", "a"}, u.Pinned)
+	assert.False(t, u.HasPinned("b"))
+	assert.Equal(t, ErrNotPinned, u.Unpin("b"))
+
+	assert.Nil(t, u.Pin("d"))
+	assert.Equal(t, []PostID{"d", "c", "a"}, u.Pinned)
+}
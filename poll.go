@@ -0,0 +1,112 @@
+package blog
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPoll       = errors.New("poll must have 2 to 4 distinct options")
+	ErrInvalidPollExpiry = errors.New("poll must be open between 5 minutes and 7 days")
+	ErrNoPoll            = errors.New("post has no poll")
+	ErrInvalidChoice     = errors.New("invalid poll option")
+	ErrPollClosed        = errors.New("poll is closed")
+	ErrAlreadyVoted      = errors.New("already voted in poll")
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 50
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+)
+
+type VoteRepository interface {
+	// Store records a vote. It returns ErrAlreadyVoted if the user already
+	// voted in the poll, however many votes are stored at once.
+	Store(v Vote) error
+	DeleteByPost(postID PostID) error
+	// CountByPosts returns the votes for each option of the polls
+	CountByPosts(ids []PostID) (map[PostID]map[int]int, error)
+	// FindByUser returns the option the user voted for in each of the polls
+	FindByUser(userID ID, ids []PostID) (map[PostID]int, error)
+}
+
+// Poll asks the readers of a post to choose one of its options until it
+// expires, Duration after the post is published
+type Poll struct {
+	Options   []string      `bson:"options"`
+	Duration  time.Duration `bson:"duration"`
+	ExpiresAt time.Time     `bson:"expires_at"`
+}
+
+// Vote records the option a user chose in the poll of a post. A user votes
+// once in each poll.
+type Vote struct {
+	PostID    PostID `bson:"post_id"`
+	UserID    ID     `bson:"user_id"`
+	Choice    int    `bson:"choice"`
+	Timestamp time.Time
+}
+
+// AddPoll adds a poll to the post that stays open for d from the time the
+// post is published
+func (p *Post) AddPoll(options []string, d time.Duration) error {
+	if len(options) < minPollOptions || len(options) > maxPollOptions {
+		return ErrInvalidPoll
+	}
+
+	seen := map[string]bool{}
+	var opts []string
+	for _, o := range options {
+		o = strings.TrimSpace(o)
+		key := strings.ToLower(o)
+		if o == "" || graphemeCount(o) > maxPollOptionLength || seen[key] {
+			return ErrInvalidPoll
+		}
+		seen[key] = true
+		opts = append(opts, o)
+	}
+
+	if d < minPollDuration || d > maxPollDuration {
+		return ErrInvalidPollExpiry
+	}
+
+	p.Poll = &Poll{Options: opts, Duration: d}
+	p.Poll.open(p.Timestamp)
+	return nil
+}
+
+// open sets the poll to expire Duration after the post is published at t
+func (poll *Poll) open(t time.Time) {
+	poll.ExpiresAt = t.Add(poll.Duration).UTC()
+}
+
+// IsClosed reports whether the poll stopped taking votes at t
+func (poll *Poll) IsClosed(t time.Time) bool {
+	return !t.Before(poll.ExpiresAt)
+}
+
+// NewVote returns the user's vote for an option of the post's poll
+func NewVote(post Post, userID ID, choice int) (*Vote, error) {
+	if post.Poll == nil {
+		return nil, ErrNoPoll
+	}
+
+	now := time.Now().UTC()
+	if post.Poll.IsClosed(now) {
+		return nil, ErrPollClosed
+	}
+
+	if choice < 0 || choice >= len(post.Poll.Options) {
+		return nil, ErrInvalidChoice
+	}
+
+	return &Vote{PostID: post.ID, UserID: userID, Choice: choice, Timestamp: now}, nil
+}
+
+func (v Vote) key() string {
+	return string(v.PostID) + ":" + string(v.UserID)
+}
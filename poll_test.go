@@ -0,0 +1,62 @@
+package blog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPost_AddPoll(t *testing.T) {
+	p, _ := NewPost(Author{UserID: nextID()}, "which one?")
+
+	tests := []struct {
+		options []string
+		d       time.Duration
+		wantErr error
+	}{
+		{options: []string{"one"}, d: time.Hour, wantErr: ErrInvalidPoll},
+		{options: []string{"a", "b", "c", "d", "e"}, d: time.Hour, wantErr: ErrInvalidPoll},
+		{options: []string{"a", " "}, d: time.Hour, wantErr: ErrInvalidPoll},
+		{options: []string{"Yes", "yes "}, d: time.Hour, wantErr: ErrInvalidPoll},
+		{options: []string{"a", string(make([]byte, maxPollOptionLength+1))}, d: time.Hour, wantErr: ErrInvalidPoll},
+		{options: []string{"a", "b"}, d: time.Minute, wantErr: ErrInvalidPollExpiry},
+		{options: []string{"a", "b"}, d: maxPollDuration + time.Second, wantErr: ErrInvalidPollExpiry},
+		{options: []string{" yes ", "no"}, d: time.Hour},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.wantErr, p.AddPoll(tt.options, tt.d))
+	}
+
+	assert.Equal(t, []string{"yes", "no"}, p.Poll.Options)
+	assert.Equal(t, p.Timestamp.Add(time.Hour).UTC(), p.Poll.ExpiresAt)
+	assert.False(t, p.Poll.IsClosed(p.Timestamp))
+	assert.True(t, p.Poll.IsClosed(p.Poll.ExpiresAt))
+
+	// a scheduled poll opens when the post is published
+	at := time.Now().Add(24 * time.Hour)
+	assert.Nil(t, p.Schedule(at))
+	assert.Equal(t, at.Add(time.Hour).UTC(), p.Poll.ExpiresAt)
+}
+
+func TestNewVote(t *testing.T) {
+	p := Post{ID: PostID(nextID())}
+	_, err := NewVote(p, nextID(), 0)
+	assert.Equal(t, ErrNoPoll, err)
+
+	p.Poll = &Poll{Options: []string{"a", "b"}, ExpiresAt: time.Now().Add(-time.Second)}
+	_, err = NewVote(p, nextID(), 0)
+	assert.Equal(t, ErrPollClosed, err)
+
+	p.Poll.ExpiresAt = time.Now().Add(time.Hour)
+	for _, c := range []int{-1, 2} {
+		_, err = NewVote(p, nextID(), c)
+		assert.Equal(t, ErrInvalidChoice, err)
+	}
+
+	v, err := NewVote(p, nextID(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v.Choice)
+	assert.Equal(t, p.ID, v.PostID)
+}
@@ -2,23 +2,158 @@ package blog
 
 import (
 	"errors"
+	"strings"
 	"time"
+
+	"github.com/rs/xid"
 )
 
 var (
-	ErrEmptyBody    = errors.New("post body cannot be empty")
-	ErrPostNotFound = errors.New("post not found")
+	ErrEmptyBody     = errors.New("post body cannot be empty")
+	ErrBodyTooLong   = errors.New("post body is too long")
+	ErrPostNotFound  = errors.New("post not found")
+	ErrInvalidCursor = errors.New("invalid page cursor")
+	ErrNotPostAuthor = errors.New("post does not belong to user")
+	ErrNoParentPost  = errors.New("post being replied to does not exist")
+
+	ErrAlreadyReposted = errors.New("post already reposted")
+	ErrNotReposted     = errors.New("post not reposted")
+	ErrCantRepostOwn   = errors.New("can't repost your own post")
+	ErrCantEditRepost  = errors.New("reposts can't be edited")
+
+	ErrContentWarningTooLong = errors.New("content warning is too long")
+
+	ErrInvalidPublishTime = errors.New("publish time must be in the future and within a year")
+	ErrNotScheduled       = errors.New("post is not scheduled")
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// maxContentWarningLength is the most characters a content warning can have
+const maxContentWarningLength = 100
+
+// maxScheduleAhead is how far in the future a post can be scheduled
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+const (
+	// exploreWindow is how far back the explore feed reaches
+	exploreWindow = 7 * 24 * time.Hour
+	// maxEngagedBatches bounds how many batches of posts are read looking for
+	// a page of the posts with engagement
+	maxEngagedBatches = 5
 )
 
+// MaxBodyLength is the most characters a post body can have, counting each
+// grapheme cluster as one character. Zero or less means no limit.
+var MaxBodyLength = 280
+
 type PostRepository interface {
 	FindByID(id PostID) (Post, error)
+	FindByIDs(ids []PostID) ([]*Post, error)
 	Store(post Post) error
-	FindLatestPostsForUser(id ID) ([]*Post, error)
-	FindLatestPostsForUserAndFriends(user *User) ([]*Post, error)
+	Update(post Post) error
+	Delete(id PostID) error
+	FindLatestPostsForUser(id ID, page Page) ([]*Post, error)
+	FindLatestPostsForUserAndFriends(user *User, limit int) ([]*Post, error)
+	FindByConversation(id PostID) ([]*Post, error)
+	// CountReplies counts the public replies to each post. Replies only some
+	// can read are left out so that the count doesn't give them away.
+	CountReplies(ids []PostID) (map[PostID]int, error)
+	FindRepost(id ID, postID PostID) (Post, error)
+	// CountReposts counts the public reposts and quotes of each post
+	CountReposts(ids []PostID) (map[PostID]int, error)
+	FindByTag(tag string, page Page) ([]*Post, error)
+	FindMentions(id ID, page Page) ([]*Post, error)
+	// FindPublic returns the published public posts of every user made since
+	// the given time, leaving out plain reposts
+	FindPublic(since time.Time, page Page) ([]*Post, error)
+	// FindScheduled returns the scheduled posts of a user, due first
+	FindScheduled(id ID) ([]*Post, error)
+	// FindDue returns the scheduled posts due to be published at t
+	FindDue(t time.Time) ([]*Post, error)
+	// Publish publishes a scheduled post at the given time and returns it. It
+	// returns ErrNotScheduled if the post is not scheduled anymore, so that a
+	// post is only ever published once.
+	Publish(id PostID, at time.Time) (Post, error)
+	// Search returns the published posts matching q that q.Viewer can read,
+	// ranked by page.Order and skipping the first page.Offset of them. A Limit
+	// of zero or less means no limit.
+	Search(q SearchQuery, page SearchPage) ([]*Post, error)
 }
 
 type PostID string
 
+// PostState tells scheduled posts apart from published ones. Published posts
+// have no state so that posts stored before scheduling existed are published.
+type PostState string
+
+const PostScheduled PostState = "scheduled"
+
+// PostKind tells plain posts apart from posts that share another post
+type PostKind string
+
+const (
+	KindPost PostKind = "post"
+	// KindRepost shares another post as is
+	KindRepost PostKind = "repost"
+	// KindQuote shares another post with commentary in its body
+	KindQuote PostKind = "quote"
+)
+
+// Page selects a window of results ordered newest first. Before and After are
+// exclusive cursors holding time ordered xids: Before selects results older
+// than the cursor and After selects results newer than it. A Limit of zero
+// or less means no limit at repository level. Posts are ordered by timestamp,
+// so a cursor naming a post stands for the time the post was published.
+type Page struct {
+	Limit  int
+	Before string
+	After  string
+}
+
+// newerThan reports whether p comes before o in feeds, which hold posts newest
+// first by the time they were published, and by id when that is the same
+func (p *Post) newerThan(o *Post) bool {
+	if !p.Timestamp.Equal(o.Timestamp) {
+		return p.Timestamp.After(o.Timestamp)
+	}
+	return p.ID > o.ID
+}
+
+// cursorPost returns where the post named by a cursor sits in feeds. A post
+// deleted since the cursor was handed out is placed at the time of its id.
+func cursorPost(cursor string, find func(PostID) (Post, error)) *Post {
+	if p, err := find(PostID(cursor)); err == nil {
+		return &Post{ID: p.ID, Timestamp: p.Timestamp}
+	}
+	id, _ := xid.FromString(cursor)
+	return &Post{ID: PostID(cursor), Timestamp: id.Time()}
+}
+
+// normalize validates the cursors and clamps the limit to a sane range
+func (p Page) normalize() (Page, error) {
+	if p.Before != "" && p.After != "" {
+		return Page{}, ErrInvalidCursor
+	}
+
+	for _, c := range []string{p.Before, p.After} {
+		if c != "" && !IsValidID(c) {
+			return Page{}, ErrInvalidCursor
+		}
+	}
+
+	if p.Limit <= 0 {
+		p.Limit = defaultPageLimit
+	}
+	if p.Limit > maxPageLimit {
+		p.Limit = maxPageLimit
+	}
+	return p, nil
+}
+
 type Author struct {
 	UserID ID `bson:"user_id"`
 }
@@ -28,12 +163,169 @@ type Post struct {
 	Author    Author
 	Body      string
 	Timestamp time.Time
+	EditedAt  time.Time `bson:"edited_at,omitempty"`
+	Revisions []Revision
+	// InReplyTo is the post this one replies to, if any
+	InReplyTo PostID `bson:"in_reply_to,omitempty"`
+	// ConversationID is the id of the post that started the thread
+	ConversationID PostID   `bson:"conversation_id"`
+	Kind           PostKind `bson:"kind,omitempty"`
+	// RepostOf is the post shared by a repost or quote
+	RepostOf PostID `bson:"repost_of,omitempty"`
+	// Tags are the normalized hashtags found in the body
+	Tags []string `bson:"tags,omitempty"`
+	// Mentions are the users mentioned in the body that could be found
+	Mentions []Mention `bson:"mentions,omitempty"`
+	// Attachments are the media shown with the post, in order
+	Attachments []AttachmentID `bson:"attachments,omitempty"`
+	State       PostState      `bson:"state,omitempty"`
+	// PublishAt is when a scheduled post is due to be published
+	PublishAt time.Time `bson:"publish_at,omitempty"`
+	// Visibility is empty for public posts
+	Visibility Visibility `bson:"visibility,omitempty"`
+	Poll       *Poll      `bson:"poll,omitempty"`
+	// ContentWarning is shown in place of the body until the reader expands it
+	ContentWarning string `bson:"content_warning,omitempty"`
+	// Sensitive marks the attachments as not safe to show without asking
+	Sensitive bool `bson:"sensitive,omitempty"`
+}
+
+// Revision is a body the post had before it was edited, along with the time
+// that body was written.
+type Revision struct {
+	Body      string
+	Timestamp time.Time
 }
 
 func NewPost(author Author, body string) (*Post, error) {
-	if body == "" {
-		return nil, ErrEmptyBody
+	if err := validateBody(body); err != nil {
+		return nil, err
+	}
+
+	return &Post{Author: author, Body: body, Timestamp: time.Now(), Kind: KindPost, Tags: extractTags(body)}, nil
+}
+
+// NewRepost returns a post sharing original as is. Reposting a repost shares
+// the post it reposted.
+func NewRepost(author Author, original Post) (*Post, error) {
+	if original.Author.UserID == author.UserID {
+		return nil, ErrCantRepostOwn
+	}
+	if !original.IsPublic() {
+		return nil, ErrCantRepostPrivate
+	}
+
+	return &Post{Author: author, Timestamp: time.Now(), Kind: KindRepost, RepostOf: original.ID}, nil
+}
+
+// NewQuote returns a post sharing original with body as commentary
+func NewQuote(author Author, original Post, body string) (*Post, error) {
+	if !original.IsPublic() {
+		return nil, ErrCantRepostPrivate
+	}
+	if err := validateBody(body); err != nil {
+		return nil, err
+	}
+
+	return &Post{
+		Author:    author,
+		Body:      body,
+		Timestamp: time.Now(),
+		Kind:      KindQuote,
+		RepostOf:  original.ID,
+		Tags:      extractTags(body),
+	}, nil
+}
+
+// IsRepost reports whether the post shares another post without commentary
+func (p *Post) IsRepost() bool {
+	return p.Kind == KindRepost
+}
+
+// IsScheduled reports whether the post is waiting to be published. Scheduled
+// posts are only shown to their author.
+func (p *Post) IsScheduled() bool {
+	return p.State == PostScheduled
+}
+
+// Schedule sets the post to be published at the given time, which becomes its
+// timestamp and the time its poll opens
+func (p *Post) Schedule(at time.Time) error {
+	now := time.Now()
+	if !at.After(now) || at.After(now.Add(maxScheduleAhead)) {
+		return ErrInvalidPublishTime
+	}
+
+	p.State = PostScheduled
+	p.PublishAt = at.UTC()
+	p.Timestamp = p.PublishAt
+	if p.Poll != nil {
+		p.Poll.open(p.Timestamp)
+	}
+	return nil
+}
+
+// SetContentWarning hides the post behind a content warning, its media when
+// sensitive, or both
+func (p *Post) SetContentWarning(warning string, sensitive bool) error {
+	warning = strings.TrimSpace(warning)
+	if graphemeCount(warning) > maxContentWarningLength {
+		return ErrContentWarningTooLong
 	}
 
-	return &Post{Author: author, Body: body, Timestamp: time.Now()}, nil
+	p.ContentWarning = warning
+	p.Sensitive = sensitive
+	return nil
+}
+
+// IsCollapsible reports whether the post should be collapsed for readers who
+// don't always expand sensitive content
+func (p *Post) IsCollapsible() bool {
+	return p.ContentWarning != "" || p.Sensitive
+}
+
+// ReplyTo places the post in the thread of its parent
+func (p *Post) ReplyTo(parent Post) {
+	p.InReplyTo = parent.ID
+	p.ConversationID = parent.ConversationID
+	if p.ConversationID == "" {
+		p.ConversationID = parent.ID
+	}
+}
+
+// Edit replaces the body of the post and keeps the previous one as a revision.
+// Edits that don't change the body are ignored.
+func (p *Post) Edit(body string) error {
+	if p.IsRepost() {
+		return ErrCantEditRepost
+	}
+
+	if err := validateBody(body); err != nil {
+		return err
+	}
+
+	if body == p.Body {
+		return nil
+	}
+
+	written := p.Timestamp
+	if !p.EditedAt.IsZero() {
+		written = p.EditedAt
+	}
+
+	p.Revisions = append(p.Revisions, Revision{Body: p.Body, Timestamp: written})
+	p.Body = body
+	p.Tags = extractTags(body)
+	p.EditedAt = time.Now()
+	return nil
+}
+
+func validateBody(body string) error {
+	if body == "" {
+		return ErrEmptyBody
+	}
+	if MaxBodyLength > 0 && graphemeCount(body) > MaxBodyLength {
+		return ErrBodyTooLong
+	}
+	return nil
 }
@@ -0,0 +1,112 @@
+package blog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBody(t *testing.T) {
+	defer func(max int) { MaxBodyLength = max }(MaxBodyLength)
+	MaxBodyLength = 5
+
+	tests := []struct {
+		body    string
+		wantErr error
+	}{
+		{body: "", wantErr: ErrEmptyBody},
+		{body: "hello"},
+		{body: "hello!", wantErr: ErrBodyTooLong},
+		{body: "héllo"},
+		{body: "\U0001F1F3\U0001F1EC\U0001F44D\U0001F3FD\u2764\ufe0f\U0001F468\u200d\U0001F469\u200d\U0001F467a"},
+		{body: "\U0001F1F3\U0001F1EC\U0001F44D\U0001F3FD\u2764\ufe0f\U0001F468\u200d\U0001F469\u200d\U0001F467ab", wantErr: ErrBodyTooLong},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.wantErr, validateBody(tt.body), tt.body)
+	}
+
+	MaxBodyLength = 0
+	assert.Nil(t, validateBody(strings.Repeat("a", 10000)))
+}
+
+func TestPost_Edit(t *testing.T) {
+	created := time.Now()
+	p, _ := NewPost(Author{UserID: nextID()}, "first")
+
+	assert.Equal(t, ErrEmptyBody, p.Edit(""))
+	assert.Nil(t, p.Edit("first"))
+	assert.True(t, p.EditedAt.IsZero())
+	assert.Equal(t, 0, len(p.Revisions))
+
+	assert.Nil(t, p.Edit("second"))
+	firstEdit := p.EditedAt
+	assert.Nil(t, p.Edit("third"))
+
+	assert.Equal(t, "third", p.Body)
+	assert.True(t, p.EditedAt.After(created))
+	assert.Equal(t, []Revision{
+		{Body: "first", Timestamp: p.Timestamp},
+		{Body: "second", Timestamp: firstEdit},
+	}, p.Revisions)
+
+	assert.Nil(t, p.Tags)
+	assert.Nil(t, p.Edit("fourth #Edited"))
+	assert.Equal(t, []string{"edited"}, p.Tags)
+}
+
+func TestPost_Schedule(t *testing.T) {
+	p, _ := NewPost(Author{UserID: nextID()}, "later")
+	assert.False(t, p.IsScheduled())
+
+	assert.Equal(t, ErrInvalidPublishTime, p.Schedule(time.Now().Add(-time.Second)))
+	assert.Equal(t, ErrInvalidPublishTime, p.Schedule(time.Now().Add(maxScheduleAhead+time.Hour)))
+	assert.False(t, p.IsScheduled())
+
+	at := time.Now().Add(time.Hour)
+	assert.Nil(t, p.Schedule(at))
+	assert.True(t, p.IsScheduled())
+	assert.Equal(t, at.UTC(), p.PublishAt)
+	assert.Equal(t, p.PublishAt, p.Timestamp)
+}
+
+func TestPost_SetContentWarning(t *testing.T) {
+	p, _ := NewPost(Author{UserID: nextID()}, "spoilers")
+	assert.False(t, p.IsCollapsible())
+
+	long := strings.Repeat("a", maxContentWarningLength+1)
+	assert.Equal(t, ErrContentWarningTooLong, p.SetContentWarning(long, false))
+
+	assert.Nil(t, p.SetContentWarning("  ending of the film ", false))
+	assert.Equal(t, "ending of the film", p.ContentWarning)
+	assert.True(t, p.IsCollapsible())
+
+	assert.Nil(t, p.SetContentWarning("", true))
+	assert.True(t, p.Sensitive)
+	assert.True(t, p.IsCollapsible())
+}
+
+func TestNewRepost(t *testing.T) {
+	author := Author{UserID: nextID()}
+	original := Post{ID: PostID(nextID()), Author: Author{UserID: nextID()}, Body: "original", Kind: KindPost}
+
+	_, err := NewRepost(author, Post{Author: author})
+	assert.Equal(t, ErrCantRepostOwn, err)
+
+	repost, err := NewRepost(author, original)
+	assert.Nil(t, err)
+	assert.True(t, repost.IsRepost())
+	assert.Equal(t, original.ID, repost.RepostOf)
+	assert.Equal(t, ErrCantEditRepost, repost.Edit("body"))
+
+	_, err = NewQuote(author, original, "")
+	assert.Equal(t, ErrEmptyBody, err)
+
+	quote, err := NewQuote(author, Post{ID: original.ID, Author: author}, "my own")
+	assert.Nil(t, err)
+	assert.Equal(t, KindQuote, quote.Kind)
+	assert.False(t, quote.IsRepost())
+	assert.Nil(t, quote.Edit("edited"))
+}
@@ -0,0 +1,68 @@
+package blog
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidReaction = errors.New("invalid reaction")
+	ErrAlreadyReacted  = errors.New("already reacted to post")
+	ErrNotReacted      = errors.New("not reacted to post")
+)
+
+type ReactionRepository interface {
+	Store(r Reaction) error
+	Delete(postID PostID, userID ID, t ReactionType) error
+	DeleteByPost(postID PostID) error
+	FindByPost(postID PostID) ([]Reaction, error)
+	CountByPosts(ids []PostID) (map[PostID]map[ReactionType]int, error)
+	FindByUser(userID ID, ids []PostID) (map[PostID][]ReactionType, error)
+}
+
+// ReactionType names one of the emoji a user can react to a post with
+type ReactionType string
+
+const (
+	ReactionLike  ReactionType = "like"
+	ReactionLove  ReactionType = "love"
+	ReactionLaugh ReactionType = "laugh"
+	ReactionWow   ReactionType = "wow"
+	ReactionSad   ReactionType = "sad"
+	ReactionAngry ReactionType = "angry"
+)
+
+var reactionEmoji = map[ReactionType]string{
+	ReactionLike:  "👍",
+	ReactionLove:  "❤️",
+	ReactionLaugh: "😂",
+	ReactionWow:   "😮",
+	ReactionSad:   "😢",
+	ReactionAngry: "😠",
+}
+
+// Reaction records a user reacting to a post. A user can react to a post
+// with each type once.
+type Reaction struct {
+	PostID    PostID `bson:"post_id"`
+	UserID    ID     `bson:"user_id"`
+	Type      ReactionType
+	Timestamp time.Time
+}
+
+func NewReaction(postID PostID, userID ID, t ReactionType) (*Reaction, error) {
+	if _, ok := reactionEmoji[t]; !ok {
+		return nil, ErrInvalidReaction
+	}
+
+	return &Reaction{PostID: postID, UserID: userID, Type: t, Timestamp: time.Now().UTC()}, nil
+}
+
+// key identifies the reaction of a user with a type on a post
+func (r Reaction) key() string {
+	return reactionKey(r.PostID, r.UserID, r.Type)
+}
+
+func reactionKey(postID PostID, userID ID, t ReactionType) string {
+	return string(postID) + ":" + string(userID) + ":" + string(t)
+}
@@ -0,0 +1,24 @@
+package blog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReaction(t *testing.T) {
+	postID, userID := PostID(nextID()), nextID()
+
+	_, err := NewReaction(postID, userID, "")
+	assert.Equal(t, ErrInvalidReaction, err)
+
+	_, err = NewReaction(postID, userID, "👍")
+	assert.Equal(t, ErrInvalidReaction, err)
+
+	for rt := range reactionEmoji {
+		r, err := NewReaction(postID, userID, rt)
+		assert.Nil(t, err)
+		assert.Equal(t, rt, r.Type)
+		assert.Equal(t, reactionKey(postID, userID, rt), r.key())
+	}
+}
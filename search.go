@@ -0,0 +1,337 @@
+package blog
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/kljensen/snowball/english"
+)
+
+var (
+	ErrEmptySearch        = errors.New("search has no words to look for")
+	ErrInvalidSearchDate  = errors.New("search dates must look like 2006-01-02")
+	ErrInvalidSearchOrder = errors.New("invalid search order")
+)
+
+// searchDateLayout is the format of the since: and until: filters
+const searchDateLayout = "2006-01-02"
+
+// SearchOrder decides how search results are ranked
+type SearchOrder string
+
+const (
+	// SearchByRelevance ranks the best matches first, newest first among equals
+	SearchByRelevance SearchOrder = "relevance"
+	// SearchByRecency ranks the newest matches first
+	SearchByRecency SearchOrder = "recent"
+)
+
+// SearchQuery is a parsed post search. A post matches when it holds any of
+// the words, or every one of the phrases if there are any, and passes the
+// filters. This is how Mongo text search treats words and phrases.
+type SearchQuery struct {
+	Words []string
+	// Phrases must be found in the post word for word
+	Phrases []string
+	// From is the username given in a from: filter
+	From string
+	// Author is the id of the user named by From, filled in once the user is
+	// found
+	Author ID
+	// Since and Until limit the results to posts published on or after Since
+	// and before Until
+	Since, Until time.Time
+	// Viewer is the user searching, who only finds the posts they can read,
+	// and Following the users they follow
+	Viewer    ID
+	Following []ID
+}
+
+// SearchPage selects a window of the search results ranked by Order. Windows
+// are found by position since results ranked by relevance have no cursor to
+// page from. An empty Order ranks by relevance.
+type SearchPage struct {
+	Order  SearchOrder
+	Offset int
+	Limit  int
+}
+
+// ParseSearchQuery splits a search into its words, its "quoted phrases" and
+// its from:username, since:2006-01-02 and until:2006-01-02 filters. until: is
+// inclusive of the day it names.
+func ParseSearchQuery(q string) (SearchQuery, error) {
+	var sq SearchQuery
+
+	for i, part := range strings.Split(q, `"`) {
+		// parts at odd positions were inside quotes
+		if i%2 == 1 {
+			if p := strings.Join(strings.Fields(part), " "); len(searchTokens(p)) > 0 {
+				sq.Phrases = append(sq.Phrases, p)
+			}
+			continue
+		}
+
+		for _, f := range strings.Fields(part) {
+			var err error
+			switch {
+			case strings.HasPrefix(f, "from:") && len(f) > len("from:"):
+				sq.From = strings.TrimPrefix(strings.TrimPrefix(f, "from:"), "@")
+			case strings.HasPrefix(f, "since:"):
+				sq.Since, err = time.Parse(searchDateLayout, strings.TrimPrefix(f, "since:"))
+			case strings.HasPrefix(f, "until:"):
+				sq.Until, err = time.Parse(searchDateLayout, strings.TrimPrefix(f, "until:"))
+				sq.Until = sq.Until.AddDate(0, 0, 1)
+			default:
+				sq.Words = append(sq.Words, f)
+			}
+			if err != nil {
+				return SearchQuery{}, ErrInvalidSearchDate
+			}
+		}
+	}
+
+	if len(searchTerms(sq.Words)) < 1 && len(sq.Phrases) < 1 {
+		return SearchQuery{}, ErrEmptySearch
+	}
+	return sq, nil
+}
+
+// normalize validates the order and offset and clamps the limit to a sane range
+func (p SearchPage) normalize() (SearchPage, error) {
+	if p.Order == "" {
+		p.Order = SearchByRelevance
+	}
+	if p.Order != SearchByRelevance && p.Order != SearchByRecency {
+		return SearchPage{}, ErrInvalidSearchOrder
+	}
+	if p.Offset < 0 {
+		return SearchPage{}, ErrInvalidCursor
+	}
+	if p.Limit <= 0 {
+		p.Limit = defaultPageLimit
+	}
+	if p.Limit > maxPageLimit {
+		p.Limit = maxPageLimit
+	}
+	return p, nil
+}
+
+// matches reports whether the viewer can read the post and it passes the
+// author and date filters
+func (sq SearchQuery) matches(p *Post) bool {
+	if p.IsScheduled() || sq.Author != "" && p.Author.UserID != sq.Author {
+		return false
+	}
+	if !p.IsVisibleTo(sq.Viewer, sq.follows(p.Author.UserID)) {
+		return false
+	}
+	if !sq.Since.IsZero() && p.Timestamp.Before(sq.Since) {
+		return false
+	}
+	return sq.Until.IsZero() || p.Timestamp.Before(sq.Until)
+}
+
+// follows reports whether the viewer follows the user
+func (sq SearchQuery) follows(id ID) bool {
+	for _, f := range sq.Following {
+		if f == id {
+			return true
+		}
+	}
+	return false
+}
+
+// searchTokens splits text into lower case words and stems each of them, so
+// that "running", "runs" and "run" are the same token
+func searchTokens(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, len(words))
+	for i, w := range words {
+		tokens[i] = stem(w)
+	}
+	return tokens
+}
+
+// searchTerms returns the tokens of words worth ranking on, leaving out the
+// stop words
+func searchTerms(words []string) []string {
+	var terms []string
+	for _, t := range searchTokens(strings.Join(words, " ")) {
+		if !stopWords[t] {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// stem reduces a lower case word to its English stem with the Snowball
+// (Porter2) stemmer
+func stem(w string) string {
+	return english.Stem(w, true)
+}
+
+// stopWords are too common to tell posts apart. They are still indexed so
+// that phrases containing them can be found.
+var stopWords = stemAll("a", "an", "and", "are", "as", "at", "be", "but", "by",
+	"for", "if", "in", "into", "is", "it", "no", "not", "of", "on", "or", "so",
+	"that", "the", "their", "then", "there", "these", "they", "this", "to",
+	"was", "will", "with")
+
+func stemAll(words ...string) map[string]bool {
+	stems := map[string]bool{}
+	for _, w := range words {
+		stems[stem(w)] = true
+	}
+	return stems
+}
+
+// maxUserSearchCandidates bounds how many users a store ranks for a search
+const maxUserSearchCandidates = 500
+
+// NormalizeUserSearch returns the search as it is matched against users:
+// trimmed, in lower case and without the @ of a mention
+func NormalizeUserSearch(q string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(q), "@"))
+}
+
+// rankUsers returns up to limit of the users matching q, best matches first
+// and the most followed first among equal matches. A limit of zero or less
+// means no limit.
+func rankUsers(users []User, q string, limit int) []User {
+	scores := map[ID]float64{}
+	var res []User
+	for _, u := range users {
+		if s := u.matchScore(q); s > 0 {
+			scores[u.ID] = s
+			res = append(res, u)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		si, sj := scores[res[i].ID], scores[res[j].ID]
+		if si != sj {
+			return si > sj
+		}
+		if fi, fj := len(res[i].Followers), len(res[j].Followers); fi != fj {
+			return fi > fj
+		}
+		return res[i].Username < res[j].Username
+	})
+
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+// matchScore rates how well the user matches the normalized search q, from 0
+// for no match to 1 for their exact username. Usernames are matched whole or
+// by prefix, bios a word at a time, and both allow for a typo or two.
+// Misspellings have to get the first letter of a username or the first two
+// letters of a word right, which lets stores look up candidates by them.
+func (u *User) matchScore(q string) float64 {
+	query := []rune(q)
+	if len(query) == 0 {
+		return 0
+	}
+
+	name := []rune(strings.ToLower(u.Username))
+	switch {
+	case string(name) == q:
+		return 1
+	case strings.HasPrefix(string(name), q):
+		// the more of the name the search covers the better
+		return 0.75 + 0.2*float64(len(query))/float64(len(name))
+	}
+
+	var score float64
+	if d := fuzzyDistance(query, name, 1); d >= 0 {
+		score = 0.5 - 0.1*float64(d)
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(u.Bio), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		word := []rune(w)
+		if strings.HasPrefix(w, q) {
+			return maxFloat(score, 0.3)
+		}
+		if d := fuzzyDistance(query, word, 2); d >= 0 {
+			score = maxFloat(score, 0.2-0.05*float64(d))
+		}
+	}
+	return score
+}
+
+// fuzzyDistance returns how many edits away from the word, or from its
+// beginning, the query is. It returns -1 when the query is too far off or
+// doesn't share the first fixed runes of the word. Short queries are allowed
+// fewer edits.
+func fuzzyDistance(query, word []rune, fixed int) int {
+	maxEdits := 2
+	switch {
+	case len(query) < 3:
+		return -1
+	case len(query) <= 5:
+		maxEdits = 1
+	}
+
+	if len(word) < fixed || len(query) < fixed || string(word[:fixed]) != string(query[:fixed]) {
+		return -1
+	}
+
+	d := editDistance(query, word)
+	if len(word) > len(query) {
+		if p := editDistance(query, word[:len(query)]); p < d {
+			d = p
+		}
+	}
+
+	if d > maxEdits {
+		return -1
+	}
+	return d
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, minInt(cur[j-1]+1, prev[j-1]+cost))
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,134 @@
+package blog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse(searchDateLayout, s)
+		return d
+	}
+
+	tests := []struct {
+		q       string
+		want    SearchQuery
+		wantErr error
+	}{
+		{q: "", wantErr: ErrEmptySearch},
+		{q: "the of", wantErr: ErrEmptySearch},
+		{q: `from:alice ""`, wantErr: ErrEmptySearch},
+		{q: "go since:yesterday", wantErr: ErrInvalidSearchDate},
+		{q: "go until:2019-13-01", wantErr: ErrInvalidSearchDate},
+		{q: "golang tips", want: SearchQuery{Words: []string{"golang", "tips"}}},
+		{
+			q:    `go "error   handling" from:@alice "the end"`,
+			want: SearchQuery{Words: []string{"go"}, Phrases: []string{"error handling", "the end"}, From: "alice"},
+		},
+		{
+			q:    "go since:2019-01-01 until:2019-01-31",
+			want: SearchQuery{Words: []string{"go"}, Since: day("2019-01-01"), Until: day("2019-02-01")},
+		},
+		{q: "from: go", want: SearchQuery{Words: []string{"from:", "go"}}},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseSearchQuery(tt.q)
+		assert.Equal(t, tt.wantErr, err, tt.q)
+		assert.Equal(t, tt.want, q, tt.q)
+	}
+}
+
+func TestSearchTokens(t *testing.T) {
+	assert.Equal(t, []string{"run", "run", "run", "fall"}, searchTokens("Running, runs; RUN falling"))
+	assert.Equal(t, []string{"make", "make", "poni", "poni", "class", "golang"}, searchTokens("make making pony ponies class #golang"))
+	assert.Equal(t, []string{"jump", "string", "cat", "go", "speed", "generous"}, searchTokens("jumped string cats go speed generously"))
+	assert.Empty(t, searchTokens("!! ..."))
+}
+
+func TestSearchIndex(t *testing.T) {
+	idx := newSearchIndex()
+	idx.add("a", "error handling in go")
+	idx.add("b", "handling errors is what go does, error after error")
+	idx.add("c", "nothing to see")
+
+	scores := idx.search(SearchQuery{Words: []string{"errors"}})
+	assert.Len(t, scores, 2)
+	assert.True(t, scores["b"] > scores["a"])
+
+	scores = idx.search(SearchQuery{Phrases: []string{"Errors handled"}})
+	assert.Len(t, scores, 1)
+	assert.Contains(t, scores, PostID("a"))
+
+	// the words only rank the posts holding the phrase
+	scores = idx.search(SearchQuery{Words: []string{"see"}, Phrases: []string{"error"}})
+	assert.Len(t, scores, 2)
+
+	idx.add("a", "nothing here")
+	idx.remove("b")
+	assert.Empty(t, idx.search(SearchQuery{Words: []string{"error"}}))
+	assert.Len(t, idx.search(SearchQuery{Words: []string{"nothing"}}), 2)
+}
+
+func TestUser_matchScore(t *testing.T) {
+	tests := []struct {
+		username, bio, q string
+		want             float64
+	}{
+		{username: "gopher", q: "gopher", want: 1},
+		{username: "gopher", q: "go", want: 0.75 + 0.2*2/6},
+		{username: "gopher", q: "gohper", want: 0.3},
+		{username: "gopher", q: "gophr", want: 0.4},
+		{username: "gopher", q: "hopher", want: 0},
+		{username: "gopher", q: "gx", want: 0},
+		{username: "amy", bio: "Writes Go at night", q: "nig", want: 0.3},
+		{username: "amy", bio: "Writes Go at night", q: "wirtes", want: 0},
+		{username: "amy", bio: "Writes Go at night", q: "writse", want: 0.1},
+		{username: "amy", bio: "Writes Go at night", q: "ruby", want: 0},
+		{username: "gophers", bio: "the gopher club", q: "gopher", want: 0.75 + 0.2*6/7},
+	}
+
+	for _, tt := range tests {
+		u := User{Username: tt.username, Bio: tt.bio}
+		assert.InDelta(t, tt.want, u.matchScore(tt.q), 1e-9, tt.q)
+	}
+}
+
+func TestRankUsers(t *testing.T) {
+	users := []User{
+		{ID: "1", Username: "gophr", Followers: []ID{"x", "y"}},
+		{ID: "2", Username: "gopher"},
+		{ID: "3", Username: "gopherina", Followers: []ID{"x"}},
+		{ID: "4", Username: "gophercon", Followers: []ID{"x", "y"}},
+		{ID: "5", Username: "rust", Bio: "not a gopher"},
+		{ID: "6", Username: "java"},
+	}
+
+	var ids []ID
+	for _, u := range rankUsers(users, "gopher", 0) {
+		ids = append(ids, u.ID)
+	}
+	assert.Equal(t, []ID{"2", "4", "3", "1", "5"}, ids)
+	assert.Len(t, rankUsers(users, "gopher", 2), 2)
+	assert.Empty(t, rankUsers(users, "c++", 0))
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"gopher", "gohper", 2},
+		{"ünï", "uni", 2},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, editDistance([]rune(tt.a), []rune(tt.b)))
+	}
+}
@@ -1,9 +1,14 @@
 package blog
 
 import (
+	"bytes"
 	"crypto/md5"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,34 +19,174 @@ import (
 
 type Service interface {
 	CreateProfile(id, username, email string)
-	CreatePost(id ID, body string) (PostID, error)        //messaging
-	GetUserPosts(username string) ([]*Post, error)        //messaging
-	GetProfile(username string) (Profile, error)          //profile
-	UpdateLastSeen(id ID) error                           //profile
-	EditProfile(id ID, req editProfileRequest) error      //profile
-	CreateRelationshipFor(id ID, username string) error   //profile
-	RemoveRelationshipFor(id ID, username string) error   //profile
-	GetUserFriends(username string) ([]UserInfo, error)   //profile
-	GetUserFollowers(username string) ([]UserInfo, error) //profile
-	GetTimeline(id ID) ([]postResponse, error)            //messaging
+	CreatePost(id ID, req createPostRequest) (PostID, error)                    //messaging
+	GetThread(viewer ID, id PostID) (threadResponse, error)                     //messaging
+	GetUserPosts(viewer ID, username string, page Page) (postPage, error)       //messaging
+	GetPost(viewer ID, id PostID) (postResponse, error)                         //messaging
+	GetTagPosts(viewer ID, tag string, page Page) (postPage, error)             //messaging
+	SearchPosts(viewer ID, query string, page SearchPage) (postPage, error)     //messaging
+	GetExplore(viewer ID, engaged bool, page Page) (postPage, error)            //messaging
+	GetUserMentions(viewer ID, username string, page Page) (postPage, error)    //messaging
+	EditPost(id ID, postID PostID, body string) error                           //messaging
+	GetPostRevisions(viewer ID, id PostID) ([]revisionResponse, error)          //messaging
+	DeletePost(id ID, postID PostID) error                                      //messaging
+	Repost(id ID, postID PostID, body string) (PostID, error)                   //messaging
+	RemoveRepost(id ID, postID PostID) error                                    //messaging
+	React(id ID, postID PostID, t ReactionType) error                           //messaging
+	Unreact(id ID, postID PostID, t ReactionType) error                         //messaging
+	GetReactions(viewer ID, postID PostID) ([]reactorResponse, error)           //messaging
+	Vote(id ID, postID PostID, choice int) error                                //messaging
+	PinPost(id ID, postID PostID) error                                         //profile
+	UnpinPost(id ID, postID PostID) error                                       //profile
+	BookmarkPost(id ID, postID PostID) error                                    //messaging
+	RemoveBookmark(id ID, postID PostID) error                                  //messaging
+	GetBookmarks(id ID, page Page) (postPage, error)                            //messaging
+	GetScheduledPosts(id ID) ([]postResponse, error)                            //messaging
+	ReschedulePost(id ID, postID PostID, at time.Time) error                    //messaging
+	CancelScheduledPost(id ID, postID PostID) error                             //messaging
+	PublishDuePosts() (int, error)                                              //messaging
+	CreateDraft(id ID, req draftRequest) (DraftID, error)                       //messaging
+	UpdateDraft(id ID, draftID DraftID, req draftRequest) error                 //messaging
+	GetDrafts(id ID) ([]draftResponse, error)                                   //messaging
+	DeleteDraft(id ID, draftID DraftID) error                                   //messaging
+	PublishDraft(id ID, draftID DraftID) (PostID, error)                        //messaging
+	UploadMedia(id ID, data []byte, altText string) (attachmentResponse, error) //media
+	GetMedia(viewer ID, key string) (mediaFile, error)                          //media
+	DeleteOrphanedMedia(ttl time.Duration) (int, error)                         //media
+	GetProfile(viewer ID, username string) (Profile, error)                     //profile
+	UpdateLastSeen(id ID) error                                                 //profile
+	EditProfile(id ID, req editProfileRequest) error                            //profile
+	CreateRelationshipFor(id ID, username string) error                         //profile
+	RemoveRelationshipFor(id ID, username string) error                         //profile
+	GetUserFriends(username string) ([]UserInfo, error)                         //profile
+	GetUserFollowers(username string) ([]UserInfo, error)                       //profile
+	SearchUsers(query string, limit int) ([]UserInfo, error)                    //profile
+	GetTimeline(id ID, page Page) (postPage, error)                             //messaging
+	GetTrends(window TrendWindow, limit int) ([]Trend, error)                   //messaging
 }
 
 type service struct {
-	users Repository
-	posts PostRepository
+	users       Repository
+	posts       PostRepository
+	reactions   ReactionRepository
+	attachments AttachmentRepository
+	blobs       BlobStore
+	drafts      DraftRepository
+	votes       VoteRepository
+	bookmarks   BookmarkRepository
+	timelines   TimelineRepository
+	trends      TrendRepository
+}
+
+// Option configures the storage of the optional parts of the service
+type Option func(*service)
+
+func WithReactionRepository(reactions ReactionRepository) Option {
+	return func(svc *service) {
+		svc.reactions = reactions
+	}
+}
+
+// WithMedia stores uploaded media records in attachments and their files in blobs
+func WithMedia(attachments AttachmentRepository, blobs BlobStore) Option {
+	return func(svc *service) {
+		svc.attachments = attachments
+		svc.blobs = blobs
+	}
+}
+
+func WithVoteRepository(votes VoteRepository) Option {
+	return func(svc *service) {
+		svc.votes = votes
+	}
+}
+
+func WithBookmarkRepository(bookmarks BookmarkRepository) Option {
+	return func(svc *service) {
+		svc.bookmarks = bookmarks
+	}
+}
+
+func WithTimelineRepository(timelines TimelineRepository) Option {
+	return func(svc *service) {
+		svc.timelines = timelines
+	}
+}
+
+func WithTrendRepository(trends TrendRepository) Option {
+	return func(svc *service) {
+		svc.trends = trends
+	}
+}
+
+func WithDraftRepository(drafts DraftRepository) Option {
+	return func(svc *service) {
+		svc.drafts = drafts
+	}
 }
 
 type createPostRequest struct {
-	Body string
+	Body        string
+	InReplyTo   PostID         `json:"in_reply_to"`
+	Attachments []AttachmentID `json:"attachments"`
+	// PublishAt schedules the post to be published later when set
+	PublishAt time.Time `json:"publish_at"`
+	// Visibility defaults to public
+	Visibility Visibility   `json:"visibility"`
+	Poll       *pollRequest `json:"poll"`
+	// ContentWarning is shown in place of the body until the reader expands it
+	ContentWarning string `json:"content_warning"`
+	Sensitive      bool   `json:"sensitive"`
+}
+
+type pollRequest struct {
+	Options []string `json:"options"`
+	// ExpiresIn is how many seconds the poll stays open
+	ExpiresIn int `json:"expires_in"`
+}
+
+type voteRequest struct {
+	Choice *int `json:"choice"`
 }
 
 type createPostResponse struct {
 	ID PostID `json:"id"`
 }
 
+type editPostRequest struct {
+	Body string
+}
+
+type draftRequest struct {
+	Body      string
+	InReplyTo PostID `json:"in_reply_to"`
+}
+
+type createDraftResponse struct {
+	ID DraftID `json:"id"`
+}
+
+type draftsResponse struct {
+	Drafts []draftResponse `json:"drafts"`
+	URL    string          `json:"url"`
+}
+
+type draftResponse struct {
+	ID        DraftID   `json:"id"`
+	Body      string    `json:"body"`
+	InReplyTo PostID    `json:"in_reply_to,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type reschedulePostRequest struct {
+	PublishAt time.Time `json:"publish_at"`
+}
+
 type editProfileRequest struct {
-	Username *string
-	Bio      *string
+	Username        *string
+	Bio             *string
+	ExpandSensitive *bool `json:"expand_sensitive"`
 }
 
 type Relationships struct {
@@ -50,16 +195,22 @@ type Relationships struct {
 }
 
 type Profile struct {
-	ID            ID             `json:"id"`
-	Username      string         `json:"username"`
-	Avatar        string         `json:"avatar_url,omitempty"`
-	Bio           string         `json:"bio"`
-	Joined        time.Time      `json:"joined"`
-	LastSeen      time.Time      `json:"last_seen"`
-	Relationships Relationships  `json:"relationships"`
-	Posts         []postResponse `json:"posts"`
+	ID            ID            `json:"id"`
+	Username      string        `json:"username"`
+	Avatar        string        `json:"avatar_url,omitempty"`
+	Bio           string        `json:"bio"`
+	Joined        time.Time     `json:"joined"`
+	LastSeen      time.Time     `json:"last_seen"`
+	Relationships Relationships `json:"relationships"`
+	// ExpandSensitive is only shown to the owner of the profile
+	ExpandSensitive *bool `json:"expand_sensitive,omitempty"`
+	// Pinned holds the posts the user pinned, shown ahead of Posts
+	Pinned []postResponse `json:"pinned"`
+	Posts  []postResponse `json:"posts"`
 }
 
+const deletedUsername = "[deleted]"
+
 type authorResponse struct {
 	UserID   ID     `json:"user_id"`
 	Username string `json:"username"`
@@ -67,10 +218,125 @@ type authorResponse struct {
 }
 
 type postResponse struct {
-	ID        PostID         `json:"id"`
-	Body      string         `json:"body"`
-	Timestamp time.Time      `json:"timestamp"`
+	ID         PostID     `json:"id"`
+	Kind       PostKind   `json:"kind"`
+	Visibility Visibility `json:"visibility"`
+	Body       string     `json:"body"`
+	Timestamp  time.Time  `json:"timestamp"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	// PublishAt is when a scheduled post is due to be published
+	PublishAt *time.Time     `json:"publish_at,omitempty"`
 	Author    authorResponse `json:"author"`
+	InReplyTo PostID         `json:"in_reply_to,omitempty"`
+	Tags      []string       `json:"tags,omitempty"`
+	// Entities locates the URLs, mentions and hashtags in the body
+	Entities    []entityResponse     `json:"entities"`
+	ReplyCount  int                  `json:"reply_count"`
+	RepostOf    *postResponse        `json:"repost_of,omitempty"`
+	RepostCount int                  `json:"repost_count"`
+	Attachments []attachmentResponse `json:"attachments,omitempty"`
+	Poll        *pollResponse        `json:"poll,omitempty"`
+	// ContentWarning and Sensitive tell clients why a post is collapsed
+	ContentWarning string `json:"content_warning,omitempty"`
+	Sensitive      bool   `json:"sensitive"`
+	// Collapsed is set for posts to show collapsed unless the viewer always
+	// expands them
+	Collapsed bool `json:"collapsed"`
+	// Reactions counts the reactions to the post by type
+	Reactions map[ReactionType]int `json:"reactions"`
+	// Reacted holds the types the viewer reacted to the post with
+	Reacted []ReactionType `json:"viewer_reactions,omitempty"`
+}
+
+// pollResponse shows the options of a poll. The votes are only counted for
+// viewers who voted and once the poll closed, so they can't sway the vote.
+type pollResponse struct {
+	Options   []pollOptionResponse `json:"options"`
+	ExpiresAt time.Time            `json:"expires_at"`
+	Closed    bool                 `json:"closed"`
+	// Choice is the option the viewer voted for
+	Choice     *int `json:"viewer_choice,omitempty"`
+	TotalVotes *int `json:"total_votes,omitempty"`
+}
+
+type pollOptionResponse struct {
+	Text  string `json:"text"`
+	Votes *int   `json:"votes,omitempty"`
+}
+
+// entityResponse locates a URL, mention or hashtag in a post body, both in
+// bytes and in runes, along with what it links to.
+type entityResponse struct {
+	Type      EntityType `json:"type"`
+	Text      string     `json:"text"`
+	ByteStart int        `json:"byte_start"`
+	ByteEnd   int        `json:"byte_end"`
+	RuneStart int        `json:"rune_start"`
+	RuneEnd   int        `json:"rune_end"`
+	UserID    ID         `json:"user_id,omitempty"`
+	Tag       string     `json:"tag,omitempty"`
+	URL       string     `json:"url,omitempty"`
+}
+
+// mediaFile is the file of an attachment along with whether anyone can see it
+type mediaFile struct {
+	io.ReadCloser
+	Public bool
+}
+
+type attachmentResponse struct {
+	ID           AttachmentID `json:"id"`
+	URL          string       `json:"url"`
+	ThumbnailURL string       `json:"thumbnail_url"`
+	ContentType  string       `json:"content_type"`
+	Width        int          `json:"width"`
+	Height       int          `json:"height"`
+	AltText      string       `json:"alt_text"`
+}
+
+type reactRequest struct {
+	Type ReactionType
+}
+
+type reactorResponse struct {
+	User      UserInfo     `json:"user"`
+	Type      ReactionType `json:"type"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+type threadNode struct {
+	postResponse
+	Replies []threadNode `json:"replies"`
+}
+
+type threadResponse struct {
+	Ancestors []postResponse `json:"ancestors"`
+	Post      threadNode     `json:"post"`
+}
+
+type revisionResponse struct {
+	Body      string    `json:"body"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// postPage is a page of posts along with the cursors of its neighbours.
+// Next selects older posts and Prev selects newer posts.
+type postPage struct {
+	Posts []postResponse
+	Next  string
+	Prev  string
+}
+
+type postsResponse struct {
+	Posts []postResponse `json:"posts"`
+	URL   string         `json:"url"`
+	Next  string         `json:"next,omitempty"`
+	Prev  string         `json:"prev,omitempty"`
+}
+
+type getPostResponse struct {
+	Post *postResponse `json:"post,omitempty"`
+	URL  string        `json:"url"`
 }
 
 type profileResponse struct {
@@ -87,8 +353,26 @@ type UserInfo struct {
 	Joined   time.Time `json:"joined"`
 }
 
-func NewService(users Repository, posts PostRepository) Service {
-	return &service{users: users, posts: posts}
+// NewService returns a Service storing users and posts in the given
+// repositories. Parts of the service not configured through opts are kept in
+// memory.
+func NewService(users Repository, posts PostRepository, opts ...Option) Service {
+	svc := &service{
+		users:       users,
+		posts:       posts,
+		reactions:   NewReactionRepository(),
+		attachments: NewAttachmentRepository(),
+		blobs:       NewBlobStore(),
+		drafts:      NewDraftRepository(),
+		votes:       NewVoteRepository(),
+		bookmarks:   NewBookmarkRepository(),
+		timelines:   NewTimelineRepository(),
+		trends:      NewTrendRepository(),
+	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	return svc
 }
 
 func (svc *service) CreateProfile(id string, username string, email string) {
@@ -109,7 +393,7 @@ func (svc *service) userExists(username string, email string) bool {
 	return false
 }
 
-func (svc *service) CreatePost(id ID, body string) (PostID, error) {
+func (svc *service) CreatePost(id ID, req createPostRequest) (PostID, error) {
 	if !IsValidID(string(id)) {
 		return "", ErrInvalidID
 	}
@@ -120,61 +404,1390 @@ func (svc *service) CreatePost(id ID, body string) (PostID, error) {
 	}
 
 	author := Author{UserID: user.ID}
-	post, err := NewPost(author, body)
+	post, err := NewPost(author, req.Body)
 	if err != nil {
 		return "", err
 	}
 
+	if err := post.SetVisibility(req.Visibility); err != nil {
+		return "", err
+	}
+
+	if !req.PublishAt.IsZero() {
+		if err := post.Schedule(req.PublishAt); err != nil {
+			return "", err
+		}
+	}
+
+	if req.Poll != nil {
+		if err := post.AddPoll(req.Poll.Options, time.Duration(req.Poll.ExpiresIn)*time.Second); err != nil {
+			return "", err
+		}
+	}
+
+	if err := post.SetContentWarning(req.ContentWarning, req.Sensitive); err != nil {
+		return "", err
+	}
+
 	// TODO refactor this to return next id
 	post.ID = PostID(xid.New().String())
+	post.ConversationID = post.ID
+
+	if req.InReplyTo != "" {
+		parent, err := svc.findParent(user.ID, req.InReplyTo)
+		if err != nil {
+			return "", err
+		}
+		post.ReplyTo(parent)
+	}
+
+	post.Mentions = svc.resolveMentions(post.Body)
+
+	if post.Attachments, err = svc.checkAttachments(user.ID, req.Attachments); err != nil {
+		return "", err
+	}
+
 	if err = svc.posts.Store(*post); err != nil {
 		return "", errors.New("error saving post")
 	}
 
+	if len(post.Attachments) > 0 {
+		if err := svc.attachments.Attach(user.ID, post.Attachments, post.ID); err != nil {
+			_ = svc.posts.Delete(post.ID)
+			if err == ErrInvalidAttachment {
+				return "", err
+			}
+			return "", errors.New("error attaching media")
+		}
+	}
+
+	if !post.IsScheduled() {
+		svc.fanOut(post)
+		svc.countTags(post)
+	}
+
 	return post.ID, nil
 }
 
-func (svc *service) GetUserPosts(username string) ([]*Post, error) {
+// checkAttachments returns the given attachments without duplicates if they
+// can all be added to a new post by the user
+func (svc *service) checkAttachments(id ID, ids []AttachmentID) ([]AttachmentID, error) {
+	var unique []AttachmentID
+	seen := map[AttachmentID]bool{}
+	for _, aid := range ids {
+		if !seen[aid] {
+			seen[aid] = true
+			unique = append(unique, aid)
+		}
+	}
+
+	if len(unique) > maxAttachments {
+		return nil, ErrTooManyAttachments
+	}
+	if len(unique) < 1 {
+		return nil, nil
+	}
+
+	found, err := svc.attachments.FindByIDs(unique)
+	if err != nil {
+		return nil, errors.New("error finding attachments")
+	}
+	if len(found) != len(unique) {
+		return nil, ErrInvalidAttachment
+	}
+
+	for _, a := range found {
+		if a.Owner != id || !a.IsOrphan() {
+			return nil, ErrInvalidAttachment
+		}
+	}
+	return unique, nil
+}
+
+// findParent returns the post with the given id if the user can reply to it
+func (svc *service) findParent(viewer ID, id PostID) (Post, error) {
+	if !IsValidID(string(id)) {
+		return Post{}, ErrNoParentPost
+	}
+
+	parent, err := svc.posts.FindByID(id)
+	if err == ErrPostNotFound || err == nil && (parent.IsScheduled() || !svc.visibleTo(viewer)(&parent)) {
+		return Post{}, ErrNoParentPost
+	}
+	return parent, err
+}
+
+func (svc *service) GetUserPosts(viewer ID, username string, page Page) (postPage, error) {
 	if username == "" {
-		return nil, ErrInvalidUsername
+		return postPage{}, ErrInvalidUsername
+	}
+
+	page, err := page.normalize()
+	if err != nil {
+		return postPage{}, err
+	}
+
+	user, err := svc.users.FindByName(username)
+	if err != nil {
+		return postPage{}, ErrNotFound
+	}
+
+	return svc.findPostPage(viewer, page, func(p Page) ([]*Post, error) {
+		return svc.posts.FindLatestPostsForUser(user.ID, p)
+	})
+}
+
+func (svc *service) GetPost(viewer ID, id PostID) (postResponse, error) {
+	if !IsValidID(string(id)) {
+		return postResponse{}, ErrPostNotFound
+	}
+
+	post, err := svc.posts.FindByID(id)
+	if err != nil {
+		return postResponse{}, err
+	}
+
+	if post.IsScheduled() && post.Author.UserID != viewer || !svc.visibleTo(viewer)(&post) {
+		return postResponse{}, ErrPostNotFound
+	}
+
+	res, err := svc.buildPostResponses(viewer, []*Post{&post})
+	if err != nil {
+		return postResponse{}, err
+	}
+
+	if len(res) < 1 {
+		// a repost of a deleted post
+		return postResponse{}, ErrPostNotFound
+	}
+	return res[0], nil
+}
+
+// GetTagPosts returns the posts tagged with tag, newest first. The tag may be
+// given with or without its leading #.
+func (svc *service) GetTagPosts(viewer ID, tag string, page Page) (postPage, error) {
+	tag = normalizeTag(tag)
+	if !isValidTag(tag) {
+		return postPage{}, ErrInvalidTag
+	}
+
+	page, err := page.normalize()
+	if err != nil {
+		return postPage{}, err
+	}
+
+	return svc.findPostPage(viewer, page, func(p Page) ([]*Post, error) {
+		return svc.posts.FindByTag(tag, p)
+	})
+}
+
+// GetExplore returns the recent public posts of every user, newest first,
+// leaving out plain reposts. With engaged set only the posts that have been
+// replied to, reposted or reacted to are returned.
+func (svc *service) GetExplore(viewer ID, engaged bool, page Page) (postPage, error) {
+	page, err := page.normalize()
+	if err != nil {
+		return postPage{}, err
+	}
+
+	find := func(p Page) ([]*Post, error) {
+		return svc.posts.FindPublic(time.Now().Add(-exploreWindow), p)
+	}
+	if engaged {
+		return svc.findEngagedPage(viewer, page, find)
+	}
+	return svc.findPostPage(viewer, page, find)
+}
+
+// findEngagedPage finds a page of the posts with engagement among those found
+// by find. It keeps finding posts further from the cursor until it has as
+// many as the page asks for, there are none left or it has read
+// maxEngagedBatches batches. A page cut short by the last of these has a
+// cursor to the last post read, so that the next page picks up from there.
+func (svc *service) findEngagedPage(viewer ID, page Page, find func(Page) ([]*Post, error)) (postPage, error) {
+	var res []*Post
+	var last string
+	p := page
+	p.Limit++
+	done := false
+	for i := 0; i < maxEngagedBatches && !done && len(res) < p.Limit; i++ {
+		posts, err := find(p)
+		if err != nil {
+			return postPage{}, errors.New("error finding posts")
+		}
+
+		engaged, err := svc.filterEngaged(posts)
+		if err != nil {
+			return postPage{}, err
+		}
+
+		done = len(posts) < p.Limit
+		if len(posts) < 1 {
+			break
+		}
+
+		if page.After != "" {
+			// walking upwards from the cursor finds newer posts, which go first
+			res = append(engaged, res...)
+			last = string(posts[0].ID)
+			p.After = last
+		} else {
+			res = append(res, engaged...)
+			last = string(posts[len(posts)-1].ID)
+			p.Before = last
+		}
+	}
+
+	if len(res) > p.Limit {
+		// keep the posts closest to the cursor
+		if page.After != "" {
+			res = res[len(res)-p.Limit:]
+		} else {
+			res = res[:p.Limit]
+		}
+	}
+
+	ids := make([]string, len(res))
+	for i, p := range res {
+		ids[i] = string(p.ID)
+	}
+
+	lo, hi, prev, next := cutPage(page, ids)
+	if !done && len(res) < p.Limit {
+		if page.After != "" {
+			prev = last
+		} else {
+			next = last
+		}
+	}
+
+	pp := postPage{Prev: prev, Next: next}
+	var err error
+	pp.Posts, err = svc.buildPostResponses(viewer, svc.filterVisible(viewer, res[lo:hi]))
+	if err != nil {
+		return postPage{}, err
+	}
+	return pp, nil
+}
+
+// filterEngaged leaves out the posts nobody has replied to, reposted or
+// reacted to
+func (svc *service) filterEngaged(posts []*Post) ([]*Post, error) {
+	res := make([]*Post, 0, len(posts))
+	if len(posts) < 1 {
+		return res, nil
+	}
+
+	ids := make([]PostID, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	replies, err := svc.posts.CountReplies(ids)
+	if err != nil {
+		return nil, errors.New("error counting replies")
+	}
+
+	reposts, err := svc.posts.CountReposts(ids)
+	if err != nil {
+		return nil, errors.New("error counting reposts")
+	}
+
+	reactions, err := svc.reactions.CountByPosts(ids)
+	if err != nil {
+		return nil, errors.New("error counting reactions")
+	}
+
+	for _, p := range posts {
+		n := replies[p.ID] + reposts[p.ID]
+		for _, c := range reactions[p.ID] {
+			n += c
+		}
+		if n > 0 {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
+// SearchPosts returns the posts matching query that the viewer can read,
+// ranked as the page asks. The page cursors are offsets into the results.
+func (svc *service) SearchPosts(viewer ID, query string, page SearchPage) (postPage, error) {
+	page, err := page.normalize()
+	if err != nil {
+		return postPage{}, err
+	}
+
+	q, err := ParseSearchQuery(query)
+	if err != nil {
+		return postPage{}, err
+	}
+
+	if q.From != "" {
+		user, err := svc.users.FindByName(q.From)
+		if err != nil {
+			// nobody by that name has posted anything
+			return postPage{Posts: []postResponse{}}, nil
+		}
+		q.Author = user.ID
+	}
+
+	if viewer != "" {
+		user, err := svc.users.FindByID(viewer)
+		if err != nil {
+			return postPage{}, ErrNotFound
+		}
+		q.Viewer, q.Following = user.ID, user.Friends
+	}
+
+	p := page
+	p.Limit++
+	posts, err := svc.posts.Search(q, p)
+	if err != nil {
+		return postPage{}, errors.New("error searching posts")
+	}
+
+	var pp postPage
+	if len(posts) > page.Limit {
+		posts = posts[:page.Limit]
+		pp.Next = strconv.Itoa(page.Offset + page.Limit)
+	}
+	if page.Offset > 0 {
+		prev := page.Offset - page.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		pp.Prev = strconv.Itoa(prev)
+	}
+
+	// the search only finds the posts the viewer can read, so pages are full
+	pp.Posts, err = svc.buildPostResponses(viewer, posts)
+	if err != nil {
+		return postPage{}, err
+	}
+	return pp, nil
+}
+
+// GetUserMentions returns the posts mentioning the user, newest first
+func (svc *service) GetUserMentions(viewer ID, username string, page Page) (postPage, error) {
+	if username == "" {
+		return postPage{}, ErrInvalidUsername
+	}
+
+	page, err := page.normalize()
+	if err != nil {
+		return postPage{}, err
+	}
+
+	user, err := svc.users.FindByName(username)
+	if err != nil {
+		return postPage{}, ErrNotFound
+	}
+
+	return svc.findPostPage(viewer, page, func(p Page) ([]*Post, error) {
+		return svc.posts.FindMentions(user.ID, p)
+	})
+}
+
+// resolveMentions looks up the users mentioned in body. Mentions of users
+// that can't be found are left as plain text.
+func (svc *service) resolveMentions(body string) []Mention {
+	var mentions []Mention
+	for _, username := range mentionedUsernames(body) {
+		if u, err := svc.users.FindByName(username); err == nil {
+			mentions = append(mentions, Mention{UserID: u.ID, Username: username})
+		}
+	}
+	return mentions
+}
+
+// GetThread returns the post with the given id, the chain of posts it replies
+// to starting from the root of the conversation, and its replies as a tree.
+func (svc *service) GetThread(viewer ID, id PostID) (threadResponse, error) {
+	if !IsValidID(string(id)) {
+		return threadResponse{}, ErrPostNotFound
+	}
+
+	post, err := svc.posts.FindByID(id)
+	if err != nil {
+		return threadResponse{}, err
+	}
+
+	if post.IsScheduled() || !svc.visibleTo(viewer)(&post) {
+		return threadResponse{}, ErrPostNotFound
+	}
+
+	conversation := post.ConversationID
+	if conversation == "" {
+		conversation = post.ID
+	}
+
+	posts, err := svc.posts.FindByConversation(conversation)
+	if err != nil {
+		return threadResponse{}, errors.New("error finding conversation")
+	}
+	// replies the viewer can't see are left out along with their own replies
+	posts = svc.filterVisible(viewer, posts)
+
+	// replies are read in the order they were published
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[j].newerThan(posts[i])
+	})
+
+	responses, err := svc.buildPostResponses(viewer, posts)
+	if err != nil {
+		return threadResponse{}, err
+	}
+
+	byID := map[PostID]postResponse{}
+	parents := map[PostID]PostID{}
+	children := map[PostID][]PostID{}
+	for i, p := range posts {
+		byID[p.ID] = responses[i]
+		parents[p.ID] = p.InReplyTo
+		if p.InReplyTo != "" {
+			children[p.InReplyTo] = append(children[p.InReplyTo], p.ID)
+		}
+	}
+
+	ancestors := []postResponse{}
+	for pid := parents[post.ID]; pid != ""; pid = parents[pid] {
+		pr, ok := byID[pid]
+		if !ok {
+			// the rest of the chain was deleted
+			break
+		}
+		ancestors = append([]postResponse{pr}, ancestors...)
+	}
+
+	var build func(id PostID) threadNode
+	build = func(id PostID) threadNode {
+		node := threadNode{postResponse: byID[id], Replies: []threadNode{}}
+		for _, c := range children[id] {
+			node.Replies = append(node.Replies, build(c))
+		}
+		return node
+	}
+
+	return threadResponse{Ancestors: ancestors, Post: build(post.ID)}, nil
+}
+
+func (svc *service) EditPost(id ID, postID PostID, body string) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	post, err := svc.findOwnPost(id, postID)
+	if err != nil {
+		return err
+	}
+
+	before := post
+	if err := post.Edit(body); err != nil {
+		return err
+	}
+	post.Mentions = svc.resolveMentions(post.Body)
+
+	if err := svc.posts.Update(post); err != nil {
+		return fmt.Errorf("error updating post: %s", err.Error())
+	}
+
+	if !post.IsScheduled() && strings.Join(before.Tags, " ") != strings.Join(post.Tags, " ") {
+		svc.uncountTags(&before)
+		svc.countTags(&post)
+	}
+	return nil
+}
+
+func (svc *service) GetPostRevisions(viewer ID, id PostID) ([]revisionResponse, error) {
+	if !IsValidID(string(id)) {
+		return nil, ErrPostNotFound
+	}
+
+	post, err := svc.posts.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if post.IsScheduled() || !svc.visibleTo(viewer)(&post) {
+		return nil, ErrPostNotFound
+	}
+
+	res := []revisionResponse{}
+	for _, r := range post.Revisions {
+		res = append(res, revisionResponse{Body: r.Body, Timestamp: r.Timestamp})
+	}
+	return res, nil
+}
+
+func (svc *service) DeletePost(id ID, postID PostID) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	post, err := svc.findOwnPost(id, postID)
+	if err != nil {
+		return err
+	}
+
+	return svc.deletePost(post)
+}
+
+// deletePost deletes a post along with its reactions and attachments
+func (svc *service) deletePost(post Post) error {
+	if err := svc.posts.Delete(post.ID); err != nil {
+		return fmt.Errorf("error deleting post: %s", err.Error())
+	}
+	svc.uncountTags(&post)
+
+	if author, err := svc.users.FindByID(post.Author.UserID); err == nil && author.HasPinned(post.ID) {
+		_ = author.Unpin(post.ID)
+		if err := svc.users.Update(author); err != nil {
+			return fmt.Errorf("error unpinning post: %s", err.Error())
+		}
+	}
+
+	if err := svc.reactions.DeleteByPost(post.ID); err != nil {
+		return fmt.Errorf("error deleting post reactions: %s", err.Error())
+	}
+
+	if post.Poll != nil {
+		if err := svc.votes.DeleteByPost(post.ID); err != nil {
+			return fmt.Errorf("error deleting poll votes: %s", err.Error())
+		}
+	}
+
+	if err := svc.bookmarks.DeleteByPost(post.ID); err != nil {
+		return fmt.Errorf("error deleting post bookmarks: %s", err.Error())
+	}
+
+	if err := svc.timelines.RemovePost(post.ID); err != nil {
+		return fmt.Errorf("error removing post from timelines: %s", err.Error())
+	}
+
+	attachments, err := svc.attachments.FindByPost(post.ID)
+	if err != nil {
+		return fmt.Errorf("error finding post attachments: %s", err.Error())
+	}
+	for _, a := range attachments {
+		if err := svc.deleteAttachment(a, svc.attachments.Delete); err != nil {
+			return fmt.Errorf("error deleting post attachments: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// GetScheduledPosts returns the posts the user scheduled, due first
+func (svc *service) GetScheduledPosts(id ID) ([]postResponse, error) {
+	if !IsValidID(string(id)) {
+		return nil, ErrInvalidID
+	}
+
+	posts, err := svc.posts.FindScheduled(id)
+	if err != nil {
+		return nil, errors.New("error finding scheduled posts")
+	}
+
+	return svc.buildPostResponses(id, posts)
+}
+
+func (svc *service) ReschedulePost(id ID, postID PostID, at time.Time) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	post, err := svc.findScheduledPost(id, postID)
+	if err != nil {
+		return err
+	}
+
+	if err := post.Schedule(at); err != nil {
+		return err
+	}
+
+	if err := svc.posts.Update(post); err != nil {
+		return fmt.Errorf("error updating post: %s", err.Error())
+	}
+	return nil
+}
+
+func (svc *service) CancelScheduledPost(id ID, postID PostID) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	post, err := svc.findScheduledPost(id, postID)
+	if err != nil {
+		return err
+	}
+
+	return svc.deletePost(post)
+}
+
+// findScheduledPost returns the user's post with the given id if it is
+// scheduled
+func (svc *service) findScheduledPost(id ID, postID PostID) (Post, error) {
+	post, err := svc.findOwnPost(id, postID)
+	if err != nil {
+		return Post{}, err
+	}
+
+	if !post.IsScheduled() {
+		return Post{}, ErrNotScheduled
+	}
+	return post, nil
+}
+
+// PublishDuePosts publishes the scheduled posts that are due and returns how
+// many were published.
+func (svc *service) PublishDuePosts() (int, error) {
+	due, err := svc.posts.FindDue(time.Now().UTC())
+	if err != nil {
+		return 0, errors.New("error finding due posts")
+	}
+
+	n := 0
+	for _, p := range due {
+		err := svc.publish(*p)
+		if err == ErrNotScheduled {
+			// published or cancelled in the meantime
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("error publishing post %s: %s", p.ID, err.Error())
+		}
+		n++
+	}
+	return n, nil
+}
+
+// publish publishes a scheduled post in place, so that it keeps the id it
+// was given when scheduled while its timestamp places it in feeds at the
+// time it is published rather than when it was written.
+func (svc *service) publish(scheduled Post) error {
+	post, err := svc.posts.Publish(scheduled.ID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	// the poll runs from when the post is actually published, which is
+	// later than planned if the post was due while no publisher ran
+	if post.Poll != nil {
+		post.Poll.open(post.Timestamp)
+		if err := svc.posts.Update(post); err != nil {
+			return err
+		}
+	}
+
+	svc.fanOut(&post)
+	svc.countTags(&post)
+	return nil
+}
+
+func (svc *service) CreateDraft(id ID, req draftRequest) (DraftID, error) {
+	if !IsValidID(string(id)) {
+		return "", ErrInvalidID
+	}
+
+	if _, err := svc.users.FindByID(id); err != nil {
+		return "", err
+	}
+
+	draft, err := NewDraft(id, req.Body, req.InReplyTo)
+	if err != nil {
+		return "", err
+	}
+
+	if err := svc.drafts.Store(*draft); err != nil {
+		return "", errors.New("error saving draft")
+	}
+	return draft.ID, nil
+}
+
+func (svc *service) UpdateDraft(id ID, draftID DraftID, req draftRequest) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	draft, err := svc.findOwnDraft(id, draftID)
+	if err != nil {
+		return err
+	}
+
+	if err := draft.Edit(req.Body, req.InReplyTo); err != nil {
+		return err
+	}
+
+	if err := svc.drafts.Update(draft); err != nil {
+		return fmt.Errorf("error updating draft: %s", err.Error())
+	}
+	return nil
+}
+
+// GetDrafts returns the user's drafts, most recently updated first
+func (svc *service) GetDrafts(id ID) ([]draftResponse, error) {
+	if !IsValidID(string(id)) {
+		return nil, ErrInvalidID
+	}
+
+	drafts, err := svc.drafts.FindByAuthor(id)
+	if err != nil {
+		return nil, errors.New("error finding drafts")
+	}
+
+	res := []draftResponse{}
+	for _, d := range drafts {
+		res = append(res, draftResponse{
+			ID:        d.ID,
+			Body:      d.Body,
+			InReplyTo: d.InReplyTo,
+			CreatedAt: d.CreatedAt,
+			UpdatedAt: d.UpdatedAt,
+		})
+	}
+	return res, nil
+}
+
+func (svc *service) DeleteDraft(id ID, draftID DraftID) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	if _, err := svc.findOwnDraft(id, draftID); err != nil {
+		return err
+	}
+
+	if err := svc.drafts.Delete(draftID); err != nil {
+		return fmt.Errorf("error deleting draft: %s", err.Error())
+	}
+	return nil
+}
+
+// PublishDraft creates a post from the draft, held to the same rules as any
+// other post, and deletes the draft. The draft is kept if it can't be posted.
+func (svc *service) PublishDraft(id ID, draftID DraftID) (PostID, error) {
+	if !IsValidID(string(id)) {
+		return "", ErrInvalidID
+	}
+
+	draft, err := svc.findOwnDraft(id, draftID)
+	if err != nil {
+		return "", err
+	}
+
+	postID, err := svc.CreatePost(id, createPostRequest{Body: draft.Body, InReplyTo: draft.InReplyTo})
+	if err != nil {
+		return "", err
+	}
+
+	if err := svc.drafts.Delete(draft.ID); err != nil && err != ErrDraftNotFound {
+		return postID, fmt.Errorf("error deleting draft: %s", err.Error())
+	}
+	return postID, nil
+}
+
+// findOwnDraft returns the draft with the given id if it belongs to the user.
+// Drafts of other users are not found since they are private.
+func (svc *service) findOwnDraft(id ID, draftID DraftID) (Draft, error) {
+	if !IsValidID(string(draftID)) {
+		return Draft{}, ErrDraftNotFound
+	}
+
+	draft, err := svc.drafts.FindByID(draftID)
+	if err != nil {
+		return Draft{}, err
+	}
+
+	if draft.Author != id {
+		return Draft{}, ErrDraftNotFound
+	}
+	return draft, nil
+}
+
+// Repost shares the post with the given id on behalf of the user. The post is
+// shared as is when body is empty, and quoted with body as commentary otherwise.
+func (svc *service) Repost(id ID, postID PostID, body string) (PostID, error) {
+	if !IsValidID(string(id)) {
+		return "", ErrInvalidID
+	}
+
+	user, err := svc.users.FindByID(id)
+	if err != nil {
+		return "", ErrNotFound
+	}
+
+	original, err := svc.findOriginal(id, postID)
+	if err != nil {
+		return "", err
+	}
+
+	author := Author{UserID: user.ID}
+	var post *Post
+	if body == "" {
+		if _, err := svc.posts.FindRepost(user.ID, original.ID); err == nil {
+			return "", ErrAlreadyReposted
+		}
+		post, err = NewRepost(author, original)
+	} else {
+		post, err = NewQuote(author, original, body)
+	}
+	if err != nil {
+		return "", err
+	}
+	post.Mentions = svc.resolveMentions(post.Body)
+
+	post.ID = PostID(xid.New().String())
+	post.ConversationID = post.ID
+
+	if err = svc.posts.Store(*post); err != nil {
+		if err == ErrAlreadyReposted {
+			return "", err
+		}
+		return "", errors.New("error saving post")
+	}
+
+	svc.fanOut(post)
+	svc.countTags(post)
+
+	return post.ID, nil
+}
+
+func (svc *service) RemoveRepost(id ID, postID PostID) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	original, err := svc.findOriginal(id, postID)
+	if err != nil {
+		return err
+	}
+
+	repost, err := svc.posts.FindRepost(id, original.ID)
+	if err == ErrPostNotFound {
+		return ErrNotReposted
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := svc.posts.Delete(repost.ID); err != nil {
+		return fmt.Errorf("error deleting repost: %s", err.Error())
+	}
+
+	if err := svc.timelines.RemovePost(repost.ID); err != nil {
+		return fmt.Errorf("error removing repost from timelines: %s", err.Error())
+	}
+	return nil
+}
+
+func (svc *service) React(id ID, postID PostID, t ReactionType) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	post, err := svc.findOriginal(id, postID)
+	if err != nil {
+		return err
+	}
+
+	reaction, err := NewReaction(post.ID, id, t)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.reactions.Store(*reaction); err != nil {
+		if err == ErrAlreadyReacted {
+			return err
+		}
+		return errors.New("error saving reaction")
+	}
+	return nil
+}
+
+// BookmarkPost saves a post for the user to read later. Bookmarking a repost
+// saves the post it shares.
+func (svc *service) BookmarkPost(id ID, postID PostID) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	post, err := svc.findOriginal(id, postID)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.bookmarks.Store(*NewBookmark(id, post.ID)); err != nil {
+		if err == ErrAlreadyBookmarked {
+			return err
+		}
+		return errors.New("error saving bookmark")
+	}
+	return nil
+}
+
+func (svc *service) RemoveBookmark(id ID, postID PostID) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	if !IsValidID(string(postID)) {
+		return ErrPostNotFound
+	}
+
+	// bookmarks of reposts are saved against the post they share
+	if post, err := svc.posts.FindByID(postID); err == nil && post.IsRepost() {
+		postID = post.RepostOf
+	}
+
+	if err := svc.bookmarks.Delete(id, postID); err != nil {
+		if err == ErrNotBookmarked {
+			return err
+		}
+		return errors.New("error removing bookmark")
+	}
+	return nil
+}
+
+// GetBookmarks returns the posts the user bookmarked, most recently
+// bookmarked first. The page cursors are bookmark ids.
+func (svc *service) GetBookmarks(id ID, page Page) (postPage, error) {
+	if !IsValidID(string(id)) {
+		return postPage{}, ErrInvalidID
+	}
+
+	page, err := page.normalize()
+	if err != nil {
+		return postPage{}, err
+	}
+
+	p := page
+	p.Limit++
+	bookmarks, err := svc.bookmarks.FindByUser(id, p)
+	if err != nil {
+		return postPage{}, errors.New("error finding bookmarks")
+	}
+
+	ids := make([]string, len(bookmarks))
+	for i, b := range bookmarks {
+		ids[i] = string(b.ID)
+	}
+
+	lo, hi, prev, next := cutPage(page, ids)
+	bookmarks = bookmarks[lo:hi]
+
+	postIDs := make([]PostID, len(bookmarks))
+	for i, b := range bookmarks {
+		postIDs[i] = b.PostID
+	}
+
+	found, err := svc.posts.FindByIDs(postIDs)
+	if err != nil {
+		return postPage{}, errors.New("error finding posts")
+	}
+
+	byID := map[PostID]*Post{}
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+
+	var posts []*Post
+	for _, id := range postIDs {
+		if p, ok := byID[id]; ok {
+			posts = append(posts, p)
+		}
+	}
+
+	pp := postPage{Prev: prev, Next: next}
+	pp.Posts, err = svc.buildPostResponses(id, svc.filterVisible(id, posts))
+	if err != nil {
+		return postPage{}, err
+	}
+	return pp, nil
+}
+
+func (svc *service) Unreact(id ID, postID PostID, t ReactionType) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	if _, ok := reactionEmoji[t]; !ok {
+		return ErrInvalidReaction
+	}
+
+	post, err := svc.findOriginal(id, postID)
+	if err != nil {
+		return err
+	}
+
+	return svc.reactions.Delete(post.ID, id, t)
+}
+
+// Vote records the user's choice in the poll of a post
+func (svc *service) Vote(id ID, postID PostID, choice int) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	post, err := svc.findOriginal(id, postID)
+	if err != nil {
+		return err
+	}
+
+	vote, err := NewVote(post, id, choice)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.votes.Store(*vote); err != nil {
+		if err == ErrAlreadyVoted {
+			return err
+		}
+		return errors.New("error saving vote")
+	}
+	return nil
+}
+
+// GetReactions returns the users who reacted to a post, most recent first
+func (svc *service) GetReactions(viewer ID, postID PostID) ([]reactorResponse, error) {
+	post, err := svc.findOriginal(viewer, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	reactions, err := svc.reactions.FindByPost(post.ID)
+	if err != nil {
+		return nil, errors.New("error finding reactions")
+	}
+
+	res := []reactorResponse{}
+	if len(reactions) < 1 {
+		return res, nil
+	}
+
+	var ids []ID
+	for _, r := range reactions {
+		ids = append(ids, r.UserID)
+	}
+
+	users, err := svc.users.FindByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := map[ID]UserInfo{}
+	for _, info := range buildUserInfosFromUsers(users) {
+		infos[info.ID] = info
+	}
+
+	for _, r := range reactions {
+		if info, ok := infos[r.UserID]; ok {
+			res = append(res, reactorResponse{User: info, Type: r.Type, Timestamp: r.Timestamp})
+		}
+	}
+	return res, nil
+}
+
+// UploadMedia stores an image for the user to attach to a post. The image is
+// re-encoded without its metadata and a thumbnail is made for it.
+func (svc *service) UploadMedia(id ID, data []byte, altText string) (attachmentResponse, error) {
+	if !IsValidID(string(id)) {
+		return attachmentResponse{}, ErrInvalidID
+	}
+
+	user, err := svc.users.FindByID(id)
+	if err != nil {
+		return attachmentResponse{}, ErrNotFound
+	}
+
+	img, err := processImage(data)
+	if err != nil {
+		return attachmentResponse{}, err
+	}
+
+	a, err := NewAttachment(user.ID, img, altText)
+	if err != nil {
+		return attachmentResponse{}, err
+	}
+
+	if err := svc.blobs.Put(a.Key, bytes.NewReader(img.Data)); err != nil {
+		return attachmentResponse{}, errors.New("error saving media")
+	}
+
+	if err := svc.blobs.Put(a.ThumbnailKey, bytes.NewReader(img.Thumbnail)); err != nil {
+		_ = svc.blobs.Delete(a.Key)
+		return attachmentResponse{}, errors.New("error saving media")
+	}
+
+	if err := svc.attachments.Store(*a); err != nil {
+		_ = svc.blobs.Delete(a.Key)
+		_ = svc.blobs.Delete(a.ThumbnailKey)
+		return attachmentResponse{}, errors.New("error saving media")
+	}
+
+	return newAttachmentResponse(*a), nil
+}
+
+// GetMedia returns a file of an attachment the viewer can see. Attachments
+// are only seen by their owner until they are added to a post, and then by
+// whoever can read the post.
+func (svc *service) GetMedia(viewer ID, key string) (mediaFile, error) {
+	found, err := svc.attachments.FindByIDs([]AttachmentID{attachmentIDFromKey(key)})
+	if err != nil {
+		return mediaFile{}, errors.New("error finding media")
+	}
+	if len(found) < 1 || found[0].Key != key && found[0].ThumbnailKey != key {
+		return mediaFile{}, ErrBlobNotFound
+	}
+
+	a, public := found[0], false
+	if a.IsOrphan() {
+		if a.Owner != viewer {
+			return mediaFile{}, ErrBlobNotFound
+		}
+	} else {
+		post, err := svc.posts.FindByID(a.PostID)
+		if err == ErrPostNotFound {
+			return mediaFile{}, ErrBlobNotFound
+		}
+		if err != nil {
+			return mediaFile{}, errors.New("error finding post")
+		}
+		if post.IsScheduled() && post.Author.UserID != viewer || !svc.visibleTo(viewer)(&post) {
+			return mediaFile{}, ErrBlobNotFound
+		}
+		public = post.IsPublic() && !post.IsScheduled()
+	}
+
+	f, err := svc.blobs.Get(key)
+	if err != nil {
+		return mediaFile{}, err
+	}
+	return mediaFile{ReadCloser: f, Public: public}, nil
+}
+
+// DeleteOrphanedMedia deletes the media uploaded more than ttl ago that was
+// never added to a post and returns how many were deleted.
+func (svc *service) DeleteOrphanedMedia(ttl time.Duration) (int, error) {
+	orphans, err := svc.attachments.FindOrphans(time.Now().UTC().Add(-ttl))
+	if err != nil {
+		return 0, errors.New("error finding orphaned media")
+	}
+
+	deleted := 0
+	for _, a := range orphans {
+		err := svc.deleteAttachment(a, svc.attachments.DeleteOrphan)
+		if err == ErrAttachmentNotFound {
+			// it was added to a post in the meantime
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("error deleting orphaned media: %s", err.Error())
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// deleteAttachment deletes the record of an attachment with del, then its
+// files. Files are kept if the record can't be deleted so that no attachment
+// points to missing files.
+func (svc *service) deleteAttachment(a Attachment, del func(AttachmentID) error) error {
+	if err := del(a.ID); err != nil {
+		return err
+	}
+
+	if err := svc.blobs.Delete(a.Key); err != nil {
+		return err
+	}
+	return svc.blobs.Delete(a.ThumbnailKey)
+}
+
+func newAttachmentResponse(a Attachment) attachmentResponse {
+	return attachmentResponse{
+		ID:           a.ID,
+		URL:          mediaURL(a.Key),
+		ThumbnailURL: mediaURL(a.ThumbnailKey),
+		ContentType:  a.ContentType,
+		Width:        a.Width,
+		Height:       a.Height,
+		AltText:      a.AltText,
+	}
+}
+
+func mediaURL(key string) string {
+	return "/v1/media/" + key
+}
+
+// findOriginal returns the post with the given id or, if it is a repost,
+// the post it shares, as long as the viewer can see it.
+func (svc *service) findOriginal(viewer ID, id PostID) (Post, error) {
+	if !IsValidID(string(id)) {
+		return Post{}, ErrPostNotFound
+	}
+
+	post, err := svc.posts.FindByID(id)
+	if err != nil {
+		return Post{}, err
+	}
+
+	if post.IsScheduled() {
+		return Post{}, ErrPostNotFound
+	}
+
+	if post.IsRepost() {
+		if post, err = svc.posts.FindByID(post.RepostOf); err != nil {
+			return Post{}, err
+		}
+	}
+
+	if !svc.visibleTo(viewer)(&post) {
+		return Post{}, ErrPostNotFound
+	}
+	return post, nil
+}
+
+// visibleTo returns a function reporting whether the viewer can read a post.
+// The authors the viewer follows are only looked up once a post needs them.
+func (svc *service) visibleTo(viewer ID) func(p *Post) bool {
+	var following map[ID]bool
+	return func(p *Post) bool {
+		if p.IsPublic() || viewer == "" {
+			return p.IsVisibleTo(viewer, false)
+		}
+
+		if following == nil {
+			following = map[ID]bool{}
+			if u, err := svc.users.FindByID(viewer); err == nil {
+				for _, id := range u.Friends {
+					following[id] = true
+				}
+			}
+		}
+		return p.IsVisibleTo(viewer, following[p.Author.UserID])
+	}
+}
+
+// filterVisible leaves out the posts the viewer can't read
+func (svc *service) filterVisible(viewer ID, posts []*Post) []*Post {
+	visible := svc.visibleTo(viewer)
+	res := make([]*Post, 0, len(posts))
+	for _, p := range posts {
+		if visible(p) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func (svc *service) GetProfile(viewer ID, username string) (Profile, error) {
+	if username == "" {
+		return Profile{}, ErrInvalidUsername
+	}
+
+	user, err := svc.users.FindByName(username)
+	if err != nil {
+		return Profile{}, ErrNotFound
+	}
+
+	posts, err := svc.findPostPage(viewer, Page{Limit: defaultPageLimit}, func(p Page) ([]*Post, error) {
+		return svc.posts.FindLatestPostsForUser(user.ID, p)
+	})
+	if err != nil {
+		return Profile{}, err
+	}
+
+	pinned, err := svc.findPinned(viewer, user)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	profile := Profile{
+		ID:       user.ID,
+		Username: username,
+		Avatar:   avatar(user.Email),
+		Bio:      user.Bio,
+		Joined:   user.CreatedAt,
+		LastSeen: user.LastSeen,
+		Relationships: Relationships{
+			Followers: len(user.Followers),
+			Friends:   len(user.Friends),
+		},
+		Pinned: pinned,
+		Posts:  posts.Posts,
+	}
+
+	if viewer == user.ID {
+		expand := user.ExpandSensitive
+		profile.ExpandSensitive = &expand
+	}
+	return profile, nil
+}
+
+// findPinned returns the posts the user pinned that the viewer can see, in
+// the order they are pinned
+func (svc *service) findPinned(viewer ID, user *User) ([]postResponse, error) {
+	if len(user.Pinned) < 1 {
+		return []postResponse{}, nil
+	}
+
+	found, err := svc.posts.FindByIDs(user.Pinned)
+	if err != nil {
+		return nil, errors.New("error finding pinned posts")
+	}
+
+	byID := map[PostID]*Post{}
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+
+	var posts []*Post
+	for _, id := range user.Pinned {
+		if p, ok := byID[id]; ok {
+			posts = append(posts, p)
+		}
+	}
+
+	return svc.buildPostResponses(viewer, svc.filterVisible(viewer, posts))
+}
+
+func (svc *service) PinPost(id ID, postID PostID) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
+	}
+
+	post, err := svc.findOwnPost(id, postID)
+	if err != nil {
+		return err
+	}
+	if post.IsScheduled() {
+		return ErrPostNotFound
 	}
 
-	user, err := svc.users.FindByName(username)
+	user, err := svc.users.FindByID(id)
 	if err != nil {
-		return nil, ErrNotFound
+		return ErrNotFound
 	}
 
-	return svc.posts.FindLatestPostsForUser(user.ID)
+	if err := user.Pin(post.ID); err != nil {
+		return err
+	}
+	return svc.users.Update(user)
 }
 
-func (svc *service) GetProfile(username string) (Profile, error) {
-	if username == "" {
-		return Profile{}, ErrInvalidUsername
+func (svc *service) UnpinPost(id ID, postID PostID) error {
+	if !IsValidID(string(id)) {
+		return ErrInvalidID
 	}
 
-	user, err := svc.users.FindByName(username)
+	user, err := svc.users.FindByID(id)
 	if err != nil {
-		return Profile{}, ErrNotFound
+		return ErrNotFound
 	}
 
-	posts, err := svc.posts.FindLatestPostsForUser(user.ID)
-	if err != nil {
-		return Profile{}, errors.New("error finding latest posts")
+	if err := user.Unpin(postID); err != nil {
+		return err
 	}
-
-	return Profile{
-		ID:       user.ID,
-		Username: username,
-		Avatar:   avatar(user.Email),
-		Bio:      user.Bio,
-		Joined:   user.CreatedAt,
-		LastSeen: user.LastSeen,
-		Relationships: Relationships{
-			Followers: len(user.Followers),
-			Friends:   len(user.Friends),
-		},
-		Posts: buildPostResponses(posts, user),
-	}, nil
+	return svc.users.Update(user)
 }
 
 func (svc *service) EditProfile(id ID, req editProfileRequest) error {
@@ -182,7 +1795,7 @@ func (svc *service) EditProfile(id ID, req editProfileRequest) error {
 		return ErrInvalidID
 	}
 
-	if req.Username == nil && req.Bio == nil {
+	if req.Username == nil && req.Bio == nil && req.ExpandSensitive == nil {
 		return nil
 	}
 
@@ -203,6 +1816,10 @@ func (svc *service) EditProfile(id ID, req editProfileRequest) error {
 		}
 	}
 
+	if req.ExpandSensitive != nil {
+		user.ExpandSensitive = *req.ExpandSensitive
+	}
+
 	if err := svc.users.Update(user); err != nil {
 		return err
 	}
@@ -252,6 +1869,11 @@ func (svc *service) CreateRelationshipFor(id ID, username string) error {
 		return err
 	}
 
+	// the posts of popular accounts are read from their own posts instead
+	if !u2.isPopular() {
+		svc.backfillTimeline(u1.ID, u2.ID)
+	}
+
 	return nil
 }
 
@@ -279,6 +1901,10 @@ func (svc *service) RemoveRelationshipFor(id ID, username string) error {
 		return err
 	}
 
+	if err := svc.timelines.RemoveAuthor(u1.ID, u2.ID); err != nil {
+		log.Printf("error taking the posts of %s out of the timeline of %s: %s", u2.ID, u1.ID, err)
+	}
+
 	return nil
 }
 
@@ -318,19 +1944,258 @@ func (svc *service) GetUserFollowers(username string) ([]UserInfo, error) {
 	return buildUserInfosFromUsers(followers), nil
 }
 
-func (svc *service) GetTimeline(id ID) ([]postResponse, error) {
+// SearchUsers returns up to limit users whose username or bio matches the
+// query, best matches and most followed users first
+func (svc *service) SearchUsers(query string, limit int) ([]UserInfo, error) {
+	q := NormalizeUserSearch(query)
+	if q == "" {
+		return nil, ErrEmptySearch
+	}
+
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	users, err := svc.users.Search(q, limit)
+	if err != nil {
+		return nil, errors.New("error searching users")
+	}
+	return buildUserInfosFromUsers(users), nil
+}
+
+func (svc *service) GetTimeline(id ID, page Page) (postPage, error) {
 	if !IsValidID(string(id)) {
-		return nil, ErrInvalidID
+		return postPage{}, ErrInvalidID
+	}
+
+	page, err := page.normalize()
+	if err != nil {
+		return postPage{}, err
 	}
 
 	user, err := svc.users.FindByID(id)
 	if err != nil {
-		return nil, ErrNotFound
+		return postPage{}, ErrNotFound
+	}
+
+	entries, err := svc.timelines.Find(user.ID, maxTimelineLength)
+	if err == ErrTimelineNotFound {
+		entries, err = svc.rebuildTimeline(user)
+	}
+	if err != nil {
+		return postPage{}, errors.New("error finding timeline")
+	}
+
+	return svc.findPostPage(user.ID, page, func(p Page) ([]*Post, error) {
+		return svc.findTimelinePosts(user, entries, p)
+	})
+}
+
+// GetTrends returns the tags used the most above their usual rate over the
+// window, which defaults to the last hour
+func (svc *service) GetTrends(window TrendWindow, limit int) ([]Trend, error) {
+	if window == "" {
+		window = TrendHour
+	}
+	period, ok := trendPeriods[window]
+	if !ok {
+		return nil, ErrInvalidTrendWindow
+	}
+
+	if limit <= 0 {
+		limit = defaultTrendLimit
+	}
+	if limit > maxTrendLimit {
+		limit = maxTrendLimit
+	}
+
+	now := time.Now()
+	counts, err := svc.trends.Find(period.bucket, now.Add(-period.window-period.baseline))
+	if err != nil {
+		return nil, errors.New("error finding trends")
 	}
+	return rankTrends(counts, period, now, limit), nil
+}
+
+// findTimelinePosts returns the page of the user's timeline made up of the
+// stored entries and the latest posts of the popular accounts the user
+// follows, which aren't pushed to timelines. No more than a page is read from
+// each popular account, and only the entries making the page are looked up.
+func (svc *service) findTimelinePosts(user *User, entries []TimelineEntry, page Page) ([]*Post, error) {
+	// entries stand in for their posts until the page is cut
+	seen := map[PostID]bool{}
+	posts := make([]*Post, 0, len(entries))
+	for _, e := range entries {
+		if !seen[e.PostID] {
+			seen[e.PostID] = true
+			posts = append(posts, &Post{ID: e.PostID, Author: Author{UserID: e.Author}, Timestamp: e.Timestamp})
+		}
+	}
+
+	popular, err := svc.users.FindPopular(user.Friends, maxFanOutFollowers)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[PostID]*Post{}
+	for _, id := range popular {
+		latest, err := svc.posts.FindLatestPostsForUser(id, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range latest {
+			found[p.ID] = p
+			if !seen[p.ID] {
+				seen[p.ID] = true
+				posts = append(posts, p)
+			}
+		}
+	}
+
+	posts = paginate(posts, page, svc.posts.FindByID)
+
+	var ids []PostID
+	for _, p := range posts {
+		if found[p.ID] == nil {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) > 0 {
+		stored, err := svc.posts.FindByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range stored {
+			found[p.ID] = p
+		}
+	}
+
+	// entries of posts deleted since they were pushed are left out
+	res := make([]*Post, 0, len(posts))
+	for _, p := range posts {
+		if found[p.ID] != nil {
+			res = append(res, found[p.ID])
+		}
+	}
+	return res, nil
+}
+
+// rebuildTimeline builds the timeline of a user who has none stored yet from
+// their latest posts and those of the accounts they follow, and stores it.
+// Until it is stored, posts aren't pushed to it. The timeline is stored even
+// when empty so that it is only rebuilt once.
+func (svc *service) rebuildTimeline(user *User) ([]TimelineEntry, error) {
+	posts, err := svc.posts.FindLatestPostsForUserAndFriends(user, maxTimelineLength)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]TimelineEntry, len(posts))
+	for i, p := range posts {
+		entries[i] = newTimelineEntry(p)
+	}
+	if err := svc.timelines.Store(user.ID, entries); err != nil {
+		log.Printf("error storing the rebuilt timeline of %s: %s", user.ID, err)
+	}
+	return entries, nil
+}
+
+// fanOut pushes a newly published post to the timelines of its author and,
+// unless the author is popular, of their followers. Timelines are kept on a
+// best effort basis: failing to update one doesn't undo the post.
+func (svc *service) fanOut(post *Post) {
+	author, err := svc.users.FindByID(post.Author.UserID)
+	if err != nil {
+		return
+	}
+
+	users := []ID{author.ID}
+	if !author.isPopular() {
+		users = append(users, author.Followers...)
+	}
+	if err := svc.timelines.Push(users, newTimelineEntry(post)); err != nil {
+		log.Printf("error pushing post %s to timelines: %s", post.ID, err)
+	}
+}
+
+// countTags adds the tags of a newly published post to the running counts
+// trends are worked out from. Only public posts count, so that trends don't
+// give away what others were told in private.
+func (svc *service) countTags(post *Post) {
+	if len(post.Tags) < 1 || !post.IsPublic() {
+		return
+	}
+	if err := svc.trends.Increment(tagCounts(post.Tags, post.Timestamp)); err != nil {
+		log.Printf("error counting the tags of post %s: %s", post.ID, err)
+	}
+}
+
+// uncountTags takes the tags of a published post off the running counts, as
+// when the post is deleted or edited
+func (svc *service) uncountTags(post *Post) {
+	if len(post.Tags) < 1 || !post.IsPublic() || post.IsScheduled() {
+		return
+	}
+	if err := svc.trends.Decrement(tagCounts(post.Tags, post.Timestamp)); err != nil {
+		log.Printf("error taking off the tags of post %s: %s", post.ID, err)
+	}
+}
 
-	posts, _ := svc.posts.FindLatestPostsForUserAndFriends(user)
+// backfillTimeline adds the latest posts of the author to the user's timeline.
+// A user without a stored timeline is left to have it rebuilt when it is read.
+func (svc *service) backfillTimeline(user ID, author ID) {
+	posts, err := svc.posts.FindLatestPostsForUser(author, Page{Limit: maxTimelineLength})
+	if err != nil {
+		log.Printf("error finding the posts of %s for the timeline of %s: %s", author, user, err)
+		return
+	}
+
+	entries := make([]TimelineEntry, len(posts))
+	for i, p := range posts {
+		entries[i] = newTimelineEntry(p)
+	}
+	if err := svc.timelines.Backfill(user, entries); err != nil {
+		log.Printf("error adding the posts of %s to the timeline of %s: %s", author, user, err)
+	}
+}
+
+// entities locates the URLs, hashtags and resolved mentions in the body of
+// the post. Mentions of users that couldn't be found are left out.
+func entities(p *Post) []entityResponse {
+	ids := map[string]ID{}
+	for _, m := range p.Mentions {
+		ids[m.Username] = m.UserID
+	}
 
-	return buildPostResponses(posts, user), nil
+	res := []entityResponse{}
+	for _, e := range tokenize(p.Body) {
+		er := entityResponse{
+			Type:      e.Type,
+			Text:      e.Text,
+			ByteStart: e.Start,
+			ByteEnd:   e.End,
+			RuneStart: e.RuneStart,
+			RuneEnd:   e.RuneEnd,
+		}
+
+		switch e.Type {
+		case EntityMention:
+			id, ok := ids[e.Value]
+			if !ok {
+				continue
+			}
+			er.UserID = id
+		case EntityHashtag:
+			er.Tag = e.Value
+		case EntityURL:
+			er.URL = e.Value
+		}
+		res = append(res, er)
+	}
+	return res
 }
 
 // TODO refactor this to use get U1 and U2 separately
@@ -356,6 +2221,23 @@ func (svc *service) getU1U2(id ID, username string) (u1 *User, u2 *User, err err
 	return
 }
 
+// findOwnPost returns the post with the given id if it was written by the user
+func (svc *service) findOwnPost(id ID, postID PostID) (Post, error) {
+	if !IsValidID(string(postID)) {
+		return Post{}, ErrPostNotFound
+	}
+
+	post, err := svc.posts.FindByID(postID)
+	if err != nil {
+		return Post{}, err
+	}
+
+	if post.Author.UserID != id {
+		return Post{}, ErrNotPostAuthor
+	}
+	return post, nil
+}
+
 func (svc *service) findUser(username string) (*User, error) {
 	if username == "" {
 		return nil, ErrInvalidUsername
@@ -393,27 +2275,355 @@ func (svc *service) updateUsername(username *string, user *User) error {
 	return nil
 }
 
-func buildPostResponses(posts []*Post, user *User) []postResponse {
+// findPostPage runs find for one more post than the page asks for, to learn
+// whether there are more posts beyond it, and hydrates the result. The
+// returned cursors are empty when there is no page in that direction.
+func (svc *service) findPostPage(viewer ID, page Page, find func(Page) ([]*Post, error)) (postPage, error) {
+	p := page
+	p.Limit++
+	posts, err := find(p)
+	if err != nil {
+		return postPage{}, errors.New("error finding posts")
+	}
+
+	ids := make([]string, len(posts))
+	for i, p := range posts {
+		ids[i] = string(p.ID)
+	}
+
+	lo, hi, prev, next := cutPage(page, ids)
+	posts = posts[lo:hi]
+	pp := postPage{Prev: prev, Next: next}
+
+	// the cursors are taken before leaving out the posts the viewer can't
+	// see, so a page can come back short without ending the feed
+	pp.Posts, err = svc.buildPostResponses(viewer, svc.filterVisible(viewer, posts))
+	if err != nil {
+		return postPage{}, err
+	}
+	return pp, nil
+}
+
+// cutPage takes the ids found for one more result than the page asks for and
+// returns the range of them that makes up the page, along with the cursors
+// to the pages either side of it. A cursor is empty when there is no page in
+// that direction.
+func cutPage(page Page, ids []string) (lo, hi int, prev, next string) {
+	hi = len(ids)
+	more := hi > page.Limit
+	if more {
+		if page.After != "" {
+			// the extra result is the newest one since results are counted from the cursor
+			lo = 1
+		} else {
+			hi = page.Limit
+		}
+	}
+
+	if lo == hi {
+		return lo, hi, "", ""
+	}
+
+	first, last := ids[lo], ids[hi-1]
+	switch {
+	case page.After != "":
+		next = last
+		if more {
+			prev = first
+		}
+	case page.Before != "":
+		prev = first
+		if more {
+			next = last
+		}
+	case more:
+		next = last
+	}
+	return lo, hi, prev, next
+}
+
+// buildPostResponses hydrates each post with its own author, its counters,
+// the reactions of the viewer and the post it shares, if any. Everything
+// needed is looked up once for the whole page. Posts whose author can no
+// longer be found are attributed to deletedAuthor and reposts of deleted posts
+// are left out.
+func (svc *service) buildPostResponses(viewer ID, posts []*Post) ([]postResponse, error) {
 	var res = []postResponse{}
+	if len(posts) < 1 {
+		return res, nil
+	}
+
+	originals, err := svc.findOriginals(posts)
+	if err != nil {
+		return nil, err
+	}
+
+	all := append([]*Post{}, posts...)
+	for _, o := range originals {
+		all = append(all, o)
+	}
+
+	pc, err := svc.loadPostContext(viewer, all)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, p := range posts {
-		pr := postResponse{
-			ID:        p.ID,
-			Body:      p.Body,
-			Timestamp: p.Timestamp,
-			Author: authorResponse{
-				UserID:   user.ID,
-				Username: user.Username,
-				Avatar:   avatar(user.Email),
-			},
+		pr := pc.response(p)
+
+		if p.RepostOf != "" {
+			o, ok := originals[p.RepostOf]
+			if !ok && p.IsRepost() {
+				continue
+			}
+			if ok {
+				or := pc.response(o)
+				pr.RepostOf = &or
+			}
 		}
 
 		res = append(res, pr)
 	}
 
+	return res, nil
+}
+
+// findOriginals returns the posts shared by reposts and quotes in posts
+func (svc *service) findOriginals(posts []*Post) (map[PostID]*Post, error) {
+	var ids []PostID
+	for _, p := range posts {
+		if p.RepostOf != "" {
+			ids = append(ids, p.RepostOf)
+		}
+	}
+
+	originals := map[PostID]*Post{}
+	if len(ids) < 1 {
+		return originals, nil
+	}
+
+	found, err := svc.posts.FindByIDs(ids)
+	if err != nil {
+		return nil, errors.New("error finding reposted posts")
+	}
+
+	for _, o := range found {
+		originals[o.ID] = o
+	}
+	return originals, nil
+}
+
+// postContext holds the data shared by a page of posts when building responses
+type postContext struct {
+	authors     map[ID]authorResponse
+	replies     map[PostID]int
+	reposts     map[PostID]int
+	reactions   map[PostID]map[ReactionType]int
+	reacted     map[PostID][]ReactionType
+	attachments map[AttachmentID]Attachment
+	votes       map[PostID]map[int]int
+	choices     map[PostID]int
+	now         time.Time
+	// expand is set when the viewer always expands sensitive posts
+	expand bool
+}
+
+func (svc *service) loadPostContext(viewer ID, posts []*Post) (postContext, error) {
+	var pc postContext
+
+	authors, err := svc.findAuthors(posts)
+	if err != nil {
+		return pc, err
+	}
+	pc.authors = authors
+
+	ids := make([]PostID, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	if pc.replies, err = svc.posts.CountReplies(ids); err != nil {
+		return pc, errors.New("error counting replies")
+	}
+
+	if pc.reposts, err = svc.posts.CountReposts(ids); err != nil {
+		return pc, errors.New("error counting reposts")
+	}
+
+	if pc.reactions, err = svc.reactions.CountByPosts(ids); err != nil {
+		return pc, errors.New("error counting reactions")
+	}
+
+	for _, p := range posts {
+		if p.IsCollapsible() && viewer != "" {
+			if u, err := svc.users.FindByID(viewer); err == nil {
+				pc.expand = u.ExpandSensitive
+			}
+			break
+		}
+	}
+
+	pc.reacted = map[PostID][]ReactionType{}
+	if viewer != "" {
+		if pc.reacted, err = svc.reactions.FindByUser(viewer, ids); err != nil {
+			return pc, errors.New("error finding viewer reactions")
+		}
+	}
+
+	var attachmentIDs []AttachmentID
+	for _, p := range posts {
+		attachmentIDs = append(attachmentIDs, p.Attachments...)
+	}
+
+	pc.attachments = map[AttachmentID]Attachment{}
+	if len(attachmentIDs) > 0 {
+		attachments, err := svc.attachments.FindByIDs(attachmentIDs)
+		if err != nil {
+			return pc, errors.New("error finding attachments")
+		}
+		for _, a := range attachments {
+			pc.attachments[a.ID] = a
+		}
+	}
+
+	var polls []PostID
+	for _, p := range posts {
+		if p.Poll != nil {
+			polls = append(polls, p.ID)
+		}
+	}
+
+	pc.now = time.Now()
+	pc.votes, pc.choices = map[PostID]map[int]int{}, map[PostID]int{}
+	if len(polls) > 0 {
+		if pc.votes, err = svc.votes.CountByPosts(polls); err != nil {
+			return pc, errors.New("error counting votes")
+		}
+		if viewer != "" {
+			if pc.choices, err = svc.votes.FindByUser(viewer, polls); err != nil {
+				return pc, errors.New("error finding viewer votes")
+			}
+		}
+	}
+
+	return pc, nil
+}
+
+func (pc postContext) response(p *Post) postResponse {
+	author, ok := pc.authors[p.Author.UserID]
+	if !ok {
+		author = deletedAuthor(p.Author.UserID)
+	}
+
+	kind := p.Kind
+	if kind == "" {
+		kind = KindPost
+	}
+
+	visibility := p.Visibility
+	if p.IsPublic() {
+		visibility = VisibilityPublic
+	}
+
+	pr := postResponse{
+		ID:          p.ID,
+		Kind:        kind,
+		Visibility:  visibility,
+		Body:        p.Body,
+		Timestamp:   p.Timestamp,
+		Author:      author,
+		InReplyTo:   p.InReplyTo,
+		Tags:        p.Tags,
+		Entities:    entities(p),
+		ReplyCount:  pc.replies[p.ID],
+		RepostCount: pc.reposts[p.ID],
+		Reactions:   pc.reactions[p.ID],
+		Reacted:     pc.reacted[p.ID],
+
+		ContentWarning: p.ContentWarning,
+		Sensitive:      p.Sensitive,
+		Collapsed:      p.IsCollapsible() && !pc.expand,
+	}
+
+	if pr.Reactions == nil {
+		pr.Reactions = map[ReactionType]int{}
+	}
+
+	for _, id := range p.Attachments {
+		if a, ok := pc.attachments[id]; ok {
+			pr.Attachments = append(pr.Attachments, newAttachmentResponse(a))
+		}
+	}
+
+	if !p.EditedAt.IsZero() {
+		editedAt := p.EditedAt
+		pr.EditedAt = &editedAt
+	}
+
+	if p.IsScheduled() {
+		publishAt := p.PublishAt
+		pr.PublishAt = &publishAt
+	}
+
+	if p.Poll != nil {
+		pr.Poll = pc.poll(p)
+	}
+
+	return pr
+}
+
+func (pc postContext) poll(p *Post) *pollResponse {
+	res := &pollResponse{ExpiresAt: p.Poll.ExpiresAt, Closed: p.Poll.IsClosed(pc.now)}
+
+	choice, voted := pc.choices[p.ID]
+	if voted {
+		res.Choice = &choice
+	}
+
+	total := 0
+	for i, o := range p.Poll.Options {
+		option := pollOptionResponse{Text: o}
+		if voted || res.Closed {
+			votes := pc.votes[p.ID][i]
+			option.Votes = &votes
+			total += votes
+		}
+		res.Options = append(res.Options, option)
+	}
+
+	if voted || res.Closed {
+		res.TotalVotes = &total
+	}
 	return res
 }
 
+func (svc *service) findAuthors(posts []*Post) (map[ID]authorResponse, error) {
+	var ids []ID
+	seen := map[ID]bool{}
+	for _, p := range posts {
+		if !seen[p.Author.UserID] {
+			seen[p.Author.UserID] = true
+			ids = append(ids, p.Author.UserID)
+		}
+	}
+
+	users, err := svc.users.FindByIDs(ids)
+	if err != nil {
+		return nil, errors.New("error finding post authors")
+	}
+
+	authors := map[ID]authorResponse{}
+	for _, u := range users {
+		authors[u.ID] = authorResponse{UserID: u.ID, Username: u.Username, Avatar: avatar(u.Email)}
+	}
+	return authors, nil
+}
+
+// deletedAuthor is the author shown for posts whose user no longer exists.
+func deletedAuthor(id ID) authorResponse {
+	return authorResponse{UserID: id, Username: deletedUsername, Avatar: avatar("")}
+}
+
 func avatar(email string) string {
 	digest := fmt.Sprintf("%x", md5.Sum([]byte(email)))
 	return fmt.Sprintf("https://www.gravatar.com/avatar/%s?d=identicon", digest)
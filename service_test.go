@@ -1,6 +1,9 @@
 package blog
 
 import (
+	"image/png"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,89 +21,1447 @@ type ServiceTestSuite struct {
 
 func (ts *ServiceTestSuite) TearDownTest() {
 	ts.svc.posts = NewPostRepository()
+	ts.svc.reactions = NewReactionRepository()
+	ts.svc.attachments = NewAttachmentRepository()
+	ts.svc.blobs = NewBlobStore()
+	ts.svc.drafts = NewDraftRepository()
+	ts.svc.votes = NewVoteRepository()
+	ts.svc.bookmarks = NewBookmarkRepository()
+	ts.svc.timelines = NewTimelineRepository()
+	ts.svc.trends = NewTrendRepository()
 }
 
 func (ts *ServiceTestSuite) SetupSuite() {
-	ts.svc = service{users: NewUserRepository(), posts: NewPostRepository()}
+	ts.svc = service{
+		users:       NewUserRepository(),
+		posts:       NewPostRepository(),
+		reactions:   NewReactionRepository(),
+		attachments: NewAttachmentRepository(),
+		blobs:       NewBlobStore(),
+		drafts:      NewDraftRepository(),
+		votes:       NewVoteRepository(),
+		bookmarks:   NewBookmarkRepository(),
+		timelines:   NewTimelineRepository(),
+		trends:      NewTrendRepository(),
+	}
 	ts.userID = nextID()
 	ts.username = "username"
 	ts.email = "a@b.con"
 	ts.svc.CreateProfile(string(ts.userID), ts.username, ts.email)
 
-	u, _ := ts.svc.users.FindByID(ts.userID)
-	ts.user = u
+	u, _ := ts.svc.users.FindByID(ts.userID)
+	ts.user = u
+}
+
+func (ts *ServiceTestSuite) TestService_CreateProfile() {
+	id := nextID()
+	now := time.Now().UTC()
+	tests := []struct {
+		username, email string
+		wantCreatedAt   bool
+	}{
+		{username: ts.username},
+		{username: "new", email: ts.email},
+		{username: "new", email: "n@e.co", wantCreatedAt: true},
+	}
+
+	for _, tt := range tests {
+		ts.svc.CreateProfile(string(id), tt.username, tt.email)
+
+		user, _ := ts.svc.users.FindByID(id)
+
+		if user != nil {
+			assert.Equal(ts.T(), tt.wantCreatedAt, user.CreatedAt.After(now))
+		}
+	}
+}
+
+func (ts *ServiceTestSuite) TestService_CreatePost() {
+	tests := []struct {
+		userID              ID
+		body                string
+		wantErr             error
+		wantValidID, wantTS bool
+		wantUsername        string
+	}{
+		{wantErr: ErrInvalidID},
+		{userID: "user", wantErr: ErrInvalidID},
+		{userID: nextID(), body: "post", wantErr: ErrNotFound},
+		{userID: ts.userID, wantErr: ErrEmptyBody},
+		{userID: ts.userID, body: "post", wantValidID: true, wantErr: nil, wantTS: true},
+	}
+	for _, tt := range tests {
+		now := time.Now()
+		id, err := ts.svc.CreatePost(tt.userID, createPostRequest{Body: tt.body})
+		assert.Equal(ts.T(), tt.wantValidID, IsValidID(string(id)))
+		assert.Equal(ts.T(), tt.wantErr, err)
+
+		if tt.wantValidID {
+			post, err := ts.svc.posts.FindByID(id)
+			assert.Nil(ts.T(), err)
+			assert.Equal(ts.T(), tt.body, post.Body)
+			assert.Equal(ts.T(), tt.userID, post.Author.UserID)
+			assert.Equal(ts.T(), tt.wantTS, post.Timestamp.After(now))
+		}
+	}
+}
+
+func (ts *ServiceTestSuite) TestService_CreateReply() {
+	parent, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "parent"})
+
+	tests := []struct {
+		inReplyTo        PostID
+		wantErr          error
+		wantConversation PostID
+	}{
+		{inReplyTo: "invalid", wantErr: ErrNoParentPost},
+		{inReplyTo: PostID(nextID()), wantErr: ErrNoParentPost},
+		{inReplyTo: parent, wantConversation: parent},
+	}
+
+	for _, tt := range tests {
+		id, err := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "reply", InReplyTo: tt.inReplyTo})
+		assert.Equal(ts.T(), tt.wantErr, err)
+
+		if err == nil {
+			p, _ := ts.svc.posts.FindByID(id)
+			assert.Equal(ts.T(), tt.inReplyTo, p.InReplyTo)
+			assert.Equal(ts.T(), tt.wantConversation, p.ConversationID)
+		}
+	}
+
+	// replies to replies stay in the conversation of the root post
+	reply, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "reply", InReplyTo: parent})
+	id, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "nested", InReplyTo: reply})
+	p, _ := ts.svc.posts.FindByID(id)
+	assert.Equal(ts.T(), parent, p.ConversationID)
+
+	pr, _ := ts.svc.GetPost("", parent)
+	assert.Equal(ts.T(), 2, pr.ReplyCount)
+}
+
+func (ts *ServiceTestSuite) TestService_GetThread() {
+	root, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "root"})
+	a, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "a", InReplyTo: root})
+	b, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "b", InReplyTo: a})
+	c, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "c", InReplyTo: root})
+	d, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "d", InReplyTo: b})
+
+	ids := func(prs []postResponse) []PostID {
+		res := []PostID{}
+		for _, p := range prs {
+			res = append(res, p.ID)
+		}
+		return res
+	}
+
+	tests := []struct {
+		id            PostID
+		wantErr       error
+		wantAncestors []PostID
+		wantReplies   []PostID
+	}{
+		{id: "invalid", wantErr: ErrPostNotFound},
+		{id: PostID(nextID()), wantErr: ErrPostNotFound},
+		{id: root, wantAncestors: []PostID{}, wantReplies: []PostID{a, c}},
+		{id: b, wantAncestors: []PostID{root, a}, wantReplies: []PostID{d}},
+		{id: d, wantAncestors: []PostID{root, a, b}, wantReplies: []PostID{}},
+	}
+
+	for _, tt := range tests {
+		th, err := ts.svc.GetThread("", tt.id)
+		assert.Equal(ts.T(), tt.wantErr, err)
+
+		if err == nil {
+			var replies []postResponse
+			for _, r := range th.Post.Replies {
+				replies = append(replies, r.postResponse)
+			}
+
+			assert.Equal(ts.T(), tt.id, th.Post.ID)
+			assert.Equal(ts.T(), tt.wantAncestors, ids(th.Ancestors))
+			assert.Equal(ts.T(), tt.wantReplies, ids(replies))
+		}
+	}
+
+	th, _ := ts.svc.GetThread("", root)
+	assert.Equal(ts.T(), 2, th.Post.ReplyCount)
+	assert.Equal(ts.T(), d, th.Post.Replies[0].Replies[0].Replies[0].ID)
+}
+
+func (ts *ServiceTestSuite) TestService_GetUserPosts() {
+	_, _ = ts.svc.CreatePost(ts.userID, createPostRequest{Body: "body"})
+
+	tests := []struct {
+		username     string
+		wantErr      error
+		wantPostsLen int
+	}{
+		{wantErr: ErrInvalidUsername},
+		{username: "void", wantErr: ErrNotFound},
+		{username: ts.username, wantErr: nil, wantPostsLen: 1},
+	}
+
+	for _, tt := range tests {
+		posts, err := ts.svc.GetUserPosts("", tt.username, Page{})
+		assert.Equal(ts.T(), tt.wantErr, err)
+		assert.Equal(ts.T(), tt.wantPostsLen, len(posts.Posts))
+	}
+}
+
+func (ts *ServiceTestSuite) TestService_GetUserPostsPages() {
+	var ids []PostID
+	for i := 0; i < 5; i++ {
+		id, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "body"})
+		ids = append(ids, id)
+	}
+	s := func(id PostID) string { return string(id) }
+
+	tests := []struct {
+		page               Page
+		wantErr            error
+		wantIDs            []PostID
+		wantNext, wantPrev string
+	}{
+		{page: Page{Before: "invalid"}, wantErr: ErrInvalidCursor},
+		{page: Page{Before: s(ids[1]), After: s(ids[0])}, wantErr: ErrInvalidCursor},
+		{page: Page{}, wantIDs: []PostID{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+		{page: Page{Limit: 2}, wantIDs: []PostID{ids[4], ids[3]}, wantNext: s(ids[3])},
+		{page: Page{Limit: 2, Before: s(ids[3])}, wantIDs: []PostID{ids[2], ids[1]}, wantNext: s(ids[1]), wantPrev: s(ids[2])},
+		{page: Page{Limit: 2, Before: s(ids[1])}, wantIDs: []PostID{ids[0]}, wantPrev: s(ids[0])},
+		{page: Page{Limit: 2, After: s(ids[0])}, wantIDs: []PostID{ids[2], ids[1]}, wantNext: s(ids[1]), wantPrev: s(ids[2])},
+		{page: Page{Limit: 2, After: s(ids[2])}, wantIDs: []PostID{ids[4], ids[3]}, wantNext: s(ids[3])},
+		{page: Page{Limit: 2, After: s(ids[4])}, wantIDs: []PostID{}},
+	}
+
+	for _, tt := range tests {
+		pp, err := ts.svc.GetUserPosts("", ts.username, tt.page)
+		assert.Equal(ts.T(), tt.wantErr, err)
+
+		if err == nil {
+			got := []PostID{}
+			for _, p := range pp.Posts {
+				got = append(got, p.ID)
+			}
+			assert.Equal(ts.T(), tt.wantIDs, got)
+			assert.Equal(ts.T(), tt.wantNext, pp.Next)
+			assert.Equal(ts.T(), tt.wantPrev, pp.Prev)
+		}
+	}
+}
+
+func (ts *ServiceTestSuite) TestService_GetTagPosts() {
+	p1, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "#GoLang meetup"})
+	_, _ = ts.svc.CreatePost(ts.userID, createPostRequest{Body: "#rust meetup"})
+	p3, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "more #golang and #go"})
+	p4, _ := ts.svc.Repost(ts.userID, p1, "quoting #golang")
+	_ = ts.svc.EditPost(ts.userID, p3, "no longer tagged")
+
+	tests := []struct {
+		tag     string
+		page    Page
+		wantErr error
+		wantIDs []PostID
+	}{
+		{tag: "", wantErr: ErrInvalidTag},
+		{tag: "#", wantErr: ErrInvalidTag},
+		{tag: "123", wantErr: ErrInvalidTag},
+		{tag: "go lang", wantErr: ErrInvalidTag},
+		{tag: "golang", page: Page{Before: "invalid"}, wantErr: ErrInvalidCursor},
+		{tag: "unused", wantIDs: []PostID{}},
+		{tag: "golang", wantIDs: []PostID{p4, p1}},
+		{tag: "#GOLANG", wantIDs: []PostID{p4, p1}},
+		{tag: "golang", page: Page{Limit: 1, Before: string(p4)}, wantIDs: []PostID{p1}},
+		{tag: "go", wantIDs: []PostID{}},
+	}
+
+	for _, tt := range tests {
+		pp, err := ts.svc.GetTagPosts("", tt.tag, tt.page)
+		assert.Equal(ts.T(), tt.wantErr, err)
+
+		if err == nil {
+			got := []PostID{}
+			for _, p := range pp.Posts {
+				got = append(got, p.ID)
+			}
+			assert.Equal(ts.T(), tt.wantIDs, got)
+		}
+	}
+
+	p, _ := ts.svc.GetPost("", p1)
+	assert.Equal(ts.T(), []string{"golang"}, p.Tags)
+}
+
+func (ts *ServiceTestSuite) TestService_Mentions() {
+	u1 := DuplicateUser(ts.svc.users, *ts.user, "mentioned")
+	body := "hey @mentioned, have you met @nobody? cc @" + ts.username
+
+	p1, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: body})
+	_, _ = ts.svc.CreatePost(ts.userID, createPostRequest{Body: "no mentions"})
+	p3, _ := ts.svc.Repost(u1.ID, p1, "thanks @"+ts.username)
+	p4, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "@mentioned again"})
+	_ = ts.svc.EditPost(ts.userID, p4, "not anymore")
+
+	p, _ := ts.svc.GetPost("", p1)
+	end := 42 + len(ts.username)
+	assert.Equal(ts.T(), []entityResponse{
+		{Type: EntityMention, Text: "@mentioned", ByteStart: 4, ByteEnd: 14, RuneStart: 4, RuneEnd: 14, UserID: u1.ID},
+		{Type: EntityMention, Text: "@" + ts.username, ByteStart: 41, ByteEnd: end, RuneStart: 41, RuneEnd: end, UserID: ts.userID},
+	}, p.Entities)
+
+	tests := []struct {
+		username string
+		page     Page
+		wantErr  error
+		wantIDs  []PostID
+	}{
+		{wantErr: ErrInvalidUsername},
+		{username: "nobody", wantErr: ErrNotFound},
+		{username: "mentioned", page: Page{After: "invalid"}, wantErr: ErrInvalidCursor},
+		{username: "mentioned", wantIDs: []PostID{p1}},
+		{username: ts.username, wantIDs: []PostID{p3, p1}},
+		{username: ts.username, page: Page{Limit: 1}, wantIDs: []PostID{p3}},
+	}
+
+	for _, tt := range tests {
+		pp, err := ts.svc.GetUserMentions("", tt.username, tt.page)
+		assert.Equal(ts.T(), tt.wantErr, err)
+
+		if err == nil {
+			got := []PostID{}
+			for _, p := range pp.Posts {
+				got = append(got, p.ID)
+			}
+			assert.Equal(ts.T(), tt.wantIDs, got)
+		}
+	}
+
+	// mentions keep pointing at the user after a rename
+	renamed := "renamed"
+	_ = ts.svc.EditProfile(u1.ID, editProfileRequest{Username: &renamed})
+	pp, _ := ts.svc.GetUserMentions("", renamed, Page{})
+	assert.Equal(ts.T(), 1, len(pp.Posts))
+	assert.Equal(ts.T(), "@mentioned", pp.Posts[0].Entities[0].Text)
+
+	// clean up
+	_ = ts.svc.users.Delete(u1.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_GetPost() {
+	id, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "single"})
+
+	tests := []struct {
+		id       PostID
+		wantErr  error
+		wantBody string
+		wantUN   string
+	}{
+		{wantErr: ErrPostNotFound},
+		{id: "invalid", wantErr: ErrPostNotFound},
+		{id: PostID(nextID()), wantErr: ErrPostNotFound},
+		{id: id, wantBody: "single", wantUN: ts.username},
+	}
+
+	for _, tt := range tests {
+		p, err := ts.svc.GetPost("", tt.id)
+
+		assert.Equal(ts.T(), tt.wantErr, err)
+		assert.Equal(ts.T(), tt.wantBody, p.Body)
+		assert.Equal(ts.T(), tt.wantUN, p.Author.Username)
+	}
+}
+
+func (ts *ServiceTestSuite) TestService_EditPost() {
+	other := DuplicateUser(ts.svc.users, *ts.user, "editor")
+	id, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "tpyo"})
+
+	tests := []struct {
+		userID       ID
+		postID       PostID
+		body         string
+		wantErr      error
+		wantBody     string
+		wantRevsLen  int
+		wantEditedAt bool
+	}{
+		{postID: id, body: "typo", wantErr: ErrInvalidID, wantBody: "tpyo"},
+		{userID: ts.userID, postID: "invalid", body: "typo", wantErr: ErrPostNotFound, wantBody: "tpyo"},
+		{userID: other.ID, postID: id, body: "typo", wantErr: ErrNotPostAuthor, wantBody: "tpyo"},
+		{userID: ts.userID, postID: id, wantErr: ErrEmptyBody, wantBody: "tpyo"},
+		{userID: ts.userID, postID: id, body: "typo", wantBody: "typo", wantRevsLen: 1, wantEditedAt: true},
+		{userID: ts.userID, postID: id, body: "typo!", wantBody: "typo!", wantRevsLen: 2, wantEditedAt: true},
+	}
+
+	for _, tt := range tests {
+		err := ts.svc.EditPost(tt.userID, tt.postID, tt.body)
+		assert.Equal(ts.T(), tt.wantErr, err)
+
+		p, _ := ts.svc.GetPost("", id)
+		revs, err := ts.svc.GetPostRevisions("", id)
+		assert.Nil(ts.T(), err)
+		assert.Equal(ts.T(), tt.wantBody, p.Body)
+		assert.Equal(ts.T(), tt.wantEditedAt, p.EditedAt != nil)
+		assert.Equal(ts.T(), tt.wantRevsLen, len(revs))
+	}
+
+	revs, _ := ts.svc.GetPostRevisions("", id)
+	assert.Equal(ts.T(), "tpyo", revs[0].Body)
+	assert.Equal(ts.T(), "typo", revs[1].Body)
+
+	_, err := ts.svc.GetPostRevisions("", PostID(nextID()))
+	assert.Equal(ts.T(), ErrPostNotFound, err)
+
+	// clean up
+	_ = ts.svc.users.Delete(other.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_DeletePost() {
+	other := DuplicateUser(ts.svc.users, *ts.user, "deleter")
+	other.Follow(ts.user)
+
+	id, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "to delete"})
+
+	tests := []struct {
+		userID  ID
+		postID  PostID
+		wantErr error
+	}{
+		{postID: id, wantErr: ErrInvalidID},
+		{userID: ts.userID, postID: "invalid", wantErr: ErrPostNotFound},
+		{userID: ts.userID, postID: PostID(nextID()), wantErr: ErrPostNotFound},
+		{userID: other.ID, postID: id, wantErr: ErrNotPostAuthor},
+		{userID: ts.userID, postID: id},
+		{userID: ts.userID, postID: id, wantErr: ErrPostNotFound},
+	}
+
+	for _, tt := range tests {
+		err := ts.svc.DeletePost(tt.userID, tt.postID)
+		assert.Equal(ts.T(), tt.wantErr, err)
+	}
+
+	p, _ := ts.svc.GetProfile("", ts.username)
+	tl, _ := ts.svc.GetTimeline(other.ID, Page{})
+	assert.Equal(ts.T(), 0, len(p.Posts))
+	assert.Equal(ts.T(), 0, len(tl.Posts))
+
+	// clean up
+	other.Unfollow(ts.user)
+	_ = ts.svc.users.Delete(other.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_Repost() {
+	u1 := DuplicateUser(ts.svc.users, *ts.user, "reposter")
+	u2 := DuplicateUser(ts.svc.users, *ts.user, "original")
+	u3 := DuplicateUser(ts.svc.users, *ts.user, "reader")
+	u3.Follow(u1)
+
+	original, _ := ts.svc.CreatePost(u2.ID, createPostRequest{Body: "original"})
+
+	tests := []struct {
+		userID   ID
+		postID   PostID
+		body     string
+		wantErr  error
+		wantKind PostKind
+	}{
+		{postID: original, wantErr: ErrInvalidID},
+		{userID: nextID(), postID: original, wantErr: ErrNotFound},
+		{userID: u1.ID, postID: "invalid", wantErr: ErrPostNotFound},
+		{userID: u1.ID, postID: PostID(nextID()), wantErr: ErrPostNotFound},
+		{userID: u2.ID, postID: original, wantErr: ErrCantRepostOwn},
+		{userID: u1.ID, postID: original, wantKind: KindRepost},
+		{userID: u1.ID, postID: original, wantErr: ErrAlreadyReposted},
+		{userID: u1.ID, postID: original, body: "so true", wantKind: KindQuote},
+	}
+
+	for _, tt := range tests {
+		id, err := ts.svc.Repost(tt.userID, tt.postID, tt.body)
+		assert.Equal(ts.T(), tt.wantErr, err)
+
+		if err == nil {
+			p, _ := ts.svc.GetPost("", id)
+			assert.Equal(ts.T(), tt.wantKind, p.Kind)
+			assert.Equal(ts.T(), tt.body, p.Body)
+			assert.Equal(ts.T(), u1.Username, p.Author.Username)
+			assert.Equal(ts.T(), original, p.RepostOf.ID)
+			assert.Equal(ts.T(), u2.Username, p.RepostOf.Author.Username)
+		}
+	}
+
+	tl, _ := ts.svc.GetTimeline(u3.ID, Page{})
+	assert.Equal(ts.T(), 2, len(tl.Posts))
+	assert.Equal(ts.T(), KindQuote, tl.Posts[0].Kind)
+	assert.Equal(ts.T(), KindRepost, tl.Posts[1].Kind)
+	assert.Equal(ts.T(), u2.ID, tl.Posts[1].RepostOf.Author.UserID)
+	assert.Equal(ts.T(), 2, tl.Posts[1].RepostOf.RepostCount)
+
+	// reposting a repost shares the original post
+	_, err := ts.svc.Repost(u3.ID, tl.Posts[1].ID, "")
+	assert.Nil(ts.T(), err)
+	p, _ := ts.svc.GetPost("", original)
+	assert.Equal(ts.T(), 3, p.RepostCount)
+
+	removeTests := []struct {
+		userID  ID
+		postID  PostID
+		wantErr error
+	}{
+		{postID: original, wantErr: ErrInvalidID},
+		{userID: u1.ID, postID: PostID(nextID()), wantErr: ErrPostNotFound},
+		{userID: u2.ID, postID: original, wantErr: ErrNotReposted},
+		{userID: u1.ID, postID: original},
+		{userID: u1.ID, postID: original, wantErr: ErrNotReposted},
+	}
+
+	for _, tt := range removeTests {
+		err := ts.svc.RemoveRepost(tt.userID, tt.postID)
+		assert.Equal(ts.T(), tt.wantErr, err)
+	}
+
+	// reposts of deleted posts are no longer shown
+	_ = ts.svc.DeletePost(u2.ID, original)
+	tl, _ = ts.svc.GetTimeline(u3.ID, Page{})
+	assert.Equal(ts.T(), 1, len(tl.Posts))
+	assert.Equal(ts.T(), KindQuote, tl.Posts[0].Kind)
+	assert.Nil(ts.T(), tl.Posts[0].RepostOf)
+
+	// clean up
+	_ = ts.svc.users.Delete(u1.ID)
+	_ = ts.svc.users.Delete(u2.ID)
+	_ = ts.svc.users.Delete(u3.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_Reactions() {
+	u1 := DuplicateUser(ts.svc.users, *ts.user, "reactor1")
+	u2 := DuplicateUser(ts.svc.users, *ts.user, "reactor2")
+	postID, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "react to me"})
+
+	tests := []struct {
+		userID  ID
+		postID  PostID
+		t       ReactionType
+		wantErr error
+	}{
+		{postID: postID, t: ReactionLike, wantErr: ErrInvalidID},
+		{userID: u1.ID, postID: "invalid", t: ReactionLike, wantErr: ErrPostNotFound},
+		{userID: u1.ID, postID: PostID(nextID()), t: ReactionLike, wantErr: ErrPostNotFound},
+		{userID: u1.ID, postID: postID, t: "meh", wantErr: ErrInvalidReaction},
+		{userID: u1.ID, postID: postID, t: ReactionLike},
+		{userID: u1.ID, postID: postID, t: ReactionLike, wantErr: ErrAlreadyReacted},
+		{userID: u1.ID, postID: postID, t: ReactionLove},
+		{userID: u2.ID, postID: postID, t: ReactionLike},
+	}
+
+	for _, tt := range tests {
+		err := ts.svc.React(tt.userID, tt.postID, tt.t)
+		assert.Equal(ts.T(), tt.wantErr, err)
+	}
+
+	p, _ := ts.svc.GetPost(u1.ID, postID)
+	assert.Equal(ts.T(), map[ReactionType]int{ReactionLike: 2, ReactionLove: 1}, p.Reactions)
+	assert.ElementsMatch(ts.T(), []ReactionType{ReactionLike, ReactionLove}, p.Reacted)
+
+	p, _ = ts.svc.GetPost("", postID)
+	assert.Equal(ts.T(), 0, len(p.Reacted))
+
+	reactors, err := ts.svc.GetReactions("", postID)
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), 3, len(reactors))
+	assert.Equal(ts.T(), u2.Username, reactors[0].User.Username)
+
+	unreactTests := []struct {
+		userID  ID
+		t       ReactionType
+		wantErr error
+	}{
+		{t: ReactionLike, wantErr: ErrInvalidID},
+		{userID: u1.ID, t: "meh", wantErr: ErrInvalidReaction},
+		{userID: u1.ID, t: ReactionLaugh, wantErr: ErrNotReacted},
+		{userID: u1.ID, t: ReactionLike},
+		{userID: u1.ID, t: ReactionLike, wantErr: ErrNotReacted},
+	}
+
+	for _, tt := range unreactTests {
+		err := ts.svc.Unreact(tt.userID, postID, tt.t)
+		assert.Equal(ts.T(), tt.wantErr, err)
+	}
+
+	p, _ = ts.svc.GetPost(u1.ID, postID)
+	assert.Equal(ts.T(), map[ReactionType]int{ReactionLike: 1, ReactionLove: 1}, p.Reactions)
+	assert.Equal(ts.T(), []ReactionType{ReactionLove}, p.Reacted)
+
+	_ = ts.svc.DeletePost(ts.userID, postID)
+	reactions, _ := ts.svc.reactions.FindByPost(postID)
+	assert.Equal(ts.T(), 0, len(reactions))
+
+	// clean up
+	_ = ts.svc.users.Delete(u1.ID)
+	_ = ts.svc.users.Delete(u2.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_Media() {
+	other := DuplicateUser(ts.svc.users, *ts.user, "mediaUser")
+	data := encodePNG(newTestImage(400, 200))
+
+	uploadTests := []struct {
+		userID  ID
+		data    []byte
+		altText string
+		wantErr error
+	}{
+		{data: data, wantErr: ErrInvalidID},
+		{userID: nextID(), data: data, wantErr: ErrNotFound},
+		{userID: ts.userID, data: []byte("text"), wantErr: ErrUnsupportedMedia},
+		{userID: ts.userID, data: data, altText: strings.Repeat("a", maxAltTextLength+1), wantErr: ErrAltTextTooLong},
+	}
+
+	for _, tt := range uploadTests {
+		_, err := ts.svc.UploadMedia(tt.userID, tt.data, tt.altText)
+		assert.Equal(ts.T(), tt.wantErr, err)
+	}
+
+	var ids []AttachmentID
+	for i := 0; i < maxAttachments+1; i++ {
+		a, err := ts.svc.UploadMedia(ts.userID, data, " a red square ")
+		assert.Nil(ts.T(), err)
+		ids = append(ids, a.ID)
+	}
+	otherMedia, _ := ts.svc.UploadMedia(other.ID, data, "")
+
+	a, _ := ts.svc.attachments.FindByIDs(ids[:1])
+	assert.Equal(ts.T(), "a red square", a[0].AltText)
+	r, err := ts.svc.GetMedia(ts.userID, a[0].ThumbnailKey)
+	assert.Nil(ts.T(), err)
+	assert.False(ts.T(), r.Public)
+	thumb, _ := png.DecodeConfig(r)
+	assert.Equal(ts.T(), thumbnailSize, thumb.Width)
+
+	// uploads are private to their owner until they are posted
+	_, err = ts.svc.GetMedia("", a[0].Key)
+	assert.Equal(ts.T(), ErrBlobNotFound, err)
+	_, err = ts.svc.GetMedia(other.ID, a[0].Key)
+	assert.Equal(ts.T(), ErrBlobNotFound, err)
+	_, err = ts.svc.GetMedia(ts.userID, string(a[0].ID)+".jpg")
+	assert.Equal(ts.T(), ErrBlobNotFound, err)
+
+	postTests := []struct {
+		ids     []AttachmentID
+		wantErr error
+	}{
+		{ids: ids, wantErr: ErrTooManyAttachments},
+		{ids: []AttachmentID{ids[0], AttachmentID(nextID())}, wantErr: ErrInvalidAttachment},
+		{ids: []AttachmentID{ids[0], otherMedia.ID}, wantErr: ErrInvalidAttachment},
+		{ids: []AttachmentID{ids[1], ids[0], ids[1]}},
+		{ids: []AttachmentID{ids[0]}, wantErr: ErrInvalidAttachment},
+	}
+
+	var postID PostID
+	for _, tt := range postTests {
+		id, err := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "pictures", Attachments: tt.ids})
+		assert.Equal(ts.T(), tt.wantErr, err)
+		if err == nil {
+			postID = id
+		}
+	}
+
+	p, _ := ts.svc.GetPost("", postID)
+	assert.Equal(ts.T(), 2, len(p.Attachments))
+	assert.Equal(ts.T(), ids[1], p.Attachments[0].ID)
+	assert.Equal(ts.T(), "/v1/media/"+string(ids[1])+".png", p.Attachments[0].URL)
+	assert.Equal(ts.T(), "/v1/media/"+string(ids[1])+"_thumb.png", p.Attachments[0].ThumbnailURL)
+	assert.Equal(ts.T(), 400, p.Attachments[0].Width)
+	assert.Equal(ts.T(), "a red square", p.Attachments[0].AltText)
+
+	// only orphans older than the ttl are collected
+	n, err := ts.svc.DeleteOrphanedMedia(time.Hour)
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), 0, n)
+
+	n, err = ts.svc.DeleteOrphanedMedia(-time.Second)
+	assert.Nil(ts.T(), err)
+	// all but the two attached uploads
+	assert.Equal(ts.T(), len(ids)-2+1, n)
+
+	_, err = ts.svc.GetMedia(ts.userID, string(ids[2])+".png")
+	assert.Equal(ts.T(), ErrBlobNotFound, err)
+	r, err = ts.svc.GetMedia("", string(ids[0])+".png")
+	assert.Nil(ts.T(), err)
+	assert.True(ts.T(), r.Public)
+
+	// the media of posts for followers is only seen by the followers
+	private, _ := ts.svc.UploadMedia(ts.userID, data, "")
+	_, err = ts.svc.CreatePost(ts.userID, createPostRequest{
+		Body: "friends only", Visibility: VisibilityFollowers, Attachments: []AttachmentID{private.ID},
+	})
+	assert.Nil(ts.T(), err)
+	_, err = ts.svc.GetMedia(other.ID, string(private.ID)+".png")
+	assert.Equal(ts.T(), ErrBlobNotFound, err)
+	r, err = ts.svc.GetMedia(ts.userID, string(private.ID)+".png")
+	assert.Nil(ts.T(), err)
+	assert.False(ts.T(), r.Public)
+
+	_ = ts.svc.DeletePost(ts.userID, postID)
+	attachments, _ := ts.svc.attachments.FindByIDs(ids)
+	assert.Equal(ts.T(), 0, len(attachments))
+	_, err = ts.svc.GetMedia(ts.userID, string(ids[0])+"_thumb.png")
+	assert.Equal(ts.T(), ErrBlobNotFound, err)
+
+	// clean up
+	_ = ts.svc.users.Delete(other.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_ScheduledPosts() {
+	other := DuplicateUser(ts.svc.users, *ts.user, "scheduleUser")
+	publishAt := time.Now().Add(time.Hour).UTC()
+
+	_, err := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "too late", PublishAt: time.Now().Add(-time.Minute)})
+	assert.Equal(ts.T(), ErrInvalidPublishTime, err)
+
+	media, _ := ts.svc.UploadMedia(ts.userID, encodePNG(newTestImage(10, 10)), "")
+	postID, err := ts.svc.CreatePost(ts.userID, createPostRequest{
+		Body: "later #soon", PublishAt: publishAt, Attachments: []AttachmentID{media.ID},
+	})
+	assert.Nil(ts.T(), err)
+
+	// nobody but the author sees a scheduled post
+	pp, _ := ts.svc.GetUserPosts("", ts.username, Page{})
+	assert.Equal(ts.T(), 0, len(pp.Posts))
+	pp, _ = ts.svc.GetTagPosts("", "soon", Page{})
+	assert.Equal(ts.T(), 0, len(pp.Posts))
+	_, err = ts.svc.GetPost(other.ID, postID)
+	assert.Equal(ts.T(), ErrPostNotFound, err)
+	_, err = ts.svc.GetThread(ts.userID, postID)
+	assert.Equal(ts.T(), ErrPostNotFound, err)
+	_, err = ts.svc.CreatePost(other.ID, createPostRequest{Body: "reply", InReplyTo: postID})
+	assert.Equal(ts.T(), ErrNoParentPost, err)
+	assert.Equal(ts.T(), ErrPostNotFound, ts.svc.React(other.ID, postID, ReactionLike))
+
+	p, err := ts.svc.GetPost(ts.userID, postID)
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), publishAt, *p.PublishAt)
+
+	scheduled, err := ts.svc.GetScheduledPosts(ts.userID)
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), 1, len(scheduled))
+	assert.Equal(ts.T(), postID, scheduled[0].ID)
+
+	rescheduleTests := []struct {
+		userID  ID
+		postID  PostID
+		at      time.Time
+		wantErr error
+	}{
+		{postID: postID, at: publishAt, wantErr: ErrInvalidID},
+		{userID: ts.userID, postID: PostID(nextID()), at: publishAt, wantErr: ErrPostNotFound},
+		{userID: other.ID, postID: postID, at: publishAt, wantErr: ErrNotPostAuthor},
+		{userID: ts.userID, postID: postID, at: time.Now().Add(-time.Hour), wantErr: ErrInvalidPublishTime},
+		{userID: ts.userID, postID: postID, at: publishAt.Add(time.Hour)},
+	}
+
+	for _, tt := range rescheduleTests {
+		err := ts.svc.ReschedulePost(tt.userID, tt.postID, tt.at)
+		assert.Equal(ts.T(), tt.wantErr, err)
+	}
+
+	scheduled, _ = ts.svc.GetScheduledPosts(ts.userID)
+	assert.Equal(ts.T(), publishAt.Add(time.Hour), *scheduled[0].PublishAt)
+
+	n, err := ts.svc.PublishDuePosts()
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), 0, n)
+
+	// a post written after the scheduled one but published before it
+	earlier, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "now"})
+
+	// make the post due as if the server was down when it was
+	post, _ := ts.svc.posts.FindByID(postID)
+	post.PublishAt = time.Now().Add(-time.Minute)
+	_ = ts.svc.posts.Update(post)
+
+	n, err = ts.svc.PublishDuePosts()
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), 1, n)
+
+	scheduled, _ = ts.svc.GetScheduledPosts(ts.userID)
+	assert.Equal(ts.T(), 0, len(scheduled))
+
+	// the post keeps the id it was scheduled under and goes ahead of the posts
+	// published before it
+	published, err := ts.svc.GetPost("", postID)
+	assert.Nil(ts.T(), err)
+	assert.Nil(ts.T(), published.PublishAt)
+	assert.Equal(ts.T(), media.ID, published.Attachments[0].ID)
+	pp, _ = ts.svc.GetUserPosts("", ts.username, Page{})
+	assert.Equal(ts.T(), []PostID{postID, earlier}, []PostID{pp.Posts[0].ID, pp.Posts[1].ID})
+	pp, _ = ts.svc.GetUserPosts("", ts.username, Page{Before: string(postID)})
+	assert.Equal(ts.T(), 1, len(pp.Posts))
+	assert.Equal(ts.T(), earlier, pp.Posts[0].ID)
+	assert.Equal(ts.T(), ErrNotScheduled, ts.svc.ReschedulePost(ts.userID, postID, publishAt))
+	assert.Equal(ts.T(), ErrNotScheduled, ts.svc.CancelScheduledPost(ts.userID, postID))
+
+	n, _ = ts.svc.PublishDuePosts()
+	assert.Equal(ts.T(), 0, n)
+
+	postID, _ = ts.svc.CreatePost(ts.userID, createPostRequest{Body: "cancelled", PublishAt: publishAt})
+	assert.Equal(ts.T(), ErrNotPostAuthor, ts.svc.CancelScheduledPost(other.ID, postID))
+	assert.Nil(ts.T(), ts.svc.CancelScheduledPost(ts.userID, postID))
+	_, err = ts.svc.posts.FindByID(postID)
+	assert.Equal(ts.T(), ErrPostNotFound, err)
+
+	// clean up
+	_ = ts.svc.users.Delete(other.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_Drafts() {
+	other := DuplicateUser(ts.svc.users, *ts.user, "draftUser")
+	parentID, _ := ts.svc.CreatePost(other.ID, createPostRequest{Body: "parent"})
+
+	createTests := []struct {
+		userID  ID
+		req     draftRequest
+		wantErr error
+	}{
+		{req: draftRequest{Body: "draft"}, wantErr: ErrInvalidID},
+		{userID: nextID(), req: draftRequest{Body: "draft"}, wantErr: ErrNotFound},
+		{userID: ts.userID, req: draftRequest{Body: strings.Repeat("a", maxDraftLength+1)}, wantErr: ErrBodyTooLong},
+		{userID: ts.userID, req: draftRequest{}},
+		{userID: ts.userID, req: draftRequest{Body: "second draft"}},
+	}
+
+	var ids []DraftID
+	for _, tt := range createTests {
+		id, err := ts.svc.CreateDraft(tt.userID, tt.req)
+		assert.Equal(ts.T(), tt.wantErr, err)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	updateTests := []struct {
+		userID  ID
+		draftID DraftID
+		req     draftRequest
+		wantErr error
+	}{
+		{draftID: ids[0], wantErr: ErrInvalidID},
+		{userID: ts.userID, draftID: "invalid", wantErr: ErrDraftNotFound},
+		{userID: other.ID, draftID: ids[0], req: draftRequest{Body: "mine"}, wantErr: ErrDraftNotFound},
+		{userID: ts.userID, draftID: ids[0], req: draftRequest{Body: "a reply", InReplyTo: parentID}},
+	}
+
+	for _, tt := range updateTests {
+		err := ts.svc.UpdateDraft(tt.userID, tt.draftID, tt.req)
+		assert.Equal(ts.T(), tt.wantErr, err)
+	}
+
+	drafts, err := ts.svc.GetDrafts(ts.userID)
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), 2, len(drafts))
+	assert.Equal(ts.T(), ids[0], drafts[0].ID)
+	assert.Equal(ts.T(), "a reply", drafts[0].Body)
+	assert.Equal(ts.T(), parentID, drafts[0].InReplyTo)
+
+	drafts, _ = ts.svc.GetDrafts(other.ID)
+	assert.Equal(ts.T(), 0, len(drafts))
+
+	// drafts stay out of feeds until they are published
+	pp, _ := ts.svc.GetUserPosts("", ts.username, Page{})
+	assert.Equal(ts.T(), 0, len(pp.Posts))
+
+	_ = ts.svc.UpdateDraft(ts.userID, ids[1], draftRequest{})
+	_, err = ts.svc.PublishDraft(ts.userID, ids[1])
+	assert.Equal(ts.T(), ErrEmptyBody, err)
+	_, err = ts.svc.PublishDraft(other.ID, ids[0])
+	assert.Equal(ts.T(), ErrDraftNotFound, err)
+
+	postID, err := ts.svc.PublishDraft(ts.userID, ids[0])
+	assert.Nil(ts.T(), err)
+	p, _ := ts.svc.GetPost("", postID)
+	assert.Equal(ts.T(), "a reply", p.Body)
+	assert.Equal(ts.T(), parentID, p.InReplyTo)
+
+	drafts, _ = ts.svc.GetDrafts(ts.userID)
+	assert.Equal(ts.T(), 1, len(drafts))
+	assert.Equal(ts.T(), ids[1], drafts[0].ID)
+
+	assert.Equal(ts.T(), ErrDraftNotFound, ts.svc.DeleteDraft(other.ID, ids[1]))
+	assert.Nil(ts.T(), ts.svc.DeleteDraft(ts.userID, ids[1]))
+	assert.Equal(ts.T(), ErrDraftNotFound, ts.svc.DeleteDraft(ts.userID, ids[1]))
+
+	// clean up
+	_ = ts.svc.users.Delete(other.ID)
 }
 
-func (ts *ServiceTestSuite) TestService_CreateProfile() {
-	id := nextID()
-	now := time.Now().UTC()
+func (ts *ServiceTestSuite) TestService_Visibility() {
+	author := DuplicateUser(ts.svc.users, *ts.user, "visAuthor")
+	follower := DuplicateUser(ts.svc.users, *ts.user, "visFollower")
+	mentioned := DuplicateUser(ts.svc.users, *ts.user, "visMentioned")
+	_ = ts.svc.CreateRelationshipFor(follower.ID, author.Username)
+
+	_, err := ts.svc.CreatePost(author.ID, createPostRequest{Body: "b", Visibility: "friends"})
+	assert.Equal(ts.T(), ErrInvalidVisibility, err)
+
+	public, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "public"})
+	followers, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "followers", Visibility: VisibilityFollowers})
+	direct, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "hi @visMentioned", Visibility: VisibilityMentioned})
+	reply, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "reply", InReplyTo: public, Visibility: VisibilityFollowers})
+
+	visible := map[ID][]PostID{
+		"":           {public},
+		author.ID:    {reply, direct, followers, public},
+		follower.ID:  {reply, followers, public},
+		mentioned.ID: {direct, public},
+		ts.userID:    {public},
+	}
+
+	for viewer, want := range visible {
+		pp, err := ts.svc.GetUserPosts(viewer, author.Username, Page{})
+		assert.Nil(ts.T(), err)
+		var got []PostID
+		for _, p := range pp.Posts {
+			got = append(got, p.ID)
+		}
+		assert.Equal(ts.T(), want, got, "posts of %s", viewer)
+
+		profile, _ := ts.svc.GetProfile(viewer, author.Username)
+		assert.Equal(ts.T(), len(want), len(profile.Posts))
+
+		canSeeReply := viewer == author.ID || viewer == follower.ID
+		thread, _ := ts.svc.GetThread(viewer, public)
+		assert.Equal(ts.T(), canSeeReply, len(thread.Post.Replies) > 0)
+
+		// counts leave out the followers only reply, so that they don't give
+		// it away to those who can't read it
+		pr, _ := ts.svc.GetPost(viewer, public)
+		assert.Equal(ts.T(), 0, pr.ReplyCount)
+
+		_, err = ts.svc.GetPost(viewer, direct)
+		if viewer == author.ID || viewer == mentioned.ID {
+			assert.Nil(ts.T(), err)
+		} else {
+			assert.Equal(ts.T(), ErrPostNotFound, err)
+		}
+	}
+
+	tl, _ := ts.svc.GetTimeline(follower.ID, Page{})
+	assert.Equal(ts.T(), 3, len(tl.Posts))
+	assert.Equal(ts.T(), VisibilityFollowers, tl.Posts[0].Visibility)
+	assert.Equal(ts.T(), VisibilityPublic, tl.Posts[2].Visibility)
+
+	_, err = ts.svc.GetPostRevisions("", followers)
+	assert.Equal(ts.T(), ErrPostNotFound, err)
+	_, err = ts.svc.GetReactions(mentioned.ID, followers)
+	assert.Equal(ts.T(), ErrPostNotFound, err)
+	assert.Equal(ts.T(), ErrPostNotFound, ts.svc.React(ts.userID, followers, ReactionLike))
+	assert.Nil(ts.T(), ts.svc.React(follower.ID, followers, ReactionLike))
+
+	_, err = ts.svc.CreatePost(ts.userID, createPostRequest{Body: "reply", InReplyTo: followers})
+	assert.Equal(ts.T(), ErrNoParentPost, err)
+	_, err = ts.svc.Repost(follower.ID, followers, "")
+	assert.Equal(ts.T(), ErrCantRepostPrivate, err)
+	_, err = ts.svc.Repost(follower.ID, followers, "quoted")
+	assert.Equal(ts.T(), ErrCantRepostPrivate, err)
+
+	quote := Post{ID: PostID(nextID()), Author: Author{UserID: follower.ID}, Kind: KindQuote, RepostOf: public, Visibility: VisibilityFollowers}
+	assert.Nil(ts.T(), ts.svc.posts.Store(quote))
+	pr, _ := ts.svc.GetPost("", public)
+	assert.Equal(ts.T(), 0, pr.RepostCount)
+
+	// clean up
+	_ = ts.svc.users.Delete(author.ID)
+	_ = ts.svc.users.Delete(follower.ID)
+	_ = ts.svc.users.Delete(mentioned.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_Polls() {
+	voter := DuplicateUser(ts.svc.users, *ts.user, "voter")
+	poll := &pollRequest{Options: []string{"tabs", "spaces"}, ExpiresIn: 3600}
+
+	_, err := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "poll", Poll: &pollRequest{Options: []string{"one"}, ExpiresIn: 3600}})
+	assert.Equal(ts.T(), ErrInvalidPoll, err)
+	_, err = ts.svc.CreatePost(ts.userID, createPostRequest{Body: "poll", Poll: &pollRequest{Options: poll.Options}})
+	assert.Equal(ts.T(), ErrInvalidPollExpiry, err)
+
+	postID, err := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "tabs or spaces?", Poll: poll})
+	assert.Nil(ts.T(), err)
+	plain, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "no poll"})
+
+	// the votes are hidden until the viewer votes
+	p, _ := ts.svc.GetPost(voter.ID, postID)
+	assert.False(ts.T(), p.Poll.Closed)
+	assert.Nil(ts.T(), p.Poll.Choice)
+	assert.Nil(ts.T(), p.Poll.TotalVotes)
+	assert.Equal(ts.T(), "tabs", p.Poll.Options[0].Text)
+	assert.Nil(ts.T(), p.Poll.Options[0].Votes)
+
+	voteTests := []struct {
+		userID  ID
+		postID  PostID
+		choice  int
+		wantErr error
+	}{
+		{postID: postID, wantErr: ErrInvalidID},
+		{userID: voter.ID, postID: PostID(nextID()), wantErr: ErrPostNotFound},
+		{userID: voter.ID, postID: plain, wantErr: ErrNoPoll},
+		{userID: voter.ID, postID: postID, choice: 2, wantErr: ErrInvalidChoice},
+		{userID: voter.ID, postID: postID, choice: 1},
+		{userID: voter.ID, postID: postID, choice: 0, wantErr: ErrAlreadyVoted},
+	}
+
+	for _, tt := range voteTests {
+		err := ts.svc.Vote(tt.userID, tt.postID, tt.choice)
+		assert.Equal(ts.T(), tt.wantErr, err)
+	}
+
+	// only one of many votes sent at once by the same user counts
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	counted := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(choice int) {
+			defer wg.Done()
+			if ts.svc.Vote(ts.userID, postID, choice) == nil {
+				mu.Lock()
+				counted++
+				mu.Unlock()
+			}
+		}(i % 2)
+	}
+	wg.Wait()
+	assert.Equal(ts.T(), 1, counted)
+
+	p, _ = ts.svc.GetPost(voter.ID, postID)
+	assert.Equal(ts.T(), 1, *p.Poll.Choice)
+	assert.Equal(ts.T(), 2, *p.Poll.TotalVotes)
+	assert.Equal(ts.T(), 2, *p.Poll.Options[0].Votes+*p.Poll.Options[1].Votes)
+
+	p, _ = ts.svc.GetPost("", postID)
+	assert.Nil(ts.T(), p.Poll.TotalVotes)
+
+	// everyone sees the votes once the poll closes
+	post, _ := ts.svc.posts.FindByID(postID)
+	post.Poll.ExpiresAt = time.Now().Add(-time.Second)
+	_ = ts.svc.posts.Update(post)
+
+	p, _ = ts.svc.GetPost("", postID)
+	assert.True(ts.T(), p.Poll.Closed)
+	assert.Equal(ts.T(), 2, *p.Poll.TotalVotes)
+	assert.Equal(ts.T(), ErrPollClosed, ts.svc.Vote(nextID(), postID, 0))
+
+	_ = ts.svc.DeletePost(ts.userID, postID)
+	votes, _ := ts.svc.votes.CountByPosts([]PostID{postID})
+	assert.Equal(ts.T(), 0, len(votes[postID]))
+
+	// the poll of a scheduled post stays open for as long once rescheduled
+	// and once published
+	publishAt := time.Now().Add(time.Hour).UTC()
+	postID, _ = ts.svc.CreatePost(ts.userID, createPostRequest{Body: "later?", PublishAt: publishAt, Poll: poll})
+	assert.Nil(ts.T(), ts.svc.ReschedulePost(ts.userID, postID, publishAt.Add(24*time.Hour)))
+	post, _ = ts.svc.posts.FindByID(postID)
+	assert.Equal(ts.T(), publishAt.Add(24*time.Hour+time.Hour), post.Poll.ExpiresAt)
+
+	post.PublishAt = time.Now().Add(-time.Minute)
+	_ = ts.svc.posts.Update(post)
+	n, _ := ts.svc.PublishDuePosts()
+	assert.Equal(ts.T(), 1, n)
+	post, _ = ts.svc.posts.FindByID(postID)
+	assert.Equal(ts.T(), post.Timestamp.Add(time.Hour), post.Poll.ExpiresAt)
+	_ = ts.svc.DeletePost(ts.userID, postID)
+
+	// clean up
+	_ = ts.svc.users.Delete(voter.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_ContentWarnings() {
+	reader := DuplicateUser(ts.svc.users, *ts.user, "cwReader")
+	long := strings.Repeat("a", maxContentWarningLength+1)
+
+	_, err := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "b", ContentWarning: long})
+	assert.Equal(ts.T(), ErrContentWarningTooLong, err)
+
+	warned, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "it was a sled", ContentWarning: "film ending"})
+	sensitive, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "look", Sensitive: true})
+	plain, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "nothing to hide"})
+
 	tests := []struct {
-		username, email string
-		wantCreatedAt   bool
+		postID                      PostID
+		wantWarning                 string
+		wantSensitive, wantCollapse bool
 	}{
-		{username: ts.username},
-		{username: "new", email: ts.email},
-		{username: "new", email: "n@e.co", wantCreatedAt: true},
+		{postID: warned, wantWarning: "film ending", wantCollapse: true},
+		{postID: sensitive, wantSensitive: true, wantCollapse: true},
+		{postID: plain},
 	}
 
 	for _, tt := range tests {
-		ts.svc.CreateProfile(string(id), tt.username, tt.email)
+		for _, viewer := range []ID{"", reader.ID} {
+			p, _ := ts.svc.GetPost(viewer, tt.postID)
+			assert.Equal(ts.T(), tt.wantWarning, p.ContentWarning)
+			assert.Equal(ts.T(), tt.wantSensitive, p.Sensitive)
+			assert.Equal(ts.T(), tt.wantCollapse, p.Collapsed)
+		}
+	}
 
-		user, _ := ts.svc.users.FindByID(id)
+	expand := true
+	assert.Nil(ts.T(), ts.svc.EditProfile(reader.ID, editProfileRequest{ExpandSensitive: &expand}))
 
-		if user != nil {
-			assert.Equal(ts.T(), tt.wantCreatedAt, user.CreatedAt.After(now))
-		}
+	p, _ := ts.svc.GetPost(reader.ID, warned)
+	assert.Equal(ts.T(), "film ending", p.ContentWarning)
+	assert.False(ts.T(), p.Collapsed)
+	p, _ = ts.svc.GetPost("", warned)
+	assert.True(ts.T(), p.Collapsed)
+
+	// the preference is private to the user
+	profile, _ := ts.svc.GetProfile(reader.ID, reader.Username)
+	assert.True(ts.T(), *profile.ExpandSensitive)
+	profile, _ = ts.svc.GetProfile(ts.userID, reader.Username)
+	assert.Nil(ts.T(), profile.ExpandSensitive)
+
+	// clean up
+	_ = ts.svc.users.Delete(reader.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_PinnedPosts() {
+	other := DuplicateUser(ts.svc.users, *ts.user, "pinOther")
+	var ids []PostID
+	for _, body := range []string{"a", "b", "c", "d"} {
+		id, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: body})
+		ids = append(ids, id)
+	}
+	private, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "p", Visibility: VisibilityFollowers})
+	otherPost, _ := ts.svc.CreatePost(other.ID, createPostRequest{Body: "not mine"})
+
+	tests := []struct {
+		id      ID
+		postID  PostID
+		wantErr error
+	}{
+		{id: "invalid", postID: ids[0], wantErr: ErrInvalidID},
+		{id: ts.userID, postID: "missing", wantErr: ErrPostNotFound},
+		{id: ts.userID, postID: otherPost, wantErr: ErrNotPostAuthor},
+		{id: ts.userID, postID: ids[0], wantErr: nil},
+		{id: ts.userID, postID: ids[0], wantErr: ErrAlreadyPinned},
+		{id: ts.userID, postID: private, wantErr: nil},
+		{id: ts.userID, postID: ids[2], wantErr: nil},
+		{id: ts.userID, postID: ids[3], wantErr: ErrTooManyPinned},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(ts.T(), tt.wantErr, ts.svc.PinPost(tt.id, tt.postID))
 	}
+
+	// pinned posts come first in the order they were pinned and stay in the
+	// chronological list
+	profile, _ := ts.svc.GetProfile(ts.userID, ts.username)
+	assert.Equal(ts.T(), []PostID{ids[2], private, ids[0]}, responseIDs(profile.Pinned))
+	assert.Contains(ts.T(), responseIDs(profile.Posts), ids[2])
+
+	// other users only see the pinned posts they can read
+	profile, _ = ts.svc.GetProfile(other.ID, ts.username)
+	assert.Equal(ts.T(), []PostID{ids[2], ids[0]}, responseIDs(profile.Pinned))
+
+	assert.Equal(ts.T(), ErrNotPinned, ts.svc.UnpinPost(ts.userID, ids[1]))
+	assert.Nil(ts.T(), ts.svc.UnpinPost(ts.userID, ids[2]))
+	assert.Nil(ts.T(), ts.svc.PinPost(ts.userID, ids[3]))
+
+	// deleting a pinned post unpins it
+	assert.Nil(ts.T(), ts.svc.DeletePost(ts.userID, ids[0]))
+	user, _ := ts.svc.users.FindByID(ts.userID)
+	assert.Equal(ts.T(), []PostID{ids[3], private}, user.Pinned)
+
+	// clean up
+	for _, id := range user.Pinned {
+		_ = ts.svc.UnpinPost(ts.userID, id)
+	}
+	_ = ts.svc.users.Delete(other.ID)
 }
 
-func (ts *ServiceTestSuite) TestService_CreatePost() {
+func responseIDs(posts []postResponse) []PostID {
+	ids := []PostID{}
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func (ts *ServiceTestSuite) TestService_Bookmarks() {
+	author := DuplicateUser(ts.svc.users, *ts.user, "bmAuthor")
+	var ids []PostID
+	for _, body := range []string{"a", "b", "c"} {
+		id, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: body})
+		ids = append(ids, id)
+	}
+	private, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "p", Visibility: VisibilityFollowers})
+	repost, _ := ts.svc.Repost(ts.userID, ids[1], "")
+
 	tests := []struct {
-		userID              ID
-		body                string
-		wantErr             error
-		wantValidID, wantTS bool
-		wantUsername        string
+		id      ID
+		postID  PostID
+		wantErr error
 	}{
-		{wantErr: ErrInvalidID},
-		{userID: "user", wantErr: ErrInvalidID},
-		{userID: nextID(), body: "post", wantErr: ErrNotFound},
-		{userID: ts.userID, wantErr: ErrEmptyBody},
-		{userID: ts.userID, body: "post", wantValidID: true, wantErr: nil, wantTS: true},
+		{id: "invalid", postID: ids[0], wantErr: ErrInvalidID},
+		{id: ts.userID, postID: "missing", wantErr: ErrPostNotFound},
+		{id: ts.userID, postID: private, wantErr: ErrPostNotFound},
+		{id: ts.userID, postID: ids[2], wantErr: nil},
+		{id: ts.userID, postID: ids[2], wantErr: ErrAlreadyBookmarked},
+		{id: ts.userID, postID: ids[0], wantErr: nil},
+		{id: ts.userID, postID: repost, wantErr: nil},
+		{id: ts.userID, postID: ids[1], wantErr: ErrAlreadyBookmarked},
 	}
+
 	for _, tt := range tests {
-		now := time.Now()
-		id, err := ts.svc.CreatePost(tt.userID, tt.body)
-		assert.Equal(ts.T(), tt.wantValidID, IsValidID(string(id)))
+		assert.Equal(ts.T(), tt.wantErr, ts.svc.BookmarkPost(tt.id, tt.postID))
+	}
+
+	// bookmarks come back in the order they were saved, not the order the
+	// posts were published
+	pp, err := ts.svc.GetBookmarks(ts.userID, Page{Limit: 2})
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), []PostID{ids[1], ids[0]}, responseIDs(pp.Posts))
+	assert.NotEmpty(ts.T(), pp.Next)
+
+	pp, _ = ts.svc.GetBookmarks(ts.userID, Page{Limit: 2, Before: pp.Next})
+	assert.Equal(ts.T(), []PostID{ids[2]}, responseIDs(pp.Posts))
+	assert.Empty(ts.T(), pp.Next)
+	assert.NotEmpty(ts.T(), pp.Prev)
+
+	pp, _ = ts.svc.GetBookmarks(ts.userID, Page{After: pp.Prev})
+	assert.Equal(ts.T(), []PostID{ids[1], ids[0]}, responseIDs(pp.Posts))
+
+	// bookmarks are private to the user
+	pp, _ = ts.svc.GetBookmarks(author.ID, Page{})
+	assert.Empty(ts.T(), pp.Posts)
+
+	_, err = ts.svc.GetBookmarks(ts.userID, Page{Before: "invalid"})
+	assert.Equal(ts.T(), ErrInvalidCursor, err)
+
+	assert.Equal(ts.T(), ErrNotBookmarked, ts.svc.RemoveBookmark(ts.userID, private))
+	assert.Nil(ts.T(), ts.svc.RemoveBookmark(ts.userID, repost))
+	assert.Nil(ts.T(), ts.svc.RemoveBookmark(ts.userID, ids[0]))
+
+	// deleting a post prunes its bookmarks
+	assert.Nil(ts.T(), ts.svc.DeletePost(author.ID, ids[2]))
+	assert.Equal(ts.T(), ErrNotBookmarked, ts.svc.RemoveBookmark(ts.userID, ids[2]))
+	pp, _ = ts.svc.GetBookmarks(ts.userID, Page{})
+	assert.Empty(ts.T(), pp.Posts)
+
+	// clean up
+	_ = ts.svc.users.Delete(author.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_GetExplore() {
+	other := DuplicateUser(ts.svc.users, *ts.user, "explorer")
+	old, _ := ts.svc.CreatePost(other.ID, createPostRequest{Body: "from long ago"})
+	p, _ := ts.svc.posts.FindByID(old)
+	p.Timestamp = time.Now().Add(-exploreWindow - time.Hour)
+	_ = ts.svc.posts.Update(p)
+
+	p1, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "reposted"})
+	p2, _ := ts.svc.CreatePost(other.ID, createPostRequest{Body: "replied to"})
+	p3, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "a reply", InReplyTo: p2})
+	p4, _ := ts.svc.CreatePost(other.ID, createPostRequest{Body: "reacted to"})
+	_ = ts.svc.React(ts.userID, p4, ReactionLike)
+	_, _ = ts.svc.CreatePost(other.ID, createPostRequest{Body: "followers only", Visibility: VisibilityFollowers})
+	_, _ = ts.svc.CreatePost(other.ID, createPostRequest{Body: "not yet", PublishAt: time.Now().Add(time.Hour)})
+	_, _ = ts.svc.Repost(other.ID, p1, "")
+	p5, _ := ts.svc.CreatePost(other.ID, createPostRequest{Body: "nobody noticed"})
+
+	tests := []struct {
+		viewer   ID
+		engaged  bool
+		page     Page
+		want     []PostID
+		wantNext string
+		wantPrev string
+		wantErr  error
+	}{
+		{page: Page{Before: "invalid"}, wantErr: ErrInvalidCursor},
+		{want: []PostID{p5, p4, p3, p2, p1}},
+		// posts with narrower visibility stay out even for those who can see them
+		{viewer: other.ID, want: []PostID{p5, p4, p3, p2, p1}},
+		{engaged: true, want: []PostID{p4, p2, p1}},
+		{engaged: true, page: Page{Limit: 1}, want: []PostID{p4}, wantNext: string(p4)},
+		{engaged: true, page: Page{Limit: 1, Before: string(p4)}, want: []PostID{p2}, wantNext: string(p2), wantPrev: string(p2)},
+		{engaged: true, page: Page{Limit: 1, After: string(p1)}, want: []PostID{p2}, wantNext: string(p2), wantPrev: string(p2)},
+		{engaged: true, page: Page{Limit: 1, After: string(p2)}, want: []PostID{p4}, wantNext: string(p4)},
+		{engaged: true, page: Page{Before: string(p1)}, want: []PostID{}},
+	}
+
+	for _, tt := range tests {
+		pp, err := ts.svc.GetExplore(tt.viewer, tt.engaged, tt.page)
 		assert.Equal(ts.T(), tt.wantErr, err)
+		if tt.wantErr == nil {
+			assert.Equal(ts.T(), tt.want, responseIDs(pp.Posts))
+			assert.Equal(ts.T(), tt.wantNext, pp.Next)
+			assert.Equal(ts.T(), tt.wantPrev, pp.Prev)
+		}
+	}
 
-		if tt.wantValidID {
-			post, err := ts.svc.posts.FindByID(id)
-			assert.Nil(ts.T(), err)
-			assert.Equal(ts.T(), tt.body, post.Body)
-			assert.Equal(ts.T(), tt.userID, post.Author.UserID)
-			assert.Equal(ts.T(), tt.wantTS, post.Timestamp.After(now))
+	// a page reads a bounded number of posts and goes on from the last one
+	var quiet []PostID
+	for i := 0; i < maxEngagedBatches*2; i++ {
+		id, _ := ts.svc.CreatePost(other.ID, createPostRequest{Body: "quiet"})
+		quiet = append(quiet, id)
+	}
+	pp, _ := ts.svc.GetExplore("", true, Page{Limit: 1})
+	assert.Empty(ts.T(), pp.Posts)
+	assert.Equal(ts.T(), string(quiet[0]), pp.Next)
+	pp, _ = ts.svc.GetExplore("", true, Page{Limit: 1, Before: pp.Next})
+	assert.Equal(ts.T(), []PostID{p4}, responseIDs(pp.Posts))
+
+	// clean up
+	_ = ts.svc.users.Delete(other.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_GetTrends() {
+	p1, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "hello #gophers"})
+	_, _ = ts.svc.CreatePost(ts.userID, createPostRequest{Body: "#Gophers again and #rare"})
+	_, _ = ts.svc.CreatePost(ts.userID, createPostRequest{Body: "secret #gophers", Visibility: VisibilityFollowers})
+	_, _ = ts.svc.CreatePost(ts.userID, createPostRequest{Body: "later #gophers", PublishAt: time.Now().Add(time.Hour)})
+	other := DuplicateUser(ts.svc.users, *ts.user, "trendsetter")
+	_, _ = ts.svc.Repost(other.ID, p1, "more #gophers")
+
+	_, err := ts.svc.GetTrends("1w", 0)
+	assert.Equal(ts.T(), ErrInvalidTrendWindow, err)
+
+	// private and scheduled posts don't count, and #rare is used too little
+	for _, w := range []TrendWindow{"", TrendHour, TrendDay} {
+		trends, err := ts.svc.GetTrends(w, 0)
+		assert.Nil(ts.T(), err)
+		if assert.Len(ts.T(), trends, 1) {
+			assert.Equal(ts.T(), "gophers", trends[0].Tag)
+			assert.Equal(ts.T(), 3, trends[0].Posts)
+			assert.True(ts.T(), trends[0].Score > 0)
 		}
 	}
+
+	// deleted posts and edited out tags are taken off the counts
+	gophers := func() int {
+		trends, _ := ts.svc.GetTrends(TrendHour, 0)
+		for _, t := range trends {
+			if t.Tag == "gophers" {
+				return t.Posts
+			}
+		}
+		return 0
+	}
+	extra, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "one more #gophers"})
+	assert.Equal(ts.T(), 4, gophers())
+	assert.Nil(ts.T(), ts.svc.DeletePost(ts.userID, extra))
+	assert.Equal(ts.T(), 3, gophers())
+	assert.Nil(ts.T(), ts.svc.EditPost(ts.userID, p1, "hello #golang"))
+	assert.Equal(ts.T(), 0, gophers())
+
+	// clean up
+	_ = ts.svc.users.Delete(other.ID)
 }
 
-func (ts *ServiceTestSuite) TestService_GetUserPosts() {
-	_, _ = ts.svc.CreatePost(ts.userID, "body")
+func (ts *ServiceTestSuite) TestService_SearchPosts() {
+	author := DuplicateUser(ts.svc.users, *ts.user, "searchAuthor")
+	follower := DuplicateUser(ts.svc.users, *ts.user, "searchFollower")
+	follower.Friends, author.Followers = nil, nil
+	follower.Follow(author)
+	a, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "error handling in go"})
+	b, _ := ts.svc.CreatePost(ts.userID, createPostRequest{Body: "errors, errors and more errors"})
+	c, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "handling errors the go way"})
+	private, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "secret error", Visibility: VisibilityFollowers})
+	_, _ = ts.svc.CreatePost(author.ID, createPostRequest{Body: "future error", PublishAt: time.Now().Add(time.Hour)})
+
+	today := time.Now().UTC().Format(searchDateLayout)
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(searchDateLayout)
 
 	tests := []struct {
-		username     string
-		wantErr      error
-		wantPostsLen int
+		viewer  ID
+		q       string
+		page    SearchPage
+		want    []PostID
+		wantErr error
 	}{
-		{wantErr: ErrInvalidUsername},
-		{username: "void", wantErr: ErrNotFound},
-		{username: ts.username, wantErr: nil, wantPostsLen: 1},
+		{q: "", wantErr: ErrEmptySearch},
+		{q: "error", page: SearchPage{Order: "popular"}, wantErr: ErrInvalidSearchOrder},
+		{q: "error", page: SearchPage{Offset: -1}, wantErr: ErrInvalidCursor},
+		{q: "error", want: []PostID{b, a, c}},
+		{q: "error", page: SearchPage{Order: SearchByRecency}, want: []PostID{c, b, a}},
+		{viewer: author.ID, q: "error", page: SearchPage{Order: SearchByRecency}, want: []PostID{private, c, b, a}},
+		{viewer: follower.ID, q: "secret", want: []PostID{private}},
+		{viewer: ts.userID, q: "secret", want: []PostID{}},
+		{q: `"error handling"`, want: []PostID{a}},
+		{q: "error from:searchAuthor", page: SearchPage{Order: SearchByRecency}, want: []PostID{c, a}},
+		{q: "error from:nobody", want: []PostID{}},
+		{q: "error since:" + today + " until:" + today, page: SearchPage{Order: SearchByRecency}, want: []PostID{c, b, a}},
+		{q: "error since:" + tomorrow, want: []PostID{}},
+		// offsets only count the posts the viewer can see
+		{q: "error", page: SearchPage{Order: SearchByRecency, Limit: 2, Offset: 1}, want: []PostID{b, a}},
+		{viewer: author.ID, q: "error", page: SearchPage{Order: SearchByRecency, Limit: 2, Offset: 1}, want: []PostID{c, b}},
 	}
 
 	for _, tt := range tests {
-		posts, err := ts.svc.GetUserPosts(tt.username)
-		assert.Equal(ts.T(), tt.wantErr, err)
-		assert.Equal(ts.T(), tt.wantPostsLen, len(posts))
+		pp, err := ts.svc.SearchPosts(tt.viewer, tt.q, tt.page)
+		assert.Equal(ts.T(), tt.wantErr, err, tt.q)
+		if tt.wantErr == nil {
+			assert.Equal(ts.T(), tt.want, responseIDs(pp.Posts), tt.q)
+		}
+	}
+
+	pp, _ := ts.svc.SearchPosts("", "error", SearchPage{Order: SearchByRecency, Limit: 2})
+	assert.Equal(ts.T(), "2", pp.Next)
+	assert.Empty(ts.T(), pp.Prev)
+	pp, _ = ts.svc.SearchPosts("", "error", SearchPage{Order: SearchByRecency, Limit: 2, Offset: 2})
+	assert.Empty(ts.T(), pp.Next)
+	assert.Equal(ts.T(), "0", pp.Prev)
+
+	// edited and deleted posts are searched as they are now
+	assert.Nil(ts.T(), ts.svc.EditPost(author.ID, a, "exceptions in go"))
+	assert.Nil(ts.T(), ts.svc.DeletePost(author.ID, c))
+	pp, _ = ts.svc.SearchPosts("", "error", SearchPage{})
+	assert.Equal(ts.T(), []PostID{b}, responseIDs(pp.Posts))
+
+	// clean up
+	_ = ts.svc.users.Delete(author.ID)
+	_ = ts.svc.users.Delete(follower.ID)
+}
+
+func (ts *ServiceTestSuite) TestService_SearchUsers() {
+	var users []*User
+	for _, name := range []string{"Gopherina", "gopher", "gophr", "Gophercon"} {
+		u := DuplicateUser(ts.svc.users, *ts.user, name)
+		u.Bio, u.Followers = "", nil
+		users = append(users, u)
+	}
+	users[3].Followers = []ID{ts.userID}
+	fan := DuplicateUser(ts.svc.users, *ts.user, "fan")
+	fan.Bio = "Loves every Gopher"
+
+	tests := []struct {
+		q         string
+		limit     int
+		wantNames []string
+		wantErr   error
+	}{
+		{q: " @ ", wantErr: ErrEmptySearch},
+		{q: "@GOPHER", wantNames: []string{"gopher", "Gophercon", "Gopherina", "gophr", "fan"}},
+		{q: "gopher", limit: 2, wantNames: []string{"gopher", "Gophercon"}},
+		// one edit away beats two, then the most followed comes first
+		{q: "gophre", wantNames: []string{"gophr", "Gophercon", "Gopherina", "gopher", "fan"}},
+		{q: "zebra", wantNames: []string{}},
+	}
+
+	for _, tt := range tests {
+		infos, err := ts.svc.SearchUsers(tt.q, tt.limit)
+		assert.Equal(ts.T(), tt.wantErr, err, tt.q)
+		if tt.wantErr == nil {
+			names := []string{}
+			for _, info := range infos {
+				names = append(names, info.Username)
+			}
+			assert.Equal(ts.T(), tt.wantNames, names, tt.q)
+		}
+	}
+
+	// clean up
+	for _, u := range append(users, fan) {
+		_ = ts.svc.users.Delete(u.ID)
 	}
 }
 
@@ -120,7 +1481,7 @@ func (ts *ServiceTestSuite) TestService_GetProfile() {
 	}
 
 	for _, tt := range tests {
-		p, err := ts.svc.GetProfile(tt.username)
+		p, err := ts.svc.GetProfile("", tt.username)
 
 		assert.Equal(ts.T(), tt.wantErr, err)
 		assert.Equal(ts.T(), tt.wantUN, p.Username)
@@ -324,10 +1685,10 @@ func (ts *ServiceTestSuite) TestGetTimeline() {
 	u1.Follow(u4)
 	u5.Follow(u4)
 
-	_, _ = ts.svc.CreatePost(u2.ID, "p2")
-	_, _ = ts.svc.CreatePost(u1.ID, "p3")
-	_, _ = ts.svc.CreatePost(u2.ID, "p4")
-	_, _ = ts.svc.CreatePost(u4.ID, "p5")
+	_, _ = ts.svc.CreatePost(u2.ID, createPostRequest{Body: "p2"})
+	_, _ = ts.svc.CreatePost(u1.ID, createPostRequest{Body: "p3"})
+	_, _ = ts.svc.CreatePost(u2.ID, createPostRequest{Body: "p4"})
+	_, _ = ts.svc.CreatePost(u4.ID, createPostRequest{Body: "p5"})
 
 	tests := []struct {
 		id          ID
@@ -344,10 +1705,10 @@ func (ts *ServiceTestSuite) TestGetTimeline() {
 	}
 
 	for _, tt := range tests {
-		tl, err := ts.svc.GetTimeline(tt.id)
+		tl, err := ts.svc.GetTimeline(tt.id, Page{})
 
 		assert.Equal(ts.T(), tt.wantErr, err)
-		assert.Equal(ts.T(), tt.wantPostLen, len(tl))
+		assert.Equal(ts.T(), tt.wantPostLen, len(tl.Posts))
 	}
 
 	// clean up
@@ -358,6 +1719,181 @@ func (ts *ServiceTestSuite) TestGetTimeline() {
 	_ = ts.svc.users.Delete(u5.ID)
 }
 
+func (ts *ServiceTestSuite) TestGetTimeline_Authors() {
+	u1 := DuplicateUser(ts.svc.users, *ts.user, "b1")
+	u2 := DuplicateUser(ts.svc.users, *ts.user, "b2")
+	u3 := DuplicateUser(ts.svc.users, *ts.user, "b3")
+	u1.Follow(u2)
+	u1.Follow(u3)
+
+	p1, _ := ts.svc.CreatePost(u1.ID, createPostRequest{Body: "p1"})
+	p2, _ := ts.svc.CreatePost(u2.ID, createPostRequest{Body: "p2"})
+	p3, _ := ts.svc.CreatePost(u3.ID, createPostRequest{Body: "p3"})
+
+	// u3 deletes their account after posting
+	_ = ts.svc.users.Delete(u3.ID)
+
+	tl, err := ts.svc.GetTimeline(u1.ID, Page{})
+	assert.Nil(ts.T(), err)
+
+	authors := map[PostID]authorResponse{}
+	for _, p := range tl.Posts {
+		authors[p.ID] = p.Author
+	}
+
+	assert.Equal(ts.T(), 3, len(tl.Posts))
+	assert.Equal(ts.T(), authorResponse{UserID: u1.ID, Username: "b1", Avatar: avatar(u1.Email)}, authors[p1])
+	assert.Equal(ts.T(), authorResponse{UserID: u2.ID, Username: "b2", Avatar: avatar(u2.Email)}, authors[p2])
+	assert.Equal(ts.T(), deletedAuthor(u3.ID), authors[p3])
+	assert.Equal(ts.T(), deletedUsername, authors[p3].Username)
+
+	// clean up
+	_ = ts.svc.users.Delete(u1.ID)
+	_ = ts.svc.users.Delete(u2.ID)
+}
+
+func (ts *ServiceTestSuite) TestGetTimeline_FanOut() {
+	reader := DuplicateUser(ts.svc.users, *ts.user, "c1")
+	author := DuplicateUser(ts.svc.users, *ts.user, "c2")
+	star := DuplicateUser(ts.svc.users, *ts.user, "c3")
+	for _, u := range []*User{reader, author, star} {
+		u.Friends, u.Followers = nil, nil
+	}
+	for i := 0; i < maxFanOutFollowers; i++ {
+		star.Followers = append(star.Followers, nextID())
+	}
+
+	// reading the timeline stores it, so that follows and posts are added to it
+	_, err := ts.svc.GetTimeline(reader.ID, Page{})
+	assert.Nil(ts.T(), err)
+
+	before, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "before the follow"})
+	assert.Nil(ts.T(), ts.svc.CreateRelationshipFor(reader.ID, author.Username))
+	assert.Nil(ts.T(), ts.svc.CreateRelationshipFor(reader.ID, star.Username))
+	assert.True(ts.T(), star.isPopular())
+
+	after, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "after the follow"})
+	starPost, _ := ts.svc.CreatePost(star.ID, createPostRequest{Body: "to my many fans"})
+	own, _ := ts.svc.CreatePost(reader.ID, createPostRequest{Body: "mine"})
+	_, _ = ts.svc.CreatePost(author.ID, createPostRequest{Body: "later", PublishAt: time.Now().Add(time.Hour)})
+
+	// the follow backfills, posts are pushed to followers and popular
+	// accounts are merged in when the timeline is read
+	tl, err := ts.svc.GetTimeline(reader.ID, Page{})
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), []PostID{own, starPost, after, before}, responseIDs(tl.Posts))
+
+	entries, _ := ts.svc.timelines.Find(reader.ID, 0)
+	assert.Len(ts.T(), entries, 3)
+	entries, _ = ts.svc.timelines.Find(star.Followers[0], 0)
+	assert.Empty(ts.T(), entries)
+
+	assert.Nil(ts.T(), ts.svc.DeletePost(author.ID, before))
+	assert.Nil(ts.T(), ts.svc.RemoveRelationshipFor(reader.ID, star.Username))
+	tl, _ = ts.svc.GetTimeline(reader.ID, Page{})
+	assert.Equal(ts.T(), []PostID{own, after}, responseIDs(tl.Posts))
+
+	// pages merge in no more than a page of each popular account
+	assert.Nil(ts.T(), ts.svc.CreateRelationshipFor(reader.ID, star.Username))
+	tl, _ = ts.svc.GetTimeline(reader.ID, Page{Limit: 2})
+	assert.Equal(ts.T(), []PostID{own, starPost}, responseIDs(tl.Posts))
+	assert.Equal(ts.T(), string(starPost), tl.Next)
+	tl, _ = ts.svc.GetTimeline(reader.ID, Page{Limit: 2, Before: tl.Next})
+	assert.Equal(ts.T(), []PostID{after}, responseIDs(tl.Posts))
+	assert.Empty(ts.T(), tl.Next)
+	tl, _ = ts.svc.GetTimeline(reader.ID, Page{Limit: 1, After: string(after)})
+	assert.Equal(ts.T(), []PostID{starPost}, responseIDs(tl.Posts))
+	assert.Nil(ts.T(), ts.svc.RemoveRelationshipFor(reader.ID, star.Username))
+
+	// unfollowing purges the author's posts
+	assert.Nil(ts.T(), ts.svc.RemoveRelationshipFor(reader.ID, author.Username))
+	tl, _ = ts.svc.GetTimeline(reader.ID, Page{})
+	assert.Equal(ts.T(), []PostID{own}, responseIDs(tl.Posts))
+
+	// clean up
+	for _, u := range []*User{reader, author, star} {
+		_ = ts.svc.users.Delete(u.ID)
+	}
+}
+
+func (ts *ServiceTestSuite) TestGetTimeline_Rebuild() {
+	reader := DuplicateUser(ts.svc.users, *ts.user, "d1")
+	author := DuplicateUser(ts.svc.users, *ts.user, "d2")
+	reader.Friends, author.Followers = nil, nil
+
+	// posts stored before timelines were
+	p1, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "p1"})
+	p2, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "p2"})
+	reader.Follow(author)
+
+	tl, err := ts.svc.GetTimeline(reader.ID, Page{})
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), []PostID{p2, p1}, responseIDs(tl.Posts))
+
+	entries, _ := ts.svc.timelines.Find(reader.ID, 0)
+	assert.Len(ts.T(), entries, 2)
+	for i, id := range []PostID{p2, p1} {
+		assert.Equal(ts.T(), id, entries[i].PostID)
+		assert.Equal(ts.T(), author.ID, entries[i].Author)
+	}
+
+	// a timeline with nothing to rebuild from is only rebuilt once
+	other := DuplicateUser(ts.svc.users, *ts.user, "d3")
+	other.Friends, other.Followers = nil, nil
+	reader2 := DuplicateUser(ts.svc.users, *ts.user, "d4")
+	reader2.Friends = nil
+	reader2.Follow(other)
+	_, err = ts.svc.GetTimeline(reader2.ID, Page{})
+	assert.Nil(ts.T(), err)
+	_, err = ts.svc.timelines.Find(reader2.ID, 0)
+	assert.Nil(ts.T(), err)
+
+	// clean up
+	for _, u := range []*User{reader, author, other, reader2} {
+		_ = ts.svc.users.Delete(u.ID)
+	}
+}
+
+func (ts *ServiceTestSuite) TestGetTimeline_PostsStoredBeforeTimelines() {
+	reader := DuplicateUser(ts.svc.users, *ts.user, "e1")
+	author := DuplicateUser(ts.svc.users, *ts.user, "e2")
+	loner := DuplicateUser(ts.svc.users, *ts.user, "e3")
+	for _, u := range []*User{reader, author, loner} {
+		u.Friends, u.Followers = nil, nil
+	}
+
+	// posts and follows stored before timelines were
+	var old []PostID
+	for _, u := range []*User{author, reader, loner} {
+		p, _ := NewPost(Author{UserID: u.ID}, "old")
+		p.ID = PostID(nextID())
+		assert.Nil(ts.T(), ts.svc.posts.Store(*p))
+		old = append(old, p.ID)
+	}
+	reader.Follow(author)
+
+	latest, err := ts.svc.posts.FindLatestPostsForUserAndFriends(reader, 1)
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), []PostID{old[1]}, []PostID{latest[0].ID})
+
+	// posting doesn't start a timeline holding only the new post
+	p1, _ := ts.svc.CreatePost(author.ID, createPostRequest{Body: "new"})
+	p2, _ := ts.svc.CreatePost(loner.ID, createPostRequest{Body: "new"})
+
+	tl, err := ts.svc.GetTimeline(reader.ID, Page{})
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), []PostID{p1, old[1], old[0]}, responseIDs(tl.Posts))
+
+	tl, err = ts.svc.GetTimeline(loner.ID, Page{})
+	assert.Nil(ts.T(), err)
+	assert.Equal(ts.T(), []PostID{p2, old[2]}, responseIDs(tl.Posts))
+
+	// clean up
+	for _, u := range []*User{reader, author, loner} {
+		_ = ts.svc.users.Delete(u.ID)
+	}
+}
+
 func (ts *ServiceTestSuite) TestNewService() {
 	users := NewUserRepository()
 	posts := NewPostRepository()
@@ -367,6 +1903,42 @@ func (ts *ServiceTestSuite) TestNewService() {
 
 	assert.Equal(ts.T(), users, s.users)
 	assert.Equal(ts.T(), posts, s.posts)
+	assert.NotNil(ts.T(), s.reactions)
+
+	assert.NotNil(ts.T(), s.attachments)
+	assert.NotNil(ts.T(), s.blobs)
+	assert.NotNil(ts.T(), s.drafts)
+	assert.NotNil(ts.T(), s.votes)
+	assert.NotNil(ts.T(), s.bookmarks)
+	assert.NotNil(ts.T(), s.timelines)
+
+	reactions := NewReactionRepository()
+	attachments := NewAttachmentRepository()
+	blobs := NewBlobStore()
+	s = NewService(users, posts, WithReactionRepository(reactions), WithMedia(attachments, blobs)).(*service)
+	assert.Equal(ts.T(), reactions, s.reactions)
+	assert.Equal(ts.T(), attachments, s.attachments)
+	assert.Equal(ts.T(), blobs, s.blobs)
+
+	drafts := NewDraftRepository()
+	s = NewService(users, posts, WithDraftRepository(drafts)).(*service)
+	assert.Equal(ts.T(), drafts, s.drafts)
+
+	votes := NewVoteRepository()
+	s = NewService(users, posts, WithVoteRepository(votes)).(*service)
+	assert.Equal(ts.T(), votes, s.votes)
+
+	bookmarks := NewBookmarkRepository()
+	s = NewService(users, posts, WithBookmarkRepository(bookmarks)).(*service)
+	assert.Equal(ts.T(), bookmarks, s.bookmarks)
+
+	timelines := NewTimelineRepository()
+	s = NewService(users, posts, WithTimelineRepository(timelines)).(*service)
+	assert.Equal(ts.T(), timelines, s.timelines)
+
+	trends := NewTrendRepository()
+	s = NewService(users, posts, WithTrendRepository(trends)).(*service)
+	assert.Equal(ts.T(), trends, s.trends)
 }
 
 func TestServiceSuite(t *testing.T) {
@@ -0,0 +1,35 @@
+package blog
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidTag = errors.New("invalid tag")
+
+var tagName = regexp.MustCompile(`^[\p{L}\p{M}\p{N}_]*\p{L}[\p{L}\p{M}\p{N}_]*$`)
+
+// extractTags returns the normalized hashtags in body in the order they first
+// appear, without duplicates.
+func extractTags(body string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, e := range tokenize(body) {
+		if e.Type == EntityHashtag && !seen[e.Value] {
+			seen[e.Value] = true
+			tags = append(tags, e.Value)
+		}
+	}
+	return tags
+}
+
+// normalizeTag drops the leading # of a tag, if any, and lowercases it so that
+// #Go, #GO and go are the same tag
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+func isValidTag(tag string) bool {
+	return tagName.MatchString(tag)
+}
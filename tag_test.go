@@ -0,0 +1,26 @@
+package blog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractTags(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "no tags here", want: nil},
+		{body: "#go", want: []string{"go"}},
+		{body: "meetup tonight #GoLagos #golagos #Go", want: []string{"golagos", "go"}},
+		{body: "(#first),#second. #third!", want: []string{"first", "second", "third"}},
+		{body: "#one#two", want: []string{"one"}},
+		{body: "issue#12 &#39; # #123 #_", want: nil},
+		{body: "#2019_recap #café", want: []string{"2019_recap", "café"}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, extractTags(tt.body), tt.body)
+	}
+}
@@ -0,0 +1,56 @@
+package blog
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrTimelineNotFound = errors.New("timeline not found")
+
+const (
+	// maxTimelineLength is how many posts a stored timeline keeps. Older
+	// posts drop off the end as new ones are pushed.
+	maxTimelineLength = 800
+	// maxFanOutFollowers is the most followers an account can have for its
+	// posts to be pushed to their timelines. The posts of accounts with more
+	// followers are merged into the timelines of their followers when read.
+	maxFanOutFollowers = 10000
+)
+
+// TimelineRepository stores the home timeline of each user, the posts of
+// the user and of the accounts they follow, so that reading it doesn't mean
+// looking up the posts of every account they follow.
+type TimelineRepository interface {
+	// Store stores the timeline of the user, replacing any stored before,
+	// even if there are no entries
+	Store(user ID, entries []TimelineEntry) error
+	// Push adds the entry to the timelines of those users that have one
+	Push(users []ID, e TimelineEntry) error
+	// Backfill adds the entries to the timeline of the user if they have one
+	Backfill(user ID, entries []TimelineEntry) error
+	// RemoveAuthor takes the posts of the author out of the user's timeline
+	RemoveAuthor(user ID, author ID) error
+	// RemovePost takes the post out of every timeline
+	RemovePost(id PostID) error
+	// Find returns up to limit entries of the user's timeline, newest first.
+	// It returns ErrTimelineNotFound if the user has no stored timeline.
+	Find(user ID, limit int) ([]TimelineEntry, error)
+}
+
+// TimelineEntry is a post in a timeline. Timelines are kept in feed order,
+// newest first, and hold at most maxTimelineLength entries.
+type TimelineEntry struct {
+	PostID    PostID    `bson:"post_id"`
+	Author    ID        `bson:"author"`
+	Timestamp time.Time `bson:"timestamp"`
+}
+
+func newTimelineEntry(p *Post) TimelineEntry {
+	return TimelineEntry{PostID: p.ID, Author: p.Author.UserID, Timestamp: p.Timestamp}
+}
+
+// isPopular reports whether the user has too many followers for their posts
+// to be pushed to them
+func (u *User) isPopular() bool {
+	return len(u.Followers) > maxFanOutFollowers
+}
@@ -0,0 +1,71 @@
+package blog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimelineRepository(t *testing.T) {
+	repo := NewTimelineRepository()
+	a, b := nextID(), nextID()
+
+	var ids []PostID
+	for i := 0; i < maxTimelineLength+2; i++ {
+		ids = append(ids, PostID(xid.New().String()))
+	}
+
+	// nothing is pushed to users without a stored timeline
+	c := nextID()
+	assert.Nil(t, repo.Push([]ID{c}, TimelineEntry{PostID: ids[0], Author: a}))
+	assert.Nil(t, repo.Backfill(c, []TimelineEntry{{PostID: ids[0], Author: a}}))
+	_, err := repo.Find(c, 0)
+	assert.Equal(t, ErrTimelineNotFound, err)
+
+	// a timeline is stored even without entries
+	assert.Nil(t, repo.Store(a, nil))
+	assert.Nil(t, repo.Store(b, nil))
+	tl, err := repo.Find(a, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, tl)
+
+	// entries stay newest first however they arrive, and the oldest drop off
+	assert.Nil(t, repo.Push([]ID{a, b}, TimelineEntry{PostID: ids[1], Author: b}))
+	var entries []TimelineEntry
+	for _, id := range append(ids[2:], ids[0]) {
+		entries = append(entries, TimelineEntry{PostID: id, Author: a})
+	}
+	assert.Nil(t, repo.Backfill(a, entries))
+
+	tl, _ = repo.Find(a, 0)
+	assert.Len(t, tl, maxTimelineLength)
+	assert.Equal(t, ids[len(ids)-1], tl[0].PostID)
+	assert.Equal(t, ids[2], tl[len(tl)-1].PostID)
+
+	tl, _ = repo.Find(a, 2)
+	assert.Len(t, tl, 2)
+
+	assert.Nil(t, repo.RemoveAuthor(a, a))
+	tl, _ = repo.Find(a, 0)
+	assert.Empty(t, tl)
+
+	assert.Nil(t, repo.RemovePost(ids[1]))
+	tl, _ = repo.Find(b, 0)
+	assert.Empty(t, tl)
+
+	// a scheduled post goes ahead of the posts published before it
+	now := time.Now()
+	assert.Nil(t, repo.Backfill(b, []TimelineEntry{
+		{PostID: ids[2], Author: a, Timestamp: now},
+		{PostID: ids[0], Author: a, Timestamp: now.Add(time.Minute)},
+	}))
+	tl, _ = repo.Find(b, 0)
+	assert.Equal(t, []PostID{ids[0], ids[2]}, []PostID{tl[0].PostID, tl[1].PostID})
+
+	// storing a timeline replaces the one stored before
+	assert.Nil(t, repo.Store(b, []TimelineEntry{{PostID: ids[1], Author: a}}))
+	tl, _ = repo.Find(b, 0)
+	assert.Equal(t, []TimelineEntry{{PostID: ids[1], Author: a}}, tl)
+}
@@ -0,0 +1,165 @@
+package blog
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+var ErrInvalidTrendWindow = errors.New("invalid trend window")
+
+// TrendWindow is the span of recent posts trending tags are measured over
+type TrendWindow string
+
+const (
+	TrendHour TrendWindow = "1h"
+	TrendDay  TrendWindow = "24h"
+)
+
+const (
+	defaultTrendLimit = 10
+	maxTrendLimit     = 50
+	// minTrendPosts is how many posts in the window a tag needs to trend, so
+	// that a tag used twice after months of silence doesn't top the list
+	minTrendPosts = 3
+)
+
+// trendPeriod describes how the tags of a window are counted. Uses are
+// counted in buckets of a fixed size, and the buckets of the baseline before
+// the window tell how often a tag is usually used.
+type trendPeriod struct {
+	window   time.Duration
+	bucket   time.Duration
+	baseline time.Duration
+}
+
+var trendPeriods = map[TrendWindow]trendPeriod{
+	TrendHour: {window: time.Hour, bucket: 5 * time.Minute, baseline: 24 * time.Hour},
+	TrendDay:  {window: 24 * time.Hour, bucket: time.Hour, baseline: 7 * 24 * time.Hour},
+}
+
+// TrendRepository keeps running counts of the tags used in posts, so that
+// trends are worked out from a few counts instead of the posts themselves.
+type TrendRepository interface {
+	// Increment adds each count to the one stored for its tag and bucket
+	Increment(counts []TagCount) error
+	// Decrement takes each count off the one stored for its tag and bucket,
+	// leaving alone the buckets that expired or don't hold as many
+	Decrement(counts []TagCount) error
+	// Find returns the counts of the buckets of the given size starting at or
+	// after since
+	Find(size time.Duration, since time.Time) ([]TagCount, error)
+}
+
+// TagCount is how many posts used a tag in the bucket of Size starting at Start
+type TagCount struct {
+	Tag   string        `bson:"tag"`
+	Size  time.Duration `bson:"size"`
+	Start time.Time     `bson:"start"`
+	Count int           `bson:"count"`
+	// Expires is when the bucket is too old to be part of any trend
+	Expires time.Time `bson:"expires"`
+}
+
+// Trend is a tag used more than usual over a window. Posts counts the posts
+// using the tag in the window and Score how far above its baseline it is.
+type Trend struct {
+	Tag   string  `json:"tag"`
+	Posts int     `json:"posts"`
+	Score float64 `json:"score"`
+}
+
+// tagCounts returns one use of each of the tags for every trend period at t
+func tagCounts(tags []string, t time.Time) []TagCount {
+	var counts []TagCount
+	for _, p := range trendPeriods {
+		start := t.Truncate(p.bucket)
+		for _, tag := range tags {
+			counts = append(counts, TagCount{
+				Tag:     tag,
+				Size:    p.bucket,
+				Start:   start,
+				Count:   1,
+				Expires: start.Add(p.bucket + p.window + p.baseline),
+			})
+		}
+	}
+	return counts
+}
+
+// weight is how much a bucket counts towards the trends of the period at now.
+// Weights halve every half window, so the latest posts count the most.
+func (p trendPeriod) weight(start, now time.Time) float64 {
+	age := now.Sub(start.Add(p.bucket / 2))
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, age.Seconds()/(p.window/2).Seconds())
+}
+
+// rankTrends works out the trending tags at now from the counts found for the
+// period, highest scores first. A tag's score compares its weighted count in
+// the window with what the count would be had it kept to its baseline rate,
+// scaled down for rarely used tags by the square root of that expectation.
+func rankTrends(counts []TagCount, p trendPeriod, now time.Time, limit int) []Trend {
+	windowStart := now.Add(-p.window)
+	baselineStart := windowStart.Add(-p.baseline)
+
+	// the weights of the buckets in the window, which a steady rate of one
+	// use per bucket would add up to
+	var steady float64
+	for start := now.Truncate(p.bucket); start.Add(p.bucket).After(windowStart); start = start.Add(-p.bucket) {
+		steady += p.weight(start, now)
+	}
+
+	type tally struct {
+		posts    int
+		weighted float64
+		past     int
+	}
+	tallies := map[string]*tally{}
+	for _, c := range counts {
+		t, ok := tallies[c.Tag]
+		if !ok {
+			t = &tally{}
+			tallies[c.Tag] = t
+		}
+
+		switch {
+		case c.Start.Add(p.bucket).After(windowStart):
+			t.posts += c.Count
+			t.weighted += float64(c.Count) * p.weight(c.Start, now)
+		case !c.Start.Before(baselineStart):
+			t.past += c.Count
+		}
+	}
+
+	trends := []Trend{}
+	perBucket := p.bucket.Seconds() / p.baseline.Seconds()
+	for tag, t := range tallies {
+		if t.posts < minTrendPosts {
+			continue
+		}
+
+		expected := float64(t.past) * perBucket * steady
+		if score := (t.weighted - expected) / math.Sqrt(expected+1); score > 0 {
+			trends = append(trends, Trend{Tag: tag, Posts: t.posts, Score: score})
+		}
+	}
+
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Score != trends[j].Score {
+			return trends[i].Score > trends[j].Score
+		}
+		if trends[i].Posts != trends[j].Posts {
+			return trends[i].Posts > trends[j].Posts
+		}
+		return trends[i].Tag < trends[j].Tag
+	})
+
+	if limit > 0 && len(trends) > limit {
+		trends = trends[:limit]
+	}
+	return trends
+}
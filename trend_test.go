@@ -0,0 +1,102 @@
+package blog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagCounts(t *testing.T) {
+	at := time.Date(2019, 6, 1, 12, 7, 30, 0, time.UTC)
+	counts := tagCounts([]string{"go", "rust"}, at)
+	assert.Len(t, counts, len(trendPeriods)*2)
+
+	for _, c := range counts {
+		p := trendPeriods[TrendHour]
+		if c.Size == trendPeriods[TrendDay].bucket {
+			p = trendPeriods[TrendDay]
+		}
+		assert.Equal(t, p.bucket, c.Size)
+		assert.Equal(t, at.Truncate(p.bucket), c.Start)
+		assert.Equal(t, 1, c.Count)
+		assert.True(t, c.Expires.After(at.Add(p.window+p.baseline)))
+	}
+}
+
+func TestRankTrends(t *testing.T) {
+	p := trendPeriods[TrendHour]
+	now := time.Date(2019, 6, 1, 12, 2, 0, 0, time.UTC)
+
+	var counts []TagCount
+	add := func(tag string, ago time.Duration, n int) {
+		counts = append(counts, TagCount{Tag: tag, Size: p.bucket, Start: now.Add(-ago).Truncate(p.bucket), Count: n})
+	}
+
+	// used just now, out of nowhere
+	add("recent", 0, 4)
+	// used a little while ago, out of nowhere
+	add("rising", 20*time.Minute, 5)
+	// used out of nowhere, but nearly an hour ago
+	add("older", 50*time.Minute, 4)
+	// counts from before the baseline are left out
+	add("rising", 30*time.Hour, 100)
+	// used less than usual
+	for ago := p.window; ago < p.window+p.baseline; ago += p.bucket {
+		add("usual", ago, 1)
+	}
+	add("usual", 0, 6)
+	// too few posts to trend
+	add("rare", 0, 2)
+
+	trends := rankTrends(counts, p, now, 0)
+	var tags []string
+	for _, tr := range trends {
+		tags = append(tags, tr.Tag)
+	}
+	assert.Equal(t, []string{"recent", "rising", "older"}, tags)
+	assert.Equal(t, 5, trends[1].Posts)
+
+	assert.Len(t, rankTrends(counts, p, now, 2), 2)
+	assert.Equal(t, []Trend{}, rankTrends(nil, p, now, 0))
+}
+
+func TestTrendRepository(t *testing.T) {
+	repo := NewTrendRepository()
+	now := time.Now().Truncate(time.Minute)
+
+	assert.Nil(t, repo.Increment([]TagCount{
+		{Tag: "go", Size: time.Minute, Start: now, Count: 1, Expires: now.Add(time.Hour)},
+		{Tag: "go", Size: time.Hour, Start: now.Truncate(time.Hour), Count: 1, Expires: now.Add(time.Hour)},
+		{Tag: "old", Size: time.Minute, Start: now.Add(-2 * time.Hour), Count: 1, Expires: now.Add(-time.Hour)},
+	}))
+	assert.Nil(t, repo.Increment([]TagCount{
+		{Tag: "go", Size: time.Minute, Start: now, Count: 2, Expires: now.Add(2 * time.Hour)},
+	}))
+
+	// counts add up, and expired ones are dropped
+	counts, _ := repo.Find(time.Minute, now.Add(-3*time.Hour))
+	assert.Equal(t, []TagCount{
+		{Tag: "go", Size: time.Minute, Start: now, Count: 3, Expires: now.Add(2 * time.Hour)},
+	}, counts)
+
+	counts, _ = repo.Find(time.Minute, now.Add(time.Minute))
+	assert.Empty(t, counts)
+
+	// counts never drop below zero, and are dropped at zero
+	assert.Nil(t, repo.Decrement([]TagCount{
+		{Tag: "go", Size: time.Minute, Start: now, Count: 1},
+		{Tag: "go", Size: time.Hour, Start: now.Truncate(time.Hour), Count: 2},
+		{Tag: "new", Size: time.Minute, Start: now, Count: 1},
+	}))
+	counts, _ = repo.Find(time.Minute, now.Add(-3*time.Hour))
+	assert.Equal(t, []TagCount{
+		{Tag: "go", Size: time.Minute, Start: now, Count: 2, Expires: now.Add(2 * time.Hour)},
+	}, counts)
+	counts, _ = repo.Find(time.Hour, now.Add(-3*time.Hour))
+	assert.Len(t, counts, 1)
+
+	assert.Nil(t, repo.Decrement([]TagCount{{Tag: "go", Size: time.Minute, Start: now, Count: 2}}))
+	counts, _ = repo.Find(time.Minute, now.Add(-3*time.Hour))
+	assert.Empty(t, counts)
+}
@@ -16,6 +16,12 @@ type Repository interface {
 	Delete(id ID) error
 	Update(u *User) error
 	FindByIDs(ids []ID) ([]User, error)
+	// Search returns up to limit users whose username or bio matches the
+	// normalized search q, ranked as rankUsers does
+	Search(q string, limit int) ([]User, error)
+	// FindPopular returns the ids of those of the users with more than
+	// followers followers
+	FindPopular(ids []ID, followers int) ([]ID, error)
 }
 
 type ID string
@@ -29,6 +35,11 @@ type User struct {
 	Bio       string
 	Friends   []ID
 	Followers []ID
+	// ExpandSensitive shows posts with content warnings or sensitive media
+	// expanded
+	ExpandSensitive bool
+	// Pinned holds the posts pinned to the profile, most recently pinned first
+	Pinned []PostID
 }
 
 var (
@@ -89,7 +100,7 @@ func nextID() ID {
 	return ID(xid.New().String())
 }
 
-//IsValidID checks if a given id is valid based on the xid library definition of a valid id
+// IsValidID checks if a given id is valid based on the xid library definition of a valid id
 // this method should change if we ever change our uid generation library
 func IsValidID(id string) bool {
 	if _, err := xid.FromString(id); err == xid.ErrInvalidID {
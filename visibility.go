@@ -0,0 +1,65 @@
+package blog
+
+import "errors"
+
+var (
+	ErrInvalidVisibility = errors.New("invalid visibility")
+	ErrCantRepostPrivate = errors.New("only public posts can be reposted")
+)
+
+// Visibility decides who can read a post
+type Visibility string
+
+const (
+	// VisibilityPublic posts can be read by anyone, signed in or not
+	VisibilityPublic Visibility = "public"
+	// VisibilityFollowers posts can be read by the followers of the author
+	// and the users mentioned in them
+	VisibilityFollowers Visibility = "followers"
+	// VisibilityMentioned posts can only be read by the users mentioned in them
+	VisibilityMentioned Visibility = "mentioned"
+)
+
+// SetVisibility sets who can read the post. An empty visibility makes it public.
+func (p *Post) SetVisibility(v Visibility) error {
+	switch v {
+	case "", VisibilityPublic:
+		p.Visibility = ""
+	case VisibilityFollowers, VisibilityMentioned:
+		p.Visibility = v
+	default:
+		return ErrInvalidVisibility
+	}
+	return nil
+}
+
+// IsPublic reports whether anyone can read the post. Posts stored before
+// visibility existed have none and are public.
+func (p *Post) IsPublic() bool {
+	return p.Visibility == "" || p.Visibility == VisibilityPublic
+}
+
+// IsVisibleTo reports whether the viewer can read the post given whether
+// they follow its author. An empty viewer is anonymous and only sees public
+// posts.
+func (p *Post) IsVisibleTo(viewer ID, follows bool) bool {
+	if p.IsPublic() {
+		return true
+	}
+	if viewer == "" {
+		return false
+	}
+	if p.Author.UserID == viewer || p.isMentioned(viewer) {
+		return true
+	}
+	return p.Visibility == VisibilityFollowers && follows
+}
+
+func (p *Post) isMentioned(id ID) bool {
+	for _, m := range p.Mentions {
+		if m.UserID == id {
+			return true
+		}
+	}
+	return false
+}
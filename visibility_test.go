@@ -0,0 +1,51 @@
+package blog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPost_SetVisibility(t *testing.T) {
+	p, _ := NewPost(Author{UserID: nextID()}, "body")
+	assert.True(t, p.IsPublic())
+
+	assert.Equal(t, ErrInvalidVisibility, p.SetVisibility("friends"))
+	assert.Nil(t, p.SetVisibility(VisibilityMentioned))
+	assert.Equal(t, VisibilityMentioned, p.Visibility)
+	assert.False(t, p.IsPublic())
+
+	// public posts are stored without a visibility
+	assert.Nil(t, p.SetVisibility(VisibilityPublic))
+	assert.Equal(t, Visibility(""), p.Visibility)
+	assert.True(t, p.IsPublic())
+}
+
+func TestPost_IsVisibleTo(t *testing.T) {
+	author, mentioned, follower, stranger := nextID(), nextID(), nextID(), nextID()
+	p := Post{Author: Author{UserID: author}, Mentions: []Mention{{UserID: mentioned, Username: "m"}}}
+
+	tests := []struct {
+		visibility Visibility
+		viewer     ID
+		follows    bool
+		want       bool
+	}{
+		{visibility: "", viewer: "", want: true},
+		{visibility: VisibilityPublic, viewer: stranger, want: true},
+		{visibility: VisibilityFollowers, viewer: "", want: false},
+		{visibility: VisibilityFollowers, viewer: author, want: true},
+		{visibility: VisibilityFollowers, viewer: follower, follows: true, want: true},
+		{visibility: VisibilityFollowers, viewer: mentioned, want: true},
+		{visibility: VisibilityFollowers, viewer: stranger, want: false},
+		{visibility: VisibilityMentioned, viewer: "", want: false},
+		{visibility: VisibilityMentioned, viewer: author, want: true},
+		{visibility: VisibilityMentioned, viewer: mentioned, want: true},
+		{visibility: VisibilityMentioned, viewer: follower, follows: true, want: false},
+	}
+
+	for _, tt := range tests {
+		p.Visibility = tt.visibility
+		assert.Equal(t, tt.want, p.IsVisibleTo(tt.viewer, tt.follows), "%s to %s", tt.visibility, tt.viewer)
+	}
+}